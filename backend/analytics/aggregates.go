@@ -0,0 +1,91 @@
+package analytics
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Aggregates summarizes the game_end events stored by the consumer (see
+// store.go), so it only covers games that ended while event storage was
+// running. Fields added to game_end later (firstPlayer for every game,
+// winnerName, winningColumn) are missing from older events, which are left
+// out of the statistics that need them.
+type Aggregates struct {
+	// Since is the start of the period covered, or nil for all time
+	Since *time.Time `json:"since"`
+	Games int        `json:"games"`
+	// AverageDurationSeconds is nil until a game with a known duration ends
+	AverageDurationSeconds *float64 `json:"averageDurationSeconds"`
+	// MostCommonWinningColumn is the column that most often held the disc
+	// completing a winning line, nil if no game was won by a line
+	MostCommonWinningColumn *ColumnWins     `json:"mostCommonWinningColumn"`
+	FirstMover              FirstMoverStats `json:"firstMover"`
+}
+
+type ColumnWins struct {
+	Column int `json:"column"`
+	Wins   int `json:"wins"`
+}
+
+// FirstMoverStats counts results by who moved first. Abandoned games are
+// left out.
+type FirstMoverStats struct {
+	Games              int     `json:"games"`
+	FirstMoverWins     int     `json:"firstMoverWins"`
+	SecondMoverWins    int     `json:"secondMoverWins"`
+	Draws              int     `json:"draws"`
+	FirstMoverWinRate  float64 `json:"firstMoverWinRate"`
+	SecondMoverWinRate float64 `json:"secondMoverWinRate"`
+}
+
+// GetAggregates summarizes the games that ended at or after since; a zero
+// since covers every stored game
+func (s *Service) GetAggregates(since time.Time) (*Aggregates, error) {
+	aggregates := &Aggregates{}
+	if !since.IsZero() {
+		aggregates.Since = &since
+	}
+
+	var averageDuration sql.NullFloat64
+	first := &aggregates.FirstMover
+	err := s.db.QueryRow(`
+		SELECT
+			COUNT(*),
+			AVG((payload->>'duration')::float),
+			COUNT(*) FILTER (WHERE payload->>'winnerName' = payload->>'firstPlayer'),
+			COUNT(*) FILTER (WHERE payload->>'winnerName' <> payload->>'firstPlayer'),
+			COUNT(*) FILTER (WHERE payload->>'firstPlayer' IS NOT NULL AND payload->>'winner' = 'draw'
+				AND payload->>'resultType' <> 'abandoned')
+		FROM game_events
+		WHERE type = 'game_end' AND timestamp >= $1
+	`, since.UTC()).Scan(&aggregates.Games, &averageDuration, &first.FirstMoverWins, &first.SecondMoverWins, &first.Draws)
+	if err != nil {
+		return nil, err
+	}
+	if averageDuration.Valid {
+		aggregates.AverageDurationSeconds = &averageDuration.Float64
+	}
+	first.Games = first.FirstMoverWins + first.SecondMoverWins + first.Draws
+	if first.Games > 0 {
+		first.FirstMoverWinRate = float64(first.FirstMoverWins) / float64(first.Games)
+		first.SecondMoverWinRate = float64(first.SecondMoverWins) / float64(first.Games)
+	}
+
+	var column ColumnWins
+	err = s.db.QueryRow(`
+		SELECT (payload->>'winningColumn')::int AS winning_column, COUNT(*) AS wins
+		FROM game_events
+		WHERE type = 'game_end' AND timestamp >= $1 AND payload->>'winningColumn' IS NOT NULL
+		GROUP BY winning_column
+		ORDER BY wins DESC, winning_column
+		LIMIT 1
+	`, since.UTC()).Scan(&column.Column, &column.Wins)
+	switch {
+	case err == nil:
+		aggregates.MostCommonWinningColumn = &column
+	case err != sql.ErrNoRows:
+		return nil, err
+	}
+
+	return aggregates, nil
+}
@@ -1,68 +1,267 @@
 package analytics
 
 import (
+	"connect-four/bot"
+	"connect-four/config"
 	"connect-four/game"
+	"connect-four/metrics"
+	"context"
+	"database/sql"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/IBM/sarama"
 )
 
+// Events are batched by the async producer and sent at least this often
+const (
+	flushFrequency = 100 * time.Millisecond
+	flushMessages  = 100
+)
+
 type Service struct {
-	producer sarama.SyncProducer
+	// producer sends events in the background. Its input buffer holds up to
+	// ANALYTICS_BUFFER_SIZE events; once it is full, events are dropped and
+	// counted rather than holding up the game.
+	producer sarama.AsyncProducer
+	// closeMu guards closed, so no event is sent to a closed producer
+	closeMu  sync.RWMutex
+	closed   bool
 	consumer sarama.Consumer
-	db       interface{} // Can be *sql.DB if needed
+	// stopConsumer tells the consumer loop to store its last batch and stop;
+	// consumerDone is closed once it has
+	stopConsumer chan struct{}
+	consumerDone chan struct{}
+	// topic is the Kafka topic events are published to and consumed from
+	// (KAFKA_TOPIC)
+	topic   string
+	brokers []string
+	// db keeps the consumed events, see store.go
+	db           *sql.DB
+	partitionKey PartitionKeyStrategy
+	// verbose enables high-volume events such as bot decisions (ANALYTICS_VERBOSE=true)
+	verbose bool
 }
 
-func NewService() (*Service, error) {
-	brokers := getKafkaBrokers()
-	if len(brokers) == 0 {
-		brokers = []string{"localhost:9092"}
+// PartitionKeyStrategy decides which event field is used as the Kafka
+// message key, and therefore how events spread across partitions.
+type PartitionKeyStrategy string
+
+const (
+	PartitionByGameID PartitionKeyStrategy = "gameId"
+	PartitionByPlayer PartitionKeyStrategy = "player"
+	PartitionByType   PartitionKeyStrategy = "type"
+)
+
+// NewService connects to Kafka and starts consuming game events into the
+// game_events table of db
+func NewService(db *sql.DB) (*Service, error) {
+	if err := createTables(db); err != nil {
+		return nil, err
+	}
+
+	brokers, err := getKafkaBrokers()
+	if err != nil {
+		return nil, err
+	}
+	topic, err := getKafkaTopic()
+	if err != nil {
+		return nil, err
 	}
+	slog.Info("Analytics using Kafka", "brokers", brokers, "topic", topic)
 
-	config := sarama.NewConfig()
-	config.Producer.Return.Successes = true
-	config.Producer.RequiredAcks = sarama.WaitForAll
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Producer.RequiredAcks = sarama.WaitForAll
+	saramaConfig.Producer.Return.Errors = true
+	saramaConfig.Consumer.Return.Errors = true
+	saramaConfig.Producer.Flush.Frequency = flushFrequency
+	saramaConfig.Producer.Flush.Messages = flushMessages
+	saramaConfig.ChannelBufferSize = config.GetEnvInt("ANALYTICS_BUFFER_SIZE", 1000)
 
-	producer, err := sarama.NewSyncProducer(brokers, config)
+	producer, err := sarama.NewAsyncProducer(brokers, saramaConfig)
 	if err != nil {
 		return nil, err
 	}
 
-	consumer, err := sarama.NewConsumer(brokers, config)
+	consumer, err := sarama.NewConsumer(brokers, saramaConfig)
 	if err != nil {
 		producer.Close()
 		return nil, err
 	}
 
 	service := &Service{
-		producer: producer,
-		consumer: consumer,
+		producer:     producer,
+		consumer:     consumer,
+		stopConsumer: make(chan struct{}),
+		consumerDone: make(chan struct{}),
+		topic:        topic,
+		brokers:      brokers,
+		db:           db,
+		partitionKey: getPartitionKeyStrategy(),
+		verbose:      os.Getenv("ANALYTICS_VERBOSE") == "true",
 	}
 
+	go service.reportProducerErrors()
+
 	// Start consumer in background
 	go service.startConsumer()
 
 	return service, nil
 }
 
+// reportProducerErrors logs and counts events Kafka did not accept. It ends
+// when the producer is closed.
+func (s *Service) reportProducerErrors() {
+	for err := range s.producer.Errors() {
+		metrics.AnalyticsEventsDropped.WithLabelValues("kafka_error").Inc()
+		slog.Error("Error sending event to Kafka", "error", err.Err)
+	}
+}
+
+// Close sends the events still buffered, stores the events consumed so far
+// and stops the producer and consumer. Events tracked afterwards are
+// ignored.
+func (s *Service) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.closeMu.Lock()
+	if s.closed {
+		s.closeMu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.closeMu.Unlock()
+
+	err := s.producer.Close()
+	close(s.stopConsumer)
+	<-s.consumerDone
+	if consumerErr := s.consumer.Close(); err == nil {
+		err = consumerErr
+	}
+	return err
+}
+
+// Ping reports whether events can still be sent: the producer must be open
+// and at least one broker must accept a connection before ctx is done
+func (s *Service) Ping(ctx context.Context) error {
+	s.closeMu.RLock()
+	closed := s.closed
+	s.closeMu.RUnlock()
+	if closed {
+		return errors.New("producer is closed")
+	}
+
+	var dialer net.Dialer
+	var err error
+	for _, broker := range s.brokers {
+		var conn net.Conn
+		if conn, err = dialer.DialContext(ctx, "tcp", broker); err == nil {
+			conn.Close()
+			return nil
+		}
+	}
+	return err
+}
+
+// startConsumer reads every partition of the topic, each in its own
+// goroutine, and stores the events in batches until Close. Messages that
+// can't be decoded go to the dead-letter table.
 func (s *Service) startConsumer() {
-	partitionConsumer, err := s.consumer.ConsumePartition("game-events", 0, sarama.OffsetNewest)
+	defer close(s.consumerDone)
+
+	partitions, err := s.consumer.Partitions(s.topic)
 	if err != nil {
-		log.Printf("Error creating partition consumer: %v", err)
+		slog.Error("Error listing topic partitions", "topic", s.topic, "error", err)
 		return
 	}
-	defer partitionConsumer.Close()
 
-	for message := range partitionConsumer.Messages() {
-		var event map[string]interface{}
-		if err := json.Unmarshal(message.Value, &event); err != nil {
-			log.Printf("Error unmarshaling event: %v", err)
+	events := make(chan storedEvent)
+	errs := make(chan error)
+	var wg sync.WaitGroup
+	for _, partition := range partitions {
+		partitionConsumer, err := s.consumer.ConsumePartition(s.topic, partition, sarama.OffsetNewest)
+		if err != nil {
+			slog.Error("Error creating partition consumer", "topic", s.topic, "partition", partition, "error", err)
 			continue
 		}
-		s.processEvent(event)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.consumePartition(partitionConsumer, events, errs)
+		}()
+	}
+	// events is closed once every partition goroutine has stopped
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+
+	ticker := time.NewTicker(persistInterval)
+	defer ticker.Stop()
+
+	var batch []storedEvent
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				s.persistEvents(batch)
+				return
+			}
+			if batch = append(batch, event); len(batch) >= persistBatchSize {
+				s.persistEvents(batch)
+				batch = nil
+			}
+		case err := <-errs:
+			slog.Error("Analytics consumer error", "error", err)
+		case <-ticker.C:
+			s.persistEvents(batch)
+			batch = nil
+		}
+	}
+}
+
+// consumePartition decodes one partition's messages onto events, and its
+// errors onto errs, until Close or the partition consumer stops
+func (s *Service) consumePartition(partitionConsumer sarama.PartitionConsumer, events chan<- storedEvent, errs chan<- error) {
+	defer partitionConsumer.Close()
+
+	for {
+		select {
+		case message, ok := <-partitionConsumer.Messages():
+			if !ok {
+				return
+			}
+			event, err := decodeEvent(message.Value, message.Timestamp)
+			if err != nil {
+				slog.Error("Error decoding analytics event", "partition", message.Partition, "offset", message.Offset, "error", err)
+				s.deadLetter(message.Value, message.Partition, message.Offset, err)
+				continue
+			}
+			select {
+			case events <- event:
+			case <-s.stopConsumer:
+				return
+			}
+		case err, ok := <-partitionConsumer.Errors():
+			if !ok {
+				return
+			}
+			select {
+			case errs <- err:
+			case <-s.stopConsumer:
+				return
+			}
+		case <-s.stopConsumer:
+			return
+		}
 	}
 }
 
@@ -81,40 +280,39 @@ func (s *Service) TrackGameStart(game *game.Game) {
 	s.sendEvent(event)
 }
 
-func (s *Service) TrackMove(game *game.Game, column, row int) {
+// TrackMove publishes a move. The mover is passed in rather than read from
+// the game, whose turn has already passed to the opponent.
+func (s *Service) TrackMove(g *game.Game, moverID, username string, column, row int) {
 	if s == nil || s.producer == nil {
 		return
 	}
-	player := "bot"
-	if game.CurrentPlayer == game.Player1.ID {
-		player = game.Player1.Username
-	}
 
 	event := map[string]interface{}{
 		"type":       "move",
-		"gameId":     game.ID,
-		"player":     player,
+		"gameId":     g.ID,
+		"playerId":   moverID,
+		"player":     username,
 		"column":     column,
 		"row":        row,
-		"moveNumber": len(game.Moves),
+		"moveNumber": len(g.Moves),
 		"timestamp":  time.Now().Format(time.RFC3339),
 	}
 	s.sendEvent(event)
 }
 
-func (s *Service) TrackGameEnd(game *game.Game) {
+func (s *Service) TrackGameEnd(g *game.Game) {
 	if s == nil || s.producer == nil {
 		return
 	}
 	var duration *int
-	if game.EndedAt != nil {
-		d := int(game.EndedAt.Sub(game.StartedAt).Seconds())
+	if g.EndedAt != nil {
+		d := int(g.EndedAt.Sub(g.StartedAt).Seconds())
 		duration = &d
 	}
 
 	winner := "draw"
-	if game.Winner != "draw" && game.Winner != "" {
-		if game.Winner == "bot" {
+	if g.Winner != "draw" && g.Winner != "" {
+		if g.Winner == game.BotID {
 			winner = "bot"
 		} else {
 			winner = "player"
@@ -122,57 +320,190 @@ func (s *Service) TrackGameEnd(game *game.Game) {
 	}
 
 	event := map[string]interface{}{
-		"type":       "game_end",
-		"gameId":     game.ID,
-		"winner":     winner,
-		"duration":   duration,
-		"totalMoves": len(game.Moves),
-		"timestamp":  time.Now().Format(time.RFC3339),
+		"type":        "game_end",
+		"gameId":      g.ID,
+		"winner":      winner,
+		"resultType":  g.ResultType,
+		"duration":    duration,
+		"totalMoves":  len(g.Moves),
+		"firstPlayer": usernameForID(g, firstPlayerID(g)),
+		"timestamp":   time.Now().Format(time.RFC3339),
+	}
+	if winner != "draw" {
+		event["winnerName"] = usernameForID(g, g.Winner)
+	}
+	// The column of the disc that completed the line
+	if g.ResultType == game.ResultWin && len(g.Moves) > 0 {
+		event["winningColumn"] = g.Moves[len(g.Moves)-1].Column
+	}
+	if g.EndedAt != nil {
+		event["timestamp"] = g.EndedAt.Format(time.RFC3339)
+	}
+	if g.CoinFlip != nil {
+		event["coinFlipSeed"] = g.CoinFlip.Seed
 	}
-	if game.EndedAt != nil {
-		event["timestamp"] = game.EndedAt.Format(time.RFC3339)
+	s.sendEvent(event)
+}
+
+// TrackReconnect publishes a player's disconnect and the outcome of their
+// reconnect window, to show whether the window is long enough
+func (s *Service) TrackReconnect(g *game.Game, playerID, outcome string, elapsed, window time.Duration) {
+	if s == nil || s.producer == nil {
+		return
+	}
+
+	event := map[string]interface{}{
+		"type":          "reconnect",
+		"gameId":        g.ID,
+		"player":        usernameForID(g, playerID),
+		"outcome":       outcome,
+		"elapsedMs":     elapsed.Milliseconds(),
+		"windowSeconds": int(window / time.Second),
+		"timestamp":     time.Now().Format(time.RFC3339),
+	}
+	s.sendEvent(event)
+}
+
+// TrackBotDecision publishes the bot's candidate scores and chosen column.
+// It is only sent in verbose mode; the regular move event is unaffected.
+func (s *Service) TrackBotDecision(g *game.Game, explanation *bot.MoveExplanation) {
+	if s == nil || s.producer == nil || !s.verbose {
+		return
+	}
+
+	event := map[string]interface{}{
+		"type":       "bot_decision",
+		"gameId":     g.ID,
+		"column":     explanation.Column,
+		"reason":     explanation.Reason,
+		"candidates": explanation.Candidates,
+		"moveNumber": len(g.Moves) + 1,
+		"timestamp":  time.Now().Format(time.RFC3339),
 	}
 	s.sendEvent(event)
 }
 
+// firstPlayerID returns the ID of the player who moved first: the coin
+// flip's pick if there was one, otherwise player1
+func firstPlayerID(g *game.Game) string {
+	if len(g.Moves) > 0 {
+		return g.Moves[0].Player
+	}
+	if g.CoinFlip != nil {
+		return g.CoinFlip.Starter
+	}
+	return g.Player1.ID
+}
+
+// usernameForID maps a player ID (or game.BotID) to a display name
+func usernameForID(g *game.Game, playerID string) string {
+	if playerID == g.Player2.ID || playerID == game.BotID {
+		return g.Player2.Username
+	}
+	return g.Player1.Username
+}
+
+// sendEvent queues an event for the producer without blocking. If the
+// buffer is full, because Kafka is slow or down, the event is dropped.
 func (s *Service) sendEvent(event map[string]interface{}) {
 	if s == nil || s.producer == nil {
 		return
 	}
 	eventJSON, err := json.Marshal(event)
 	if err != nil {
-		log.Printf("Error marshaling event: %v", err)
+		slog.Error("Error marshaling event", "error", err)
 		return
 	}
 
+	msg := &sarama.ProducerMessage{
+		Topic: s.topic,
+		Key:   sarama.StringEncoder(s.messageKey(event)),
+		Value: sarama.ByteEncoder(eventJSON),
+	}
+
+	s.closeMu.RLock()
+	defer s.closeMu.RUnlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.producer.Input() <- msg:
+	default:
+		metrics.AnalyticsEventsDropped.WithLabelValues("buffer_full").Inc()
+	}
+}
+
+// messageKey picks the partition key for an event according to the configured
+// strategy. Events missing the chosen field fall back to the gameId key.
+func (s *Service) messageKey(event map[string]interface{}) string {
+	switch s.partitionKey {
+	case PartitionByType:
+		if eventType, _ := event["type"].(string); eventType != "" {
+			return eventType
+		}
+	case PartitionByPlayer:
+		if player, _ := event["player"].(string); player != "" {
+			return player
+		}
+		if player, _ := event["player1"].(string); player != "" {
+			return player
+		}
+	}
+
 	gameID, _ := event["gameId"].(string)
 	if gameID == "" {
 		gameID = "system"
 	}
+	return gameID
+}
 
-	msg := &sarama.ProducerMessage{
-		Topic: "game-events",
-		Key:   sarama.StringEncoder(gameID),
-		Value: sarama.ByteEncoder(eventJSON),
+// getKafkaBrokers reads KAFKA_BROKERS as a comma-separated list of
+// host:port addresses, ignoring blanks around and between entries. Only an
+// unset or empty variable falls back to localhost.
+func getKafkaBrokers() ([]string, error) {
+	value := os.Getenv("KAFKA_BROKERS")
+	if value == "" {
+		return []string{"localhost:9092"}, nil
+	}
+	brokers := parseBrokers(value)
+	if len(brokers) == 0 {
+		return nil, fmt.Errorf("KAFKA_BROKERS %q lists no brokers", value)
 	}
+	return brokers, nil
+}
 
-	_, _, err = s.producer.SendMessage(msg)
-	if err != nil {
-		log.Printf("Error sending event to Kafka: %v", err)
+func parseBrokers(value string) []string {
+	brokers := []string{}
+	for _, broker := range strings.Split(value, ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			brokers = append(brokers, broker)
+		}
 	}
+	return brokers
 }
 
-func (s *Service) processEvent(event map[string]interface{}) {
-	// Store event or process analytics
-	// This can be extended to store in database
-	log.Printf("Processing analytics event: %+v", event)
+// getKafkaTopic reads KAFKA_TOPIC, "game-events" if unset. Separate
+// environments sharing a cluster should each use their own topic.
+func getKafkaTopic() (string, error) {
+	value, set := os.LookupEnv("KAFKA_TOPIC")
+	if !set {
+		return "game-events", nil
+	}
+	topic := strings.TrimSpace(value)
+	if topic == "" {
+		return "", fmt.Errorf("KAFKA_TOPIC is set but empty")
+	}
+	return topic, nil
 }
 
-func getKafkaBrokers() []string {
-	brokersStr := os.Getenv("KAFKA_BROKERS")
-	if brokersStr == "" {
-		return []string{}
+func getPartitionKeyStrategy() PartitionKeyStrategy {
+	switch strategy := PartitionKeyStrategy(os.Getenv("KAFKA_PARTITION_KEY")); strategy {
+	case PartitionByPlayer, PartitionByType:
+		return strategy
+	case "", PartitionByGameID:
+		return PartitionByGameID
+	default:
+		slog.Warn("Unknown KAFKA_PARTITION_KEY, partitioning by gameId", "value", string(strategy))
+		return PartitionByGameID
 	}
-	// Simple split - in production, handle more complex cases
-	return []string{brokersStr}
 }
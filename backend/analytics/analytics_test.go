@@ -0,0 +1,152 @@
+package analytics
+
+import (
+	"connect-four/game"
+	"connect-four/metrics"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newTestService returns a Service publishing to producer, with no consumer
+// loop running
+func newTestService(t *testing.T, producer sarama.AsyncProducer) *Service {
+	consumerDone := make(chan struct{})
+	close(consumerDone)
+	return &Service{
+		producer:     producer,
+		consumer:     mocks.NewConsumer(t, nil),
+		stopConsumer: make(chan struct{}),
+		consumerDone: consumerDone,
+		topic:        "game-events",
+		partitionKey: PartitionByGameID,
+	}
+}
+
+func testGame() *game.Game {
+	return &game.Game{
+		ID:        "game-1",
+		Player1:   &game.Player{ID: "p1", Username: "alice"},
+		Player2:   &game.Player{ID: "p2", Username: "bob"},
+		StartedAt: time.Now(),
+	}
+}
+
+func TestEventsAreBufferedAndFlushedOnClose(t *testing.T) {
+	const moves = 50
+	config := mocks.NewTestConfig()
+	config.ChannelBufferSize = moves + 2
+	config.Producer.Return.Successes = true
+	producer := mocks.NewAsyncProducer(t, config)
+
+	var types []string
+	for i := 0; i < moves+2; i++ {
+		producer.ExpectInputWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+			value, _ := msg.Value.Encode()
+			var event map[string]interface{}
+			if err := json.Unmarshal(value, &event); err != nil {
+				return err
+			}
+			if key, _ := msg.Key.Encode(); string(key) != "game-1" || msg.Topic != "game-events" {
+				t.Errorf("event sent to %s with key %q", msg.Topic, key)
+			}
+			types = append(types, event["type"].(string))
+			return nil
+		})
+	}
+	s := newTestService(t, producer)
+
+	// Nothing reads the producer's successes, yet no call blocks: the events
+	// wait in the producer's buffer
+	g := testGame()
+	start := time.Now()
+	s.TrackGameStart(g)
+	for i := 0; i < moves; i++ {
+		s.TrackMove(g, "p1", "alice", i%7, 0)
+	}
+	s.TrackGameEnd(g)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("tracking %d events took %v", moves+2, elapsed)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if len(types) != moves+2 || types[0] != "game_start" || types[moves+1] != "game_end" {
+		t.Fatalf("flushed %d events starting %v, want all %d in order", len(types), types[:1], moves+2)
+	}
+	sent := 0
+	for range producer.Successes() {
+		sent++
+	}
+	if sent != moves+2 {
+		t.Errorf("%d events acknowledged, want %d", sent, moves+2)
+	}
+
+	// Events after Close are ignored rather than sent to a closed producer
+	s.TrackMove(g, "p1", "alice", 0, 0)
+}
+
+// stalledProducer is an AsyncProducer whose broker never takes a message
+type stalledProducer struct {
+	sarama.AsyncProducer
+	input chan *sarama.ProducerMessage
+}
+
+func (p *stalledProducer) Input() chan<- *sarama.ProducerMessage { return p.input }
+
+func TestFullBufferDropsEvents(t *testing.T) {
+	s := &Service{producer: &stalledProducer{input: make(chan *sarama.ProducerMessage, 3)}, topic: "game-events"}
+	dropped := metrics.AnalyticsEventsDropped.WithLabelValues("buffer_full")
+	before := testutil.ToFloat64(dropped)
+
+	g := testGame()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			s.TrackMove(g, "p1", "alice", 0, i)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("TrackMove blocked on a full buffer")
+	}
+
+	if got := testutil.ToFloat64(dropped) - before; got != 7 {
+		t.Errorf("%v events counted as dropped, want 7", got)
+	}
+}
+
+func TestGetKafkaBrokers(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{"", []string{"localhost:9092"}, false},
+		{"b1:9092", []string{"b1:9092"}, false},
+		{"b1:9092,b2:9092,b3:9092", []string{"b1:9092", "b2:9092", "b3:9092"}, false},
+		{"b1:9092,b2:9092,", []string{"b1:9092", "b2:9092"}, false},
+		{" b1:9092 , b2:9092\t", []string{"b1:9092", "b2:9092"}, false},
+		{"b1:9092,,b2:9092", []string{"b1:9092", "b2:9092"}, false},
+		{" , ", nil, true},
+	}
+	for _, tt := range tests {
+		t.Setenv("KAFKA_BROKERS", tt.value)
+		got, err := getKafkaBrokers()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("KAFKA_BROKERS=%q: error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("KAFKA_BROKERS=%q: brokers = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package analytics
+
+import (
+	"connect-four/metrics"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Consumed events are written to game_events in batches of up to
+// persistBatchSize, and at least every persistInterval
+const (
+	persistBatchSize = 100
+	persistInterval  = time.Second
+)
+
+// storedEvent is a consumed event as it is written to game_events
+type storedEvent struct {
+	Type      string
+	GameID    string
+	Payload   []byte
+	Timestamp time.Time
+}
+
+// createTables sets up game_events, which keeps every consumed event, and
+// game_events_dead_letters, which keeps messages that couldn't be decoded
+func createTables(db *sql.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS game_events (
+			id BIGSERIAL PRIMARY KEY,
+			type VARCHAR(50) NOT NULL,
+			game_id VARCHAR(255),
+			payload JSONB NOT NULL,
+			timestamp TIMESTAMP NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS game_events_game_id ON game_events (game_id)`,
+		`CREATE INDEX IF NOT EXISTS game_events_type_timestamp ON game_events (type, timestamp)`,
+		`CREATE TABLE IF NOT EXISTS game_events_dead_letters (
+			id BIGSERIAL PRIMARY KEY,
+			payload BYTEA NOT NULL,
+			error TEXT NOT NULL,
+			kafka_offset BIGINT,
+			received_at TIMESTAMP DEFAULT NOW()
+		)`,
+		`ALTER TABLE game_events_dead_letters ADD COLUMN IF NOT EXISTS kafka_partition INT`,
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeEvent parses a consumed message. An event without its own timestamp
+// is stamped with received, or the current time if Kafka didn't set one.
+func decodeEvent(value []byte, received time.Time) (storedEvent, error) {
+	var event map[string]interface{}
+	if err := json.Unmarshal(value, &event); err != nil {
+		return storedEvent{}, err
+	}
+	eventType, _ := event["type"].(string)
+	if eventType == "" {
+		return storedEvent{}, fmt.Errorf("event has no type")
+	}
+
+	gameID, _ := event["gameId"].(string)
+	timestamp := received
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	if raw, _ := event["timestamp"].(string); raw != "" {
+		if parsed, err := time.Parse(time.RFC3339, raw); err == nil {
+			timestamp = parsed
+		}
+	}
+	return storedEvent{Type: eventType, GameID: gameID, Payload: value, Timestamp: timestamp}, nil
+}
+
+// persistEvents inserts a batch of events with one statement. A batch that
+// can't be written is logged and counted as dropped.
+func (s *Service) persistEvents(events []storedEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	placeholders := make([]string, len(events))
+	args := make([]interface{}, 0, len(events)*4)
+	for i, event := range events {
+		placeholders[i] = fmt.Sprintf("($%d, $%d, $%d, $%d)", i*4+1, i*4+2, i*4+3, i*4+4)
+		var gameID interface{}
+		if event.GameID != "" {
+			gameID = event.GameID
+		}
+		args = append(args, event.Type, gameID, string(event.Payload), event.Timestamp.UTC())
+	}
+
+	_, err := s.db.Exec(
+		`INSERT INTO game_events (type, game_id, payload, timestamp) VALUES `+strings.Join(placeholders, ", "),
+		args...,
+	)
+	if err != nil {
+		metrics.AnalyticsEventsDropped.WithLabelValues("store_error").Add(float64(len(events)))
+		slog.Error("Error storing analytics events", "count", len(events), "error", err)
+	}
+}
+
+// deadLetter keeps a message that couldn't be decoded, with the reason
+func (s *Service) deadLetter(value []byte, partition int32, offset int64, reason error) {
+	_, err := s.db.Exec(
+		`INSERT INTO game_events_dead_letters (payload, error, kafka_partition, kafka_offset) VALUES ($1, $2, $3, $4)`,
+		value, reason.Error(), partition, offset,
+	)
+	if err != nil {
+		slog.Error("Error storing undecodable analytics event", "partition", partition, "offset", offset, "error", err)
+	}
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIssueThenVerify(t *testing.T) {
+	s := NewSigner([]byte("secret"), time.Hour)
+	token, expiresAt, err := s.Issue("alice")
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if until := time.Until(expiresAt); until <= 59*time.Minute || until > time.Hour {
+		t.Errorf("token expires in %v, want an hour", until)
+	}
+
+	username, err := s.Verify(token)
+	if err != nil || username != "alice" {
+		t.Errorf("Verify = %q, %v, want alice", username, err)
+	}
+}
+
+func TestVerifyRejectsBadTokens(t *testing.T) {
+	s := NewSigner([]byte("secret"), time.Hour)
+	token, _, _ := s.Issue("alice")
+	parts := strings.Split(token, ".")
+
+	expired, _, _ := NewSigner([]byte("secret"), -time.Second).Issue("alice")
+	otherSecret, _, _ := NewSigner([]byte("other"), time.Hour).Issue("alice")
+	// The payload rewritten to another username, keeping alice's signature
+	forged := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"mallory","iat":0,"exp":99999999999}`)) + "." + parts[2]
+	// A token claiming to need no signature at all
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`)) + "." + parts[1] + "."
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"missing", "", ErrMissingToken},
+		{"not a JWT", "garbage", ErrInvalidToken},
+		{"expired", expired, ErrExpiredToken},
+		{"signed with another secret", otherSecret, ErrInvalidToken},
+		{"tampered payload", forged, ErrInvalidToken},
+		{"tampered signature", parts[0] + "." + parts[1] + ".AAAA", ErrInvalidToken},
+		{"alg none", unsigned, ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		if username, err := s.Verify(tt.token); err != tt.want || username != "" {
+			t.Errorf("%s: Verify = %q, %v, want %v", tt.name, username, err, tt.want)
+		}
+	}
+}
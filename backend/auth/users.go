@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrWrongPassword is returned by Users.Authenticate for a protected
+// username when the password is missing or doesn't match
+var ErrWrongPassword = errors.New("wrong password")
+
+// Users keeps the password-protected usernames in the users table. A
+// username without a row is open to anyone; asking for a token for it with
+// a password claims it, and from then on only that password gets a token.
+// Usernames are compared case-insensitively.
+type Users struct {
+	db *sql.DB
+}
+
+func NewUsers(db *sql.DB) (*Users, error) {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS users (
+			username VARCHAR(255) PRIMARY KEY,
+			password_hash TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS users_username_lower ON users (LOWER(username))`)
+	if err != nil {
+		return nil, err
+	}
+	return &Users{db: db}, nil
+}
+
+// Authenticate checks password for username, claiming an unprotected
+// username if password is set. It returns the username as registered, or
+// username itself if it stays unprotected.
+func (u *Users) Authenticate(username, password string) (string, error) {
+	registered, hash, err := u.lookup(username)
+	if err == sql.ErrNoRows {
+		if password == "" {
+			return username, nil
+		}
+		if err := u.register(username, password); err != nil {
+			return "", err
+		}
+		// Someone else may have claimed it in the meantime
+		registered, hash, err = u.lookup(username)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return "", ErrWrongPassword
+	}
+	return registered, nil
+}
+
+func (u *Users) lookup(username string) (registered, hash string, err error) {
+	err = u.db.QueryRow(
+		`SELECT username, password_hash FROM users WHERE LOWER(username) = LOWER($1)`,
+		username,
+	).Scan(&registered, &hash)
+	return registered, hash, err
+}
+
+func (u *Users) register(username, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	_, err = u.db.Exec(
+		`INSERT INTO users (username, password_hash) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+		username, hash,
+	)
+	return err
+}
@@ -0,0 +1,91 @@
+package bot
+
+import (
+	"connect-four/game"
+	"fmt"
+)
+
+// Blunder is a move by the losing player where the bot would have played
+// differently and scores its own choice higher
+type Blunder struct {
+	MoveNumber  int    `json:"moveNumber"`
+	Column      int    `json:"column"`
+	BestColumn  int    `json:"bestColumn"`
+	Reason      string `json:"reason"`
+	PlayedScore int    `json:"playedScore"`
+	BestScore   int    `json:"bestScore"`
+}
+
+// Analysis reviews the losing player's moves of a finished game
+type Analysis struct {
+	GameID   string    `json:"gameId"`
+	Loser    string    `json:"loser"`
+	Blunders []Blunder `json:"blunders"`
+}
+
+// AnalyzeGame replays a saved game and checks each of the loser's moves
+// against ExplainMove. A missed win or block is always a blunder; otherwise a
+// move is a blunder if the bot's column scores higher than the one played.
+func AnalyzeGame(saved *game.SavedGame) (*Analysis, error) {
+	loserID, loserName := saved.Loser()
+	if loserID == "" {
+		return nil, fmt.Errorf("game has no losing player")
+	}
+
+	board := saved.InitialBoard()
+	analysis := &Analysis{GameID: saved.ID, Loser: loserName, Blunders: []Blunder{}}
+	for i, move := range saved.Moves {
+		// Pop outs are outside what ExplainMove considers
+		if move.Player == loserID && !move.Pop {
+			if blunder := reviewMove(saved.Dimensions, board, move, saved.Winner); blunder != nil {
+				blunder.MoveNumber = i + 1
+				analysis.Blunders = append(analysis.Blunders, *blunder)
+			}
+		}
+
+		if result := game.ReplayMove(board, move); !result.Success {
+			return nil, fmt.Errorf("replaying move %d in column %d: %s", i+1, move.Column, result.Message)
+		}
+	}
+
+	return analysis, nil
+}
+
+func reviewMove(dimensions game.Dimensions, board [][]interface{}, move game.Move, opponentID string) *Blunder {
+	best := ExplainMove(dimensions, board, move.Player, opponentID)
+	if best == nil || best.Column == move.Column {
+		return nil
+	}
+
+	var playedScore, bestScore int
+	for _, candidate := range best.Candidates {
+		if candidate.Column == move.Column {
+			playedScore = candidate.Score
+		}
+		if candidate.Column == best.Column {
+			bestScore = candidate.Score
+		}
+	}
+
+	reason := best.Reason
+	switch best.Reason {
+	case ReasonWin:
+		reason = "missedWin"
+	case ReasonBlock:
+		reason = "missedBlock"
+	case ReasonFork:
+		reason = "missedFork"
+	default:
+		if bestScore <= playedScore {
+			return nil
+		}
+	}
+
+	return &Blunder{
+		Column:      move.Column,
+		BestColumn:  best.Column,
+		Reason:      reason,
+		PlayedScore: playedScore,
+		BestScore:   bestScore,
+	}
+}
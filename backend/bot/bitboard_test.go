@@ -0,0 +1,123 @@
+package bot
+
+import (
+	"connect-four/game"
+	"math/rand"
+	"testing"
+)
+
+// midgameColumns reaches a position with 14 discs and no four in a row
+var midgameColumns = []int{3, 3, 2, 4, 4, 2, 5, 1, 1, 5, 0, 6, 6, 0}
+
+// playColumns drops discs in the given columns, alternating "a" and "b"
+func playColumns(tb testing.TB, columns []int) [][]interface{} {
+	tb.Helper()
+	board := game.CreateBoard()
+	for i, col := range columns {
+		player := []string{"a", "b"}[i%2]
+		result := game.MakeMove(board, col, player)
+		if !result.Success {
+			tb.Fatalf("move %d in column %d: %s", i, col, result.Message)
+		}
+		if game.CheckWin(board, result.Row, col).Won {
+			tb.Fatalf("move %d in column %d wins", i, col)
+		}
+	}
+	return board
+}
+
+// countWinsBitboard counts the lines of play up to depth plies that end in
+// a win, with mine to move
+func countWinsBitboard(mine, theirs uint64, depth int) int {
+	if depth == 0 {
+		return 0
+	}
+	wins := 0
+	for col := 0; col < game.COLS; col++ {
+		move := standard.MoveBit(mine|theirs, col)
+		if move == 0 {
+			continue
+		}
+		if standard.HasLine(mine | move) {
+			wins++
+			continue
+		}
+		wins += countWinsBitboard(theirs, mine|move, depth-1)
+	}
+	return wins
+}
+
+// countWinsBoard is countWinsBitboard on the interface{} board, the way the
+// search worked before bitboards
+func countWinsBoard(board [][]interface{}, toMove, other interface{}, depth int) int {
+	if depth == 0 {
+		return 0
+	}
+	wins := 0
+	for _, col := range game.GetValidMoves(board) {
+		child := copyBoard(board)
+		result := game.MakeMove(child, col, toMove)
+		if game.CheckWin(child, result.Row, col).Won {
+			wins++
+			continue
+		}
+		wins += countWinsBoard(child, other, toMove, depth-1)
+	}
+	return wins
+}
+
+func TestHasLineMatchesCheckWin(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		board := game.CreateBoard()
+		players := []string{"a", "b"}
+		for turn := 0; ; turn++ {
+			moves := game.GetValidMoves(board)
+			if len(moves) == 0 {
+				break
+			}
+			col := moves[rng.Intn(len(moves))]
+			mover := players[turn%2]
+			result := game.MakeMove(board, col, mover)
+
+			won := game.CheckWin(board, result.Row, col).Won
+			b := standard.NewBitboard(board, mover, players[(turn+1)%2])
+			if standard.HasLine(b.Discs[0]) != won {
+				t.Fatalf("game %d move %d: HasLine = %v, CheckWin = %v", i, turn, !won, won)
+			}
+			if won {
+				break
+			}
+		}
+	}
+}
+
+func TestBitboardSearchMatchesBoardSearch(t *testing.T) {
+	board := playColumns(t, midgameColumns)
+	b := standard.NewBitboard(board, "a", "b")
+	for depth := 1; depth <= 4; depth++ {
+		want := countWinsBoard(board, "a", "b", depth)
+		if got := countWinsBitboard(b.Discs[0], b.Discs[1], depth); got != want {
+			t.Errorf("depth %d: bitboard search found %d wins, board search %d", depth, got, want)
+		}
+	}
+}
+
+func BenchmarkMidgameSearchBitboard(b *testing.B) {
+	board := playColumns(b, midgameColumns)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bb := standard.NewBitboard(board, "a", "b")
+		countWinsBitboard(bb.Discs[0], bb.Discs[1], 5)
+	}
+}
+
+func BenchmarkMidgameSearchBoard(b *testing.B) {
+	board := playColumns(b, midgameColumns)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		countWinsBoard(board, "a", "b", 5)
+	}
+}
@@ -1,96 +1,199 @@
 package bot
 
 import (
+	"connect-four/config"
 	"connect-four/game"
+	"connect-four/metrics"
+	"log/slog"
+	"math/rand"
+	"os"
+	"time"
 )
 
-type Player struct{}
+// Player is the computer opponent. It always plays as game.BotID; name is
+// only the display name shown to the human.
+type Player struct {
+	name string
+	// drawByProof ends bot games early once ProvenDraw shows neither side
+	// can win (DRAW_BY_PROOF=true)
+	drawByProof bool
+	// searchDepth is how many plies the Hard bot looks ahead
+	// (BOT_SEARCH_DEPTH)
+	searchDepth int
+	tracker     DecisionTracker
+	// rng drives Easy's random moves and breaks ties between equally good
+	// columns; it is safe for concurrent use
+	rng *rand.Rand
+}
+
+// DecisionTracker receives the reasoning behind every bot move, e.g. to
+// publish it to the analytics stream
+type DecisionTracker interface {
+	TrackBotDecision(g *game.Game, explanation *MoveExplanation)
+}
+
+// MoveExplanation describes why the bot picked a column. Candidates holds
+// the heuristic score of every legal column, even when the choice was forced
+// by a win or a block.
+type MoveExplanation struct {
+	Column     int              `json:"column"`
+	Reason     string           `json:"reason"`
+	Candidates []CandidateScore `json:"candidates"`
+}
+
+type CandidateScore struct {
+	Column int `json:"column"`
+	Score  int `json:"score"`
+}
+
+// Reasons reported in MoveExplanation
+const (
+	ReasonBlock     = "block"
+	ReasonWin       = "win"
+	ReasonHeuristic = "heuristic"
+	ReasonFork      = "fork"
+)
 
-func NewPlayer() *Player {
-	return &Player{}
+// NewPlayer returns the bot with its randomness seeded from the clock
+func NewPlayer(tracker DecisionTracker) *Player {
+	return NewPlayerWithRand(tracker, rand.NewSource(time.Now().UnixNano()))
+}
+
+// NewPlayerWithRand returns the bot with its randomness drawn from source,
+// so a fixed seed makes its moves reproducible
+func NewPlayerWithRand(tracker DecisionTracker, source rand.Source) *Player {
+	name := os.Getenv("BOT_NAME")
+	if name == "" {
+		name = "Bot"
+	}
+	return &Player{
+		name:        name,
+		drawByProof: os.Getenv("DRAW_BY_PROOF") == "true",
+		searchDepth: config.GetEnvInt("BOT_SEARCH_DEPTH", DefaultSearchDepth),
+		tracker:     tracker,
+		rng:         rand.New(&lockedSource{src: source}),
+	}
+}
+
+// Name returns the bot's display name
+func (b *Player) Name() string {
+	return b.name
 }
 
 func (b *Player) MakeMove(g *game.Game, gameManager *game.Manager, notifyCallback func(*game.Game)) {
-	if g.Status != "active" || g.CurrentPlayer != "bot" {
+	// A move scheduled before the game ended must not act on it
+	if g.Context().Err() != nil || g.Status != "active" || g.CurrentPlayer != game.BotID {
 		return
 	}
 
 	opponentID := g.Player1.ID
-	botID := "bot"
+	botID := game.BotID
 
-	// Get valid moves
-	validMoves := game.GetValidMoves(g.Board)
-	if len(validMoves) == 0 {
+	if b.declareDrawIfProven(gameManager, g, botID, opponentID, notifyCallback) {
 		return
 	}
 
-	bestColumn := validMoves[0]
-	bestScore := -999999
-	blockingColumn := -1
-
-	// Strategy priority:
-	// 1. Check if bot can win
-	// 2. Check if opponent can win (block)
-	// 3. Make best strategic move
-
-	// First, check if opponent can win immediately (must block)
-	for _, col := range validMoves {
-		testBoard := copyBoard(g.Board)
-		moveResult := game.MakeMove(testBoard, col, opponentID)
-		if moveResult.Success {
-			winCheck := game.CheckWin(testBoard, moveResult.Row, col)
-			if winCheck.Won {
-				blockingColumn = col
-				break // Must block this
-			}
-		}
+	start := time.Now()
+	explanation := b.chooseMove(g.Dimensions, g.Board, botID, opponentID, Difficulty(g.Player2.BotDifficulty))
+	elapsed := time.Since(start)
+	metrics.BotMoveSeconds.Observe(elapsed.Seconds())
+	if explanation == nil {
+		return
 	}
+	slog.Debug("Bot chose move", "gameId", g.ID, "playerId", botID, "column", explanation.Column, "reason", explanation.Reason, "elapsedMs", elapsed.Milliseconds())
 
-	// If we found a blocking move, use it
-	if blockingColumn != -1 {
-		b.executeMove(gameManager, g, blockingColumn, notifyCallback)
-		return
+	if b.tracker != nil {
+		b.tracker.TrackBotDecision(g, explanation)
 	}
+	b.executeMove(gameManager, g, explanation.Column, notifyCallback)
+}
 
-	// Check if bot can win
-	for _, col := range validMoves {
-		testBoard := copyBoard(g.Board)
-		moveResult := game.MakeMove(testBoard, col, botID)
-		if !moveResult.Success {
-			continue
-		}
+// ExplainMove picks the bot's column for the given position on a board of
+// the given dimensions and reports the reasoning. It returns nil when there
+// is no legal move.
+//
+// Strategy priority:
+//  1. Block an immediate opponent win
+//  2. Take an immediate win
+//  3. Set up two winning moves at once (a fork), which can't both be blocked
+//  4. Play the best scoring move by EvaluatePosition, preferring the center,
+//     among those that don't hand the opponent a win or a fork of their own
+//
+// Of equally good columns, the leftmost is chosen.
+func ExplainMove(dimensions game.Dimensions, board [][]interface{}, botID, opponentID interface{}) *MoveExplanation {
+	return explainMove(dimensions, board, botID, opponentID, nil)
+}
 
-		winCheck := game.CheckWin(testBoard, moveResult.Row, col)
-		if winCheck.Won {
-			// Bot wins - make this move immediately
-			b.executeMove(gameManager, g, col, notifyCallback)
-			return
-		}
+// explainMove is ExplainMove, picking among equally good columns with rng,
+// or the leftmost if rng is nil
+func explainMove(dimensions game.Dimensions, board [][]interface{}, botID, opponentID interface{}, rng *rand.Rand) *MoveExplanation {
+	// Get valid moves
+	validMoves := game.GetValidMoves(board)
+	if len(validMoves) == 0 {
+		return nil
 	}
 
+	bestColumn := validMoves[0]
+	bestScore := -999999
+	bestSafety := safetyLosing + 1
+	// ties counts the columns as good as bestColumn seen so far
+	ties := 0
+	candidates := make([]CandidateScore, 0, len(validMoves))
+
 	// Evaluate all moves and pick the best
 	for _, col := range validMoves {
-		testBoard := copyBoard(g.Board)
+		testBoard := copyBoard(board)
 		moveResult := game.MakeMove(testBoard, col, botID)
 		if !moveResult.Success {
 			continue
 		}
 
 		// Score this move
-		score := game.EvaluatePosition(testBoard, botID, opponentID)
+		score := dimensions.EvaluatePosition(testBoard, botID, opponentID)
 
 		// Prefer center columns (better strategic position)
-		centerDistance := abs(col - 3)
-		score += (3 - centerDistance) * 5
-
-		if score > bestScore {
+		center := (dimensions.Cols - 1) / 2
+		centerDistance := abs(col - center)
+		score += (center - centerDistance) * 5
+
+		candidates = append(candidates, CandidateScore{Column: col, Score: score})
+		safety := moveSafety(dimensions, testBoard, botID, opponentID)
+		if safety < bestSafety || (safety == bestSafety && score > bestScore) {
+			bestSafety = safety
 			bestScore = score
 			bestColumn = col
+			ties = 1
+		} else if safety == bestSafety && score == bestScore && rng != nil {
+			// Each tied column ends up chosen with equal probability
+			ties++
+			if rng.Intn(ties) == 0 {
+				bestColumn = col
+			}
+		}
+	}
+
+	// First, check if opponent can win immediately (must block)
+	for _, col := range validMoves {
+		testBoard := copyBoard(board)
+		moveResult := game.MakeMove(testBoard, col, opponentID)
+		if moveResult.Success && dimensions.CheckWin(testBoard, moveResult.Row, col).Won {
+			return &MoveExplanation{Column: col, Reason: ReasonBlock, Candidates: candidates}
+		}
+	}
+
+	// Check if bot can win
+	for _, col := range validMoves {
+		testBoard := copyBoard(board)
+		moveResult := game.MakeMove(testBoard, col, botID)
+		if moveResult.Success && dimensions.CheckWin(testBoard, moveResult.Row, col).Won {
+			return &MoveExplanation{Column: col, Reason: ReasonWin, Candidates: candidates}
 		}
 	}
 
-	// Make the best move
-	b.executeMove(gameManager, g, bestColumn, notifyCallback)
+	if bestSafety == safetyFork {
+		return &MoveExplanation{Column: bestColumn, Reason: ReasonFork, Candidates: candidates}
+	}
+	return &MoveExplanation{Column: bestColumn, Reason: ReasonHeuristic, Candidates: candidates}
 }
 
 func (b *Player) executeMove(gameManager *game.Manager, g *game.Game, column int, notifyCallback func(*game.Game)) {
@@ -102,7 +205,29 @@ func (b *Player) executeMove(gameManager *game.Manager, g *game.Game, column int
 		if notifyCallback != nil {
 			notifyCallback(updatedGame)
 		}
+
+		if updatedGame.Status == "active" {
+			b.declareDrawIfProven(gameManager, updatedGame, updatedGame.Player1.ID, game.BotID, notifyCallback)
+		}
+	}
+}
+
+// declareDrawIfProven ends the game as drawn by proof when enabled and the
+// endgame solver shows no one can still win. The solver only handles the
+// standard board.
+func (b *Player) declareDrawIfProven(gameManager *game.Manager, g *game.Game, toMove, other string, notifyCallback func(*game.Game)) bool {
+	if !b.drawByProof || !g.Dimensions.IsStandard() || !ProvenDraw(g.Board, toMove, other) {
+		return false
+	}
+
+	drawnGame := gameManager.DeclareDrawByProof(g.ID)
+	if drawnGame == nil {
+		return false
+	}
+	if notifyCallback != nil {
+		notifyCallback(drawnGame)
 	}
+	return true
 }
 
 func copyBoard(board [][]interface{}) [][]interface{} {
@@ -0,0 +1,66 @@
+package bot
+
+import (
+	"connect-four/game"
+	"math/rand"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// botToMove starts a game against the bot on a new manager and, if the
+// human was given the first move, plays it, so the bot is to move
+func botToMove(t *testing.T) (*game.Manager, *game.Game, *websocket.Conn) {
+	t.Helper()
+	m := game.NewManager(game.NewMemoryStore(), nil, game.Options{})
+	conn := &websocket.Conn{}
+	g := m.CreateGame(
+		&game.Player{ID: "p1", Username: "alice", Conn: conn},
+		&game.Player{ID: game.BotID, Username: "Bot", IsBot: true},
+	)
+	if g.CurrentPlayer != game.BotID {
+		result := m.MakeMove(g.ID, 0, conn)
+		if !result.Success {
+			t.Fatalf("human move failed: %s", result.Message)
+		}
+		g = result.Game
+	}
+	return m, g, conn
+}
+
+func TestMakeMovePlaysOnAnActiveGame(t *testing.T) {
+	m, g, _ := botToMove(t)
+	b := NewPlayerWithRand(nil, rand.NewSource(1))
+
+	var notified *game.Game
+	b.MakeMove(g, m, func(updated *game.Game) { notified = updated })
+
+	if notified == nil || len(notified.Moves) != len(g.Moves)+1 {
+		t.Fatalf("bot didn't move: notified with %+v", notified)
+	}
+}
+
+func TestFinishedGameIgnoresALateBotCallback(t *testing.T) {
+	m, g, conn := botToMove(t)
+	b := NewPlayerWithRand(nil, rand.NewSource(1))
+
+	// The human resigns while the bot's move is still scheduled on g
+	if result := m.Resign(g.ID, conn); !result.Success {
+		t.Fatalf("resign failed: %s", result.Message)
+	}
+	if g.Context().Err() == nil {
+		t.Fatal("game context not cancelled when the game ended")
+	}
+
+	b.MakeMove(g, m, func(updated *game.Game) {
+		t.Errorf("late bot move notified players: %+v", updated)
+	})
+
+	final := g.Final()
+	if final == nil || final.Status != "finished" || final.Winner != game.BotID {
+		t.Fatalf("final game = %+v, want the resignation to stand", final)
+	}
+	if len(final.Moves) != len(g.Moves) {
+		t.Errorf("final game has %d moves, want %d", len(final.Moves), len(g.Moves))
+	}
+}
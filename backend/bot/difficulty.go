@@ -0,0 +1,71 @@
+package bot
+
+import "connect-four/game"
+
+// Difficulty sets how strongly the bot plays. It is chosen per game and kept
+// on the bot's game.Player.
+type Difficulty string
+
+const (
+	// DifficultyEasy mostly plays random legal moves
+	DifficultyEasy Difficulty = "easy"
+	// DifficultyMedium blocks, then wins, then plays the best heuristic move
+	DifficultyMedium Difficulty = "medium"
+	// DifficultyHard searches ahead with minimax (BOT_SEARCH_DEPTH plies)
+	DifficultyHard Difficulty = "hard"
+)
+
+// easyRandomMoveRate is the share of Easy moves picked at random; the rest
+// use the Medium logic so Easy still takes an obvious win now and then
+const easyRandomMoveRate = 0.7
+
+// Reasons reported in MoveExplanation by the Easy and Hard bots
+const (
+	ReasonRandom = "random"
+	ReasonSearch = "search"
+)
+
+// ParseDifficulty accepts "easy", "medium" or "hard"; an empty value means
+// DifficultyMedium, the bot's original behavior
+func ParseDifficulty(value string) (Difficulty, bool) {
+	switch Difficulty(value) {
+	case "", DifficultyMedium:
+		return DifficultyMedium, true
+	case DifficultyEasy, DifficultyHard:
+		return Difficulty(value), true
+	default:
+		return "", false
+	}
+}
+
+// chooseMove picks the bot's column at the given difficulty. Candidates are
+// always the heuristic scores from ExplainMove, so explanations stay
+// comparable across difficulties. Medium and Hard play from the opening book
+// while the position is in it.
+func (b *Player) chooseMove(dimensions game.Dimensions, board [][]interface{}, botID, opponentID interface{}, difficulty Difficulty) *MoveExplanation {
+	explanation := explainMove(dimensions, board, botID, opponentID, b.rng)
+	if explanation == nil {
+		return nil
+	}
+
+	if difficulty != DifficultyEasy && explanation.Reason == ReasonHeuristic {
+		if column, ok := bookMove(dimensions, board, botID, opponentID); ok {
+			explanation.Column = column
+			explanation.Reason = ReasonBook
+			return explanation
+		}
+	}
+
+	switch difficulty {
+	case DifficultyEasy:
+		if b.rng.Float64() < easyRandomMoveRate {
+			validMoves := game.GetValidMoves(board)
+			explanation.Column = validMoves[b.rng.Intn(len(validMoves))]
+			explanation.Reason = ReasonRandom
+		}
+	case DifficultyHard:
+		explanation.Column = searchMove(dimensions, board, botID, opponentID, b.searchDepth)
+		explanation.Reason = ReasonSearch
+	}
+	return explanation
+}
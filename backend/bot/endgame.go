@@ -0,0 +1,50 @@
+package bot
+
+import (
+	"connect-four/game"
+)
+
+// EndgameSolverMaxEmpty bounds the exhaustive endgame search. Positions with
+// more empty cells are never reported as proven draws.
+const EndgameSolverMaxEmpty = 12
+
+// searchOrder tries central columns first, where wins are most often found
+var searchOrder = game.CenterOutColumns()
+
+// standard is the board the endgame solver and opening book work on
+var standard = game.StandardDimensions
+
+// ProvenDraw reports whether no sequence of legal moves from this position,
+// starting with toMove, can produce four in a row for either player.
+func ProvenDraw(board [][]interface{}, toMove, other interface{}) bool {
+	if game.MovesRemaining(board) > EndgameSolverMaxEmpty {
+		return false
+	}
+	b := standard.NewBitboard(board, toMove, other)
+	return !canAnyoneWin(b.Discs[0], b.Discs[1], make(map[[2]uint64]bool))
+}
+
+// canAnyoneWin searches every continuation with mine to move. Positions are
+// memoized by (mine, theirs), which also encodes whose turn it is.
+func canAnyoneWin(mine, theirs uint64, seen map[[2]uint64]bool) bool {
+	key := [2]uint64{mine, theirs}
+	if result, ok := seen[key]; ok {
+		return result
+	}
+
+	result := false
+	mask := mine | theirs
+	for _, col := range searchOrder {
+		move := standard.MoveBit(mask, col)
+		if move == 0 {
+			continue
+		}
+		if standard.HasLine(mine|move) || canAnyoneWin(theirs, mine|move, seen) {
+			result = true
+			break
+		}
+	}
+
+	seen[key] = result
+	return result
+}
@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"connect-four/game"
+)
+
+// DefaultSearchDepth is how many plies the Hard bot looks ahead unless
+// BOT_SEARCH_DEPTH says otherwise
+const DefaultSearchDepth = 5
+
+// winScore outweighs any EvaluatePosition score. Wins and losses add the
+// remaining depth so the bot prefers faster wins and slower losses.
+const winScore = 1000000
+
+// search scores positions from the bot's point of view
+type search struct {
+	dimensions game.Dimensions
+	botID      interface{}
+	opponentID interface{}
+}
+
+// searchMove returns the bot's best column by alpha-beta minimax to the given
+// depth. Columns are tried center first, so equal scores go to the central
+// column and cutoffs come early.
+func searchMove(dimensions game.Dimensions, board [][]interface{}, botID, opponentID interface{}, depth int) int {
+	s := &search{dimensions: dimensions, botID: botID, opponentID: opponentID}
+	validMoves := game.GetValidMovesCenterFirst(board)
+
+	alpha, beta := -2*winScore, 2*winScore
+	bestColumn := validMoves[0]
+	for _, col := range validMoves {
+		if score := s.scoreMove(board, col, depth, alpha, beta, true); score > alpha {
+			bestColumn, alpha = col, score
+		}
+	}
+	return bestColumn
+}
+
+// scoreMove plays col for the bot (maximizing) or the opponent and scores
+// the result, searching depth-1 further plies unless the move wins
+func (s *search) scoreMove(board [][]interface{}, col, depth, alpha, beta int, maximizing bool) int {
+	mover := s.opponentID
+	if maximizing {
+		mover = s.botID
+	}
+
+	child := copyBoard(board)
+	result := game.MakeMove(child, col, mover)
+	if s.dimensions.CheckWin(child, result.Row, col).Won {
+		if maximizing {
+			return winScore + depth
+		}
+		return -winScore - depth
+	}
+	return s.minimax(child, depth-1, alpha, beta, !maximizing)
+}
+
+// minimax returns the value of board with the bot to move if maximizing,
+// using EvaluatePosition once depth runs out. Branches outside the
+// (alpha, beta) window can't change the result and are cut off.
+func (s *search) minimax(board [][]interface{}, depth, alpha, beta int, maximizing bool) int {
+	validMoves := game.GetValidMovesCenterFirst(board)
+	if depth <= 0 || len(validMoves) == 0 {
+		return s.dimensions.EvaluatePosition(board, s.botID, s.opponentID)
+	}
+
+	for _, col := range validMoves {
+		score := s.scoreMove(board, col, depth, alpha, beta, maximizing)
+		if maximizing && score > alpha {
+			alpha = score
+		} else if !maximizing && score < beta {
+			beta = score
+		}
+		if alpha >= beta {
+			break
+		}
+	}
+	if maximizing {
+		return alpha
+	}
+	return beta
+}
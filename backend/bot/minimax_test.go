@@ -0,0 +1,154 @@
+package bot
+
+import (
+	"connect-four/game"
+	"math/rand"
+	"testing"
+)
+
+// botBoard builds a board from rows given top to bottom and aligned to the
+// bottom: 'B' is the bot's disc, 'H' the human's and anything else empty
+func botBoard(rows ...string) [][]interface{} {
+	board := game.CreateBoard()
+	offset := game.ROWS - len(rows)
+	for i, row := range rows {
+		for col, cell := range row {
+			switch cell {
+			case 'B':
+				board[offset+i][col] = game.BotID
+			case 'H':
+				board[offset+i][col] = "p1"
+			}
+		}
+	}
+	return board
+}
+
+func TestSearchMoveFindsTheFork(t *testing.T) {
+	// Either end of the bot's pair on the bottom row makes an open three,
+	// which threatens both sides at once
+	board := botBoard(
+		"......H",
+		"..BB..H",
+	)
+	column := searchMove(game.StandardDimensions, board, game.BotID, "p1", DefaultSearchDepth)
+	if column != 1 && column != 4 {
+		t.Fatalf("searchMove = %d, want the fork at 1 or 4", column)
+	}
+
+	s := &search{dimensions: game.StandardDimensions, botID: game.BotID, opponentID: "p1"}
+	if score := s.scoreMove(board, column, DefaultSearchDepth, -2*winScore, 2*winScore, true); score < winScore {
+		t.Errorf("fork at %d scored %d, want a forced win", column, score)
+	}
+}
+
+func TestSearchMovePrefersTheFasterWin(t *testing.T) {
+	// Column 2 makes a fork on the bottom row that wins in three plies;
+	// column 0 wins now
+	board := botBoard(
+		"B.....H",
+		"B.....H",
+		"B..BB.H",
+	)
+	if column := searchMove(game.StandardDimensions, board, game.BotID, "p1", DefaultSearchDepth); column != 0 {
+		t.Errorf("searchMove = %d, want the immediate win in column 0", column)
+	}
+}
+
+func TestSearchMoveBlocksTheForkSetup(t *testing.T) {
+	// The human threatens to play 1 or 4 for an open three; the bot must
+	// take one of those cells itself
+	board := botBoard(
+		"......B",
+		"..HH..B",
+	)
+	column := searchMove(game.StandardDimensions, board, game.BotID, "p1", DefaultSearchDepth)
+	if column != 1 && column != 4 {
+		t.Errorf("searchMove = %d, want 1 or 4 to stop the fork", column)
+	}
+}
+
+// plainMinimax is minimax without pruning, the reference the alpha-beta
+// search must agree with
+func (s *search) plainMinimax(board [][]interface{}, depth int, maximizing bool) int {
+	validMoves := game.GetValidMoves(board)
+	if depth <= 0 || len(validMoves) == 0 {
+		return s.dimensions.EvaluatePosition(board, s.botID, s.opponentID)
+	}
+
+	best := 0
+	for i, col := range validMoves {
+		score := s.plainScoreMove(board, col, depth, maximizing)
+		if i == 0 || (maximizing && score > best) || (!maximizing && score < best) {
+			best = score
+		}
+	}
+	return best
+}
+
+func (s *search) plainScoreMove(board [][]interface{}, col, depth int, maximizing bool) int {
+	mover := s.opponentID
+	if maximizing {
+		mover = s.botID
+	}
+	child := copyBoard(board)
+	result := game.MakeMove(child, col, mover)
+	if game.CheckWin(child, result.Row, col).Won {
+		if maximizing {
+			return winScore + depth
+		}
+		return -winScore - depth
+	}
+	return s.plainMinimax(child, depth-1, !maximizing)
+}
+
+func TestSearchMoveMatchesPlainMinimax(t *testing.T) {
+	const depth = 4
+	rng := rand.New(rand.NewSource(1))
+	positions := [][][]interface{}{game.CreateBoard(), playColumns(t, midgameColumns)}
+	for len(positions) < 12 {
+		// Random play; positions where someone has already won are skipped
+		board := game.CreateBoard()
+		for i, n := 0, 6+rng.Intn(16); i < n; i++ {
+			moves := game.GetValidMoves(board)
+			game.MakeMove(board, moves[rng.Intn(len(moves))], []string{"a", "b"}[i%2])
+		}
+		if p1Won, p2Won := game.StandardDimensions.CheckAllWins(board, "a", "b"); !p1Won && !p2Won {
+			positions = append(positions, board)
+		}
+	}
+
+	s := &search{dimensions: game.StandardDimensions, botID: "a", opponentID: "b"}
+	for i, board := range positions {
+		// The first column, center out, with the best unpruned score
+		want, best := -1, 0
+		for _, col := range game.GetValidMovesCenterFirst(board) {
+			if score := s.plainScoreMove(board, col, depth, true); want < 0 || score > best {
+				want, best = col, score
+			}
+		}
+		if got := searchMove(game.StandardDimensions, board, "a", "b", depth); got != want {
+			t.Errorf("position %d: searchMove = %d, plain minimax picks %d (score %d)", i, got, want, best)
+		}
+	}
+}
+
+func TestMinimaxScoresTheLeafWithEvaluatePosition(t *testing.T) {
+	board := playColumns(t, midgameColumns)
+	s := &search{dimensions: game.StandardDimensions, botID: "a", opponentID: "b"}
+	want := game.EvaluatePosition(board, "a", "b")
+	for _, maximizing := range []bool{true, false} {
+		if got := s.minimax(board, 0, -2*winScore, 2*winScore, maximizing); got != want {
+			t.Errorf("depth 0, maximizing %v: minimax = %d, want EvaluatePosition's %d", maximizing, got, want)
+		}
+	}
+}
+
+func BenchmarkSearchMove(b *testing.B) {
+	board := playColumns(b, midgameColumns)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		searchMove(game.StandardDimensions, board, "a", "b", DefaultSearchDepth)
+	}
+}
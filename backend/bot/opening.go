@@ -0,0 +1,76 @@
+package bot
+
+import "connect-four/game"
+
+// ReasonBook is reported in MoveExplanation when the move came from the
+// opening book
+const ReasonBook = "book"
+
+// openingLines are the book positions, each given as the columns played from
+// an empty board (alternating, whoever moved first) and the reply for the
+// player to move. Mirror images are looked up too, so only one side of the
+// board is listed. The book takes the center, then stays on it or next to it.
+var openingLines = []struct {
+	moves []int
+	reply int
+}{
+	{nil, 3},
+	{[]int{3}, 3},
+	{[]int{2}, 3},
+	{[]int{1}, 3},
+	{[]int{0}, 3},
+	{[]int{3, 3}, 3},
+	{[]int{3, 2}, 4},
+	{[]int{3, 1}, 3},
+	{[]int{3, 0}, 3},
+}
+
+// openingBook maps normalized positions (see normalizeBitboard) to the book
+// reply in the same orientation
+var openingBook = buildOpeningBook()
+
+func buildOpeningBook() map[[2]uint64]int {
+	book := make(map[[2]uint64]int, len(openingLines))
+	for _, line := range openingLines {
+		var discs [2]uint64
+		for i, col := range line.moves {
+			discs[i%2] |= standard.MoveBit(discs[0]|discs[1], col)
+		}
+		toMove := len(line.moves) % 2
+		key, mirrored := normalizeBitboard(game.Bitboard{Discs: [2]uint64{discs[toMove], discs[1-toMove]}})
+		reply := line.reply
+		if mirrored {
+			reply = game.COLS - 1 - reply
+		}
+		book[key] = reply
+	}
+	return book
+}
+
+// bookMove returns the opening book's column for botID to play, if the
+// position is in the book. Only the standard board has a book.
+func bookMove(dimensions game.Dimensions, board [][]interface{}, botID, opponentID interface{}) (int, bool) {
+	if !dimensions.IsStandard() {
+		return 0, false
+	}
+	key, mirrored := normalizeBitboard(standard.NewBitboard(board, botID, opponentID))
+	column, ok := openingBook[key]
+	if !ok {
+		return 0, false
+	}
+	if mirrored {
+		column = game.COLS - 1 - column
+	}
+	return column, true
+}
+
+// normalizeBitboard picks one of a position and its mirror image as the key
+// for both, reporting whether it is the mirror image
+func normalizeBitboard(b game.Bitboard) ([2]uint64, bool) {
+	key := b.Discs
+	mirror := [2]uint64{standard.MirrorDiscs(key[0]), standard.MirrorDiscs(key[1])}
+	if mirror[0] < key[0] || (mirror[0] == key[0] && mirror[1] < key[1]) {
+		return mirror, true
+	}
+	return key, false
+}
@@ -0,0 +1,25 @@
+package bot
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// lockedSource lets one rand.Rand be shared by bot moves running
+// concurrently in different games
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
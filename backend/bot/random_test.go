@@ -0,0 +1,105 @@
+package bot
+
+import (
+	"connect-four/game"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// fullCenter has the center column filled, leaving columns 2 and 4 as
+// mirror images with equal scores
+func fullCenter() [][]interface{} {
+	return botBoard(
+		"...H...",
+		"...B...",
+		"...H...",
+		"...B...",
+		"...H...",
+		"...B...",
+	)
+}
+
+func TestTiesAreBrokenWithTheRand(t *testing.T) {
+	board := fullCenter()
+	if column := ExplainMove(game.StandardDimensions, board, game.BotID, "p1").Column; column != 2 {
+		t.Fatalf("ExplainMove = %d, want the leftmost of the tied columns, 2", column)
+	}
+
+	picked := make(map[int]int)
+	for seed := int64(0); seed < 40; seed++ {
+		first := explainMove(game.StandardDimensions, board, game.BotID, "p1", rand.New(rand.NewSource(seed)))
+		again := explainMove(game.StandardDimensions, board, game.BotID, "p1", rand.New(rand.NewSource(seed)))
+		if first.Column != again.Column {
+			t.Fatalf("seed %d picked %d then %d", seed, first.Column, again.Column)
+		}
+		if first.Column != 2 && first.Column != 4 {
+			t.Fatalf("seed %d picked %d, want one of the tied columns", seed, first.Column)
+		}
+		picked[first.Column]++
+	}
+	if len(picked) != 2 {
+		t.Errorf("tie-breaks over 40 seeds = %v, want both columns", picked)
+	}
+}
+
+// easyGame plays the seeded bot at Easy against itself from an empty board
+// and returns the columns it chose
+func easyGame(seed int64) []int {
+	b := NewPlayerWithRand(nil, rand.NewSource(seed))
+	board := game.CreateBoard()
+	players := []interface{}{game.BotID, "p1"}
+	var columns []int
+	for turn := 0; len(game.GetValidMoves(board)) > 0; turn++ {
+		mover, opponent := players[turn%2], players[(turn+1)%2]
+		explanation := b.chooseMove(game.StandardDimensions, board, mover, opponent, DifficultyEasy)
+		result := game.MakeMove(board, explanation.Column, mover)
+		columns = append(columns, explanation.Column)
+		if game.CheckWin(board, result.Row, explanation.Column).Won {
+			break
+		}
+	}
+	return columns
+}
+
+func TestSeededBotIsReproducible(t *testing.T) {
+	first, again := easyGame(7), easyGame(7)
+	if len(first) != len(again) {
+		t.Fatalf("seed 7 played %v then %v", first, again)
+	}
+	for i := range first {
+		if first[i] != again[i] {
+			t.Fatalf("seed 7 played %v then %v", first, again)
+		}
+	}
+
+	other := easyGame(8)
+	same := len(other) == len(first)
+	for i := 0; same && i < len(first); i++ {
+		same = first[i] == other[i]
+	}
+	if same {
+		t.Errorf("seeds 7 and 8 both played %v", first)
+	}
+}
+
+func TestSeededBotIsSafeForConcurrentGames(t *testing.T) {
+	// One bot serves every game, so its rand is shared across goroutines
+	b := NewPlayerWithRand(nil, rand.NewSource(1))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			board := fullCenter()
+			for j := 0; j < 50; j++ {
+				column := b.chooseMove(game.StandardDimensions, board, game.BotID, "p1", DifficultyEasy).Column
+				if column < 0 || column >= game.COLS || column == 3 {
+					t.Errorf("chose column %d", column)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,57 @@
+package bot
+
+import "connect-four/game"
+
+// How a bot move leaves the board, best first. A fork is two or more
+// winning moves at once; only one of them can be blocked.
+const (
+	safetyFork       = iota // the bot has a fork and the opponent can't win first
+	safetySafe              // nothing immediate either way
+	safetyAllowsFork        // the opponent has a reply that sets up a fork
+	safetyLosing            // the opponent can win straight away
+)
+
+// winningMoves counts the columns where player would complete a line by
+// dropping a disc now
+func winningMoves(dimensions game.Dimensions, board [][]interface{}, player interface{}) int {
+	count := 0
+	for _, col := range game.GetValidMoves(board) {
+		testBoard := copyBoard(board)
+		moveResult := game.MakeMove(testBoard, col, player)
+		if moveResult.Success && dimensions.CheckWin(testBoard, moveResult.Row, col).Won {
+			count++
+		}
+	}
+	return count
+}
+
+// moveSafety rates the board right after the bot has moved, with the
+// opponent to play
+func moveSafety(dimensions game.Dimensions, board [][]interface{}, botID, opponentID interface{}) int {
+	if winningMoves(dimensions, board, opponentID) > 0 {
+		return safetyLosing
+	}
+	if winningMoves(dimensions, board, botID) >= 2 {
+		return safetyFork
+	}
+	if allowsFork(dimensions, board, botID, opponentID) {
+		return safetyAllowsFork
+	}
+	return safetySafe
+}
+
+// allowsFork reports whether the opponent, to play on board, has a move that
+// leaves them two or more winning moves while the bot has none of its own to
+// answer with
+func allowsFork(dimensions game.Dimensions, board [][]interface{}, botID, opponentID interface{}) bool {
+	for _, col := range game.GetValidMoves(board) {
+		testBoard := copyBoard(board)
+		if !game.MakeMove(testBoard, col, opponentID).Success {
+			continue
+		}
+		if winningMoves(dimensions, testBoard, opponentID) >= 2 && winningMoves(dimensions, testBoard, botID) == 0 {
+			return true
+		}
+	}
+	return false
+}
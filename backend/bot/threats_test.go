@@ -0,0 +1,90 @@
+package bot
+
+import (
+	"connect-four/game"
+	"testing"
+)
+
+// sevenTrap is one move short of the "7" shape for the human: the bar on the
+// fourth row is missing its middle disc at column 2, and the stroke runs down
+// to the left from column 3. Filling column 2 threatens both ends of the bar.
+func sevenTrap() [][]interface{} {
+	return botBoard(
+		".H.H...",
+		"BBHBH..",
+		"BHBBH..",
+	)
+}
+
+// swapDiscs returns board with the bot's and the human's discs exchanged
+func swapDiscs(board [][]interface{}) [][]interface{} {
+	swapped := copyBoard(board)
+	for _, row := range swapped {
+		for col, cell := range row {
+			switch cell {
+			case game.BotID:
+				row[col] = "p1"
+			case "p1":
+				row[col] = game.BotID
+			}
+		}
+	}
+	return swapped
+}
+
+func TestWinningMovesCountsEveryThreat(t *testing.T) {
+	board := sevenTrap()
+	game.MakeMove(board, 2, "p1")
+	if got := winningMoves(game.StandardDimensions, board, "p1"); got != 2 {
+		t.Fatalf("winningMoves after completing the bar = %d, want 2", got)
+	}
+	if got := winningMoves(game.StandardDimensions, board, game.BotID); got != 0 {
+		t.Errorf("winningMoves for the bot = %d, want 0", got)
+	}
+}
+
+func TestMoveSafetyRatesTheSevenTrap(t *testing.T) {
+	tests := []struct {
+		column int
+		want   int
+	}{
+		{2, safetySafe},       // takes the bar's missing cell
+		{4, safetyLosing},     // lets the human finish the stroke at column 4
+		{6, safetyAllowsFork}, // leaves the bar to be completed
+	}
+	for _, tt := range tests {
+		board := sevenTrap()
+		game.MakeMove(board, tt.column, game.BotID)
+		if got := moveSafety(game.StandardDimensions, board, game.BotID, "p1"); got != tt.want {
+			t.Errorf("column %d: moveSafety = %d, want %d", tt.column, got, tt.want)
+		}
+	}
+}
+
+func TestExplainMovePreventsTheSevenTrap(t *testing.T) {
+	board := sevenTrap()
+	explanation := ExplainMove(game.StandardDimensions, board, game.BotID, "p1")
+	if explanation.Column != 2 {
+		t.Fatalf("ExplainMove = %d (%s), want 2 to break up the fork", explanation.Column, explanation.Reason)
+	}
+
+	game.MakeMove(board, explanation.Column, game.BotID)
+	if allowsFork(game.StandardDimensions, board, game.BotID, "p1") {
+		t.Error("the human still has a fork after the bot's move")
+	}
+}
+
+func TestExplainMoveSetsItsOwnSevenTrap(t *testing.T) {
+	// With the discs swapped the bot can fill the bar at column 2, or play
+	// column 4 to threaten the bar's gap and the top of the stroke at once
+	board := swapDiscs(sevenTrap())
+	explanation := ExplainMove(game.StandardDimensions, board, game.BotID, "p1")
+	if explanation.Reason != ReasonFork || (explanation.Column != 2 && explanation.Column != 4) {
+		t.Fatalf("ExplainMove = %d (%s), want a fork at 2 or 4", explanation.Column, explanation.Reason)
+	}
+
+	game.MakeMove(board, explanation.Column, game.BotID)
+	if got := winningMoves(game.StandardDimensions, board, game.BotID); got < 2 {
+		t.Errorf("the bot has %d winning moves after its fork, want at least 2", got)
+	}
+}
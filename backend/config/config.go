@@ -0,0 +1,206 @@
+package config
+
+import (
+	"connect-four/game"
+	"connect-four/matchmaking"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the effective server configuration after env overrides. The
+// exported JSON form is served by GET /api/config, so anything secret must
+// be tagged `json:"-"`.
+type Config struct {
+	BoardRows                  int `json:"boardRows"`
+	BoardCols                  int `json:"boardCols"`
+	WinLength                  int `json:"winLength"`
+	ReconnectWindowSeconds     int `json:"reconnectWindowSeconds"`
+	MoveClockSeconds           int `json:"moveClockSeconds"` // 0 means no move clock
+	BotMoveDelayMs             int `json:"botMoveDelayMs"`
+	MatchmakingTimeoutSeconds  int `json:"matchmakingTimeoutSeconds"`
+	QueueReconnectGraceSeconds int `json:"queueReconnectGraceSeconds"`
+	RequestCooldownMoves       int `json:"requestCooldownMoves"`
+	PrivateRoomTTLSeconds      int `json:"privateRoomTtlSeconds"`
+	RematchTimeoutSeconds      int `json:"rematchTimeoutSeconds"`
+	// MoveRules lists the training rules in force, e.g. "centerFirst"
+	MoveRules string `json:"moveRules"`
+	// PlayerDeltaUpdates sends players moveApplied deltas like spectators
+	// instead of a full gameState after every move
+	PlayerDeltaUpdates bool `json:"playerDeltaUpdates"`
+	// FirstMove is who starts a game between two humans when they didn't
+	// choose: "player1" (default, the player who was waiting), "challenger"
+	// or "coinFlip"
+	FirstMove string `json:"firstMove"`
+	// DiscLimit is the number of discs each player gets, 0 for unlimited
+	DiscLimit int `json:"discLimit"`
+	// PopOut enables the Pop Out variant; PopOutTieRule is "draw" or
+	// "moverLoses" for a pop out that completes lines for both players
+	PopOut        bool   `json:"popOut"`
+	PopOutTieRule string `json:"popOutTieRule"`
+	// ConfirmMoveTags lists the game tags, e.g. "tournament", whose games
+	// need intendMove + confirmMove instead of makeMove
+	ConfirmMoveTags string `json:"confirmMoveTags"`
+	// MoveClockAction is what happens when a player runs out of
+	// MoveClockSeconds: "forfeit" (default) or "randomMove"
+	MoveClockAction string `json:"moveClockAction"`
+
+	Port                string `json:"-"`
+	AdminToken          string `json:"-"`
+	MaxConnections      int    `json:"-"`
+	LogRejectedJoins    bool   `json:"-"`
+	BannedUsernames     string `json:"-"`
+	DrainTimeoutSeconds int    `json:"-"`
+	// LogLevel is the least severe level logged: debug, info, warn or error
+	LogLevel string `json:"-"`
+	// AuthSecret signs session tokens; without it a random secret is used
+	// and tokens don't survive a restart
+	AuthSecret        string `json:"-"`
+	AuthTokenTTLHours int    `json:"-"`
+	// HeartbeatIntervalSeconds is how often each connection is pinged (0
+	// disables it); one that doesn't answer with a pong for
+	// HeartbeatTimeoutSeconds is treated as disconnected
+	HeartbeatIntervalSeconds int `json:"-"`
+	HeartbeatTimeoutSeconds  int `json:"-"`
+	// Per-connection message limits a second (0 disables one): moves
+	// (makeMove, intendMove, confirmMove, popOut), chat, and every other
+	// message type
+	MoveRateLimit    int `json:"-"`
+	ChatRateLimit    int `json:"-"`
+	MessageRateLimit int `json:"-"`
+	// Every GameSweepIntervalSeconds (0 disables it), games nobody has been
+	// connected to for IdleGameTimeoutSeconds are abandoned or dropped
+	GameSweepIntervalSeconds int `json:"-"`
+	IdleGameTimeoutSeconds   int `json:"-"`
+	// DebugBot allows players to request botThinking messages; never enable
+	// it in production
+	DebugBot bool `json:"-"`
+}
+
+// Load reads the configuration from the environment
+func Load() *Config {
+	return &Config{
+		BoardRows:                  game.ROWS,
+		BoardCols:                  game.COLS,
+		WinLength:                  game.WIN_LENGTH,
+		ReconnectWindowSeconds:     GetEnvInt("RECONNECT_WINDOW_SECONDS", int(game.DefaultReconnectWindow/time.Second)),
+		MoveClockSeconds:           GetEnvInt("MOVE_CLOCK_SECONDS", 0),
+		BotMoveDelayMs:             GetEnvInt("BOT_MOVE_DELAY_MS", 500),
+		MatchmakingTimeoutSeconds:  GetEnvInt("MATCHMAKING_TIMEOUT_SECONDS", 10),
+		QueueReconnectGraceSeconds: int(matchmaking.QueueReconnectGrace / time.Second),
+		RequestCooldownMoves:       GetEnvInt("REQUEST_COOLDOWN_MOVES", game.DefaultRequestCooldownMoves),
+		PrivateRoomTTLSeconds:      GetEnvInt("PRIVATE_ROOM_TTL_SECONDS", int(matchmaking.DefaultRoomTTL/time.Second)),
+		RematchTimeoutSeconds:      GetEnvInt("REMATCH_TIMEOUT_SECONDS", int(game.DefaultRematchTimeout/time.Second)),
+		MoveRules:                  os.Getenv("MOVE_RULES"),
+		PlayerDeltaUpdates:         os.Getenv("PLAYER_DELTA_UPDATES") == "true",
+		FirstMove:                  getEnv("FIRST_MOVE", "player1"),
+		DiscLimit:                  GetEnvInt("DISC_LIMIT", 0),
+		PopOut:                     os.Getenv("POP_OUT") == "true",
+		PopOutTieRule:              getEnv("POP_OUT_TIE_RULE", game.PopOutTieDraw),
+		ConfirmMoveTags:            os.Getenv("CONFIRM_MOVE_TAGS"),
+		MoveClockAction:            getEnv("MOVE_CLOCK_ACTION", game.MoveClockForfeit),
+
+		Port:                getEnv("PORT", "3001"),
+		AdminToken:          os.Getenv("ADMIN_TOKEN"),
+		MaxConnections:      GetEnvInt("MAX_WS_CONNECTIONS", 1000),
+		LogRejectedJoins:    os.Getenv("LOG_REJECTED_JOINS") == "true",
+		BannedUsernames:     os.Getenv("BANNED_USERNAMES"),
+		DrainTimeoutSeconds: GetEnvInt("DRAIN_TIMEOUT_SECONDS", 60),
+		LogLevel:            getEnv("LOG_LEVEL", "info"),
+		AuthSecret:          os.Getenv("AUTH_SECRET"),
+		AuthTokenTTLHours:   GetEnvInt("AUTH_TOKEN_TTL_HOURS", 24),
+		DebugBot:            os.Getenv("DEBUG_BOT") == "true",
+
+		HeartbeatIntervalSeconds: GetEnvInt("HEARTBEAT_INTERVAL_SECONDS", 20),
+		HeartbeatTimeoutSeconds:  GetEnvInt("HEARTBEAT_TIMEOUT_SECONDS", 45),
+		MoveRateLimit:            GetEnvInt("MOVE_RATE_LIMIT", 10),
+		ChatRateLimit:            GetEnvInt("CHAT_RATE_LIMIT", 3),
+		MessageRateLimit:         GetEnvInt("MESSAGE_RATE_LIMIT", 20),
+		GameSweepIntervalSeconds: GetEnvInt("GAME_SWEEP_INTERVAL_SECONDS", int(game.DefaultSweepInterval/time.Second)),
+		IdleGameTimeoutSeconds:   GetEnvInt("IDLE_GAME_TIMEOUT_SECONDS", int(game.DefaultIdleGameTimeout/time.Second)),
+	}
+}
+
+func (c *Config) BotMoveDelay() time.Duration {
+	return time.Duration(c.BotMoveDelayMs) * time.Millisecond
+}
+
+func (c *Config) MatchmakingTimeout() time.Duration {
+	return time.Duration(c.MatchmakingTimeoutSeconds) * time.Second
+}
+
+func (c *Config) PrivateRoomTTL() time.Duration {
+	return time.Duration(c.PrivateRoomTTLSeconds) * time.Second
+}
+
+func (c *Config) RematchTimeout() time.Duration {
+	return time.Duration(c.RematchTimeoutSeconds) * time.Second
+}
+
+func (c *Config) AuthTokenTTL() time.Duration {
+	return time.Duration(c.AuthTokenTTLHours) * time.Hour
+}
+
+func (c *Config) ReconnectWindow() time.Duration {
+	return time.Duration(c.ReconnectWindowSeconds) * time.Second
+}
+
+func (c *Config) MoveClock() time.Duration {
+	return time.Duration(c.MoveClockSeconds) * time.Second
+}
+
+// ConfirmMoveTagList splits ConfirmMoveTags, ignoring blanks
+func (c *Config) ConfirmMoveTagList() []string {
+	var tags []string
+	for _, tag := range strings.Split(c.ConfirmMoveTags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func (c *Config) DrainTimeout() time.Duration {
+	return time.Duration(c.DrainTimeoutSeconds) * time.Second
+}
+
+func (c *Config) HeartbeatInterval() time.Duration {
+	return time.Duration(c.HeartbeatIntervalSeconds) * time.Second
+}
+
+func (c *Config) HeartbeatTimeout() time.Duration {
+	return time.Duration(c.HeartbeatTimeoutSeconds) * time.Second
+}
+
+func (c *Config) GameSweepInterval() time.Duration {
+	return time.Duration(c.GameSweepIntervalSeconds) * time.Second
+}
+
+func (c *Config) IdleGameTimeout() time.Duration {
+	return time.Duration(c.IdleGameTimeoutSeconds) * time.Second
+}
+
+func getEnv(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// GetEnvInt reads an integer env var, falling back to defaultValue when it is
+// unset or invalid
+func GetEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Warn("Invalid integer setting, using default", "key", key, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
@@ -0,0 +1,192 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ActiveGameRecord is the saved state of a game still in play, kept so it
+// can be restored after a restart. Moves is the number of moves played, so
+// a late write can't overwrite a newer state.
+type ActiveGameRecord struct {
+	ID    string
+	Moves int
+	State json.RawMessage
+}
+
+// activeGameState is what an ActiveGameRecord's State holds
+type activeGameState struct {
+	ID             string            `json:"id"`
+	Player1        activePlayerState `json:"player1"`
+	Player2        activePlayerState `json:"player2"`
+	Board          [][]interface{}   `json:"board"`
+	Dimensions     Dimensions        `json:"dimensions"`
+	CurrentPlayer  string            `json:"currentPlayer"`
+	Moves          []Move            `json:"moves"`
+	StartedAt      time.Time         `json:"startedAt"`
+	LastMoveAt     time.Time         `json:"lastMoveAt"`
+	StartingBoard  [][]interface{}   `json:"startingBoard,omitempty"`
+	Tags           []string          `json:"tags"`
+	DiscsRemaining map[string]int    `json:"discsRemaining,omitempty"`
+}
+
+type activePlayerState struct {
+	ID             string `json:"id"`
+	Username       string `json:"username"`
+	IsBot          bool   `json:"isBot"`
+	Seat           int    `json:"seat"`
+	Color          string `json:"color"`
+	ReconnectToken string `json:"reconnectToken,omitempty"`
+	BotDifficulty  string `json:"botDifficulty,omitempty"`
+}
+
+// snapshotActive captures the game for saveActive if it is still in play, or
+// returns nil. mu must be held.
+func (game *Game) snapshotActive() *ActiveGameRecord {
+	if game.Status != "active" {
+		return nil
+	}
+	state, err := json.Marshal(activeGameState{
+		ID:             game.ID,
+		Player1:        activePlayer(game.Player1),
+		Player2:        activePlayer(game.Player2),
+		Board:          game.Board,
+		Dimensions:     game.Dimensions,
+		CurrentPlayer:  game.CurrentPlayer,
+		Moves:          game.Moves,
+		StartedAt:      game.StartedAt,
+		LastMoveAt:     game.LastMoveAt,
+		StartingBoard:  game.StartingBoard,
+		Tags:           game.Tags,
+		DiscsRemaining: game.DiscsRemaining,
+	})
+	if err != nil {
+		slog.Error("Error capturing active game", "gameId", game.ID, "error", err)
+		return nil
+	}
+	return &ActiveGameRecord{ID: game.ID, Moves: len(game.Moves), State: state}
+}
+
+func activePlayer(player *Player) activePlayerState {
+	return activePlayerState{
+		ID:             player.ID,
+		Username:       player.Username,
+		IsBot:          player.IsBot,
+		Seat:           player.Seat,
+		Color:          player.Color,
+		ReconnectToken: player.ReconnectToken,
+		BotDifficulty:  player.BotDifficulty,
+	}
+}
+
+// saveActive writes a snapshot taken by snapshotActive. It writes to the
+// store, so mu must not be held.
+func (m *Manager) saveActive(record *ActiveGameRecord) {
+	if record == nil {
+		return
+	}
+	if err := m.store.SaveActiveGame(*record); err != nil {
+		slog.Error("Error saving active game", "gameId", record.ID, "error", err)
+	}
+}
+
+// dropActive removes a game that is no longer in play from the active games
+// store. mu must not be held.
+func (m *Manager) dropActive(gameID string) {
+	if err := m.store.DeleteActiveGame(gameID); err != nil {
+		slog.Error("Error removing active game", "gameId", gameID, "error", err)
+	}
+}
+
+// RestoreActiveGames reloads the games that were in play when the server
+// last stopped. Nobody is connected to them yet, so each gets a reconnect
+// window that either player can rejoin through; if neither does, the game is
+// abandoned. notifyCallback is called for games that end while waiting.
+// Games that were saved as finished in the meantime, and tournament games,
+// which the tournament starts afresh, are dropped.
+func (m *Manager) RestoreActiveGames(notifyCallback func(*Game)) ([]*Game, error) {
+	records, err := m.store.ActiveGames()
+	if err != nil {
+		return nil, err
+	}
+
+	var restored []*Game
+	for _, record := range records {
+		game, err := m.restoreGame(record)
+		if err != nil {
+			slog.Warn("Dropping active game", "gameId", record.ID, "error", err)
+			m.dropActive(record.ID)
+			continue
+		}
+
+		m.mu.Lock()
+		m.games[game.ID] = game
+		window := m.openReconnectWindow(game, "", notifyCallback)
+		m.trackReconnect(game, window, ReconnectDisconnected)
+		m.startTurnClock(game)
+		restored = append(restored, game.snapshot())
+		m.unlock()
+	}
+	return restored, nil
+}
+
+// restoreGame rebuilds a game from its active record, with both players
+// disconnected
+func (m *Manager) restoreGame(record ActiveGameRecord) (*Game, error) {
+	if saved, err := m.store.LoadGame(record.ID); err != nil {
+		return nil, err
+	} else if saved != nil {
+		return nil, fmt.Errorf("game has already been saved as %s", saved.Status)
+	}
+
+	var state activeGameState
+	if err := json.Unmarshal(record.State, &state); err != nil {
+		return nil, err
+	}
+	if hasTag(state.Tags, TagTournament) {
+		return nil, fmt.Errorf("tournament games are replayed by their tournament")
+	}
+	if err := m.validateLiveBoard(state.Dimensions, state.Board); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	game := &Game{
+		ID:             state.ID,
+		Player1:        restoredPlayer(state.Player1),
+		Player2:        restoredPlayer(state.Player2),
+		Board:          state.Board,
+		Dimensions:     state.Dimensions,
+		CurrentPlayer:  state.CurrentPlayer,
+		Status:         "active",
+		Moves:          state.Moves,
+		StartedAt:      state.StartedAt,
+		LastMoveAt:     state.LastMoveAt,
+		StartingBoard:  state.StartingBoard,
+		Tags:           state.Tags,
+		DiscsRemaining: state.DiscsRemaining,
+		ctx:            ctx,
+		cancel:         cancel,
+		final:          &finalGame{},
+	}
+	if game.Moves == nil {
+		game.Moves = []Move{}
+	}
+	game.logEvent("restored", fmt.Sprintf("moves=%d", len(game.Moves)))
+	return game, nil
+}
+
+func restoredPlayer(state activePlayerState) *Player {
+	return &Player{
+		ID:             state.ID,
+		Username:       state.Username,
+		IsBot:          state.IsBot,
+		Seat:           state.Seat,
+		Color:          state.Color,
+		ReconnectToken: state.ReconnectToken,
+		BotDifficulty:  state.BotDifficulty,
+	}
+}
@@ -0,0 +1,95 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestActiveGamesSurviveARestart(t *testing.T) {
+	store := NewMemoryStore()
+	before := NewManager(store, nil, Options{})
+	conn1, conn2 := &websocket.Conn{}, &websocket.Conn{}
+	g := before.CreateGame(humans(conn1, conn2))
+	before.MakeMove(g.ID, 3, conn1)
+	played := before.MakeMove(g.ID, 4, conn2).Game
+
+	records, _ := store.ActiveGames()
+	if len(records) != 1 || records[0].ID != g.ID || records[0].Moves != 2 {
+		t.Fatalf("active records = %+v, want the game after 2 moves", records)
+	}
+
+	after := NewManager(store, nil, Options{})
+	restored, err := after.RestoreActiveGames(nil)
+	if err != nil || len(restored) != 1 {
+		t.Fatalf("RestoreActiveGames = %d games, %v", len(restored), err)
+	}
+	r := restored[0]
+	if r.ID != g.ID || r.Status != "active" || r.CurrentPlayer != played.CurrentPlayer || len(r.Moves) != 2 {
+		t.Errorf("restored game = %+v, want it as it was after 2 moves", r)
+	}
+	if BoardChecksum(r.Board, "p1", "p2") != BoardChecksum(played.Board, "p1", "p2") {
+		t.Errorf("restored board %v, want %v", r.Board, played.Board)
+	}
+	if r.Player1.Conn != nil || r.Player2.Conn != nil {
+		t.Error("restored players should start disconnected")
+	}
+	if after.ReconnectWindowCount() != 1 {
+		t.Fatalf("%d reconnect windows open, want 1", after.ReconnectWindowCount())
+	}
+
+	// Either player rejoins by game ID and can play on
+	rejoined := &websocket.Conn{}
+	if result := after.RejoinGame(rejoined, "alice", g.ID, played.Player1.ReconnectToken); !result.Success {
+		t.Fatalf("rejoin failed: %s", result.Message)
+	}
+	if result := after.MakeMove(g.ID, 3, rejoined); !result.Success {
+		t.Errorf("move after rejoining failed: %s", result.Message)
+	}
+}
+
+func TestFinishedGamesLeaveTheActiveStore(t *testing.T) {
+	store := NewMemoryStore()
+	m := NewManager(store, nil, Options{})
+	conn1, conn2 := &websocket.Conn{}, &websocket.Conn{}
+	g := m.CreateGame(humans(conn1, conn2))
+	for i := 0; i < 3; i++ {
+		m.MakeMove(g.ID, 0, conn1)
+		m.MakeMove(g.ID, 1, conn2)
+	}
+	m.SaveGame(m.MakeMove(g.ID, 0, conn1).Game)
+
+	if records, _ := store.ActiveGames(); len(records) != 0 {
+		t.Errorf("active records = %+v after the game finished", records)
+	}
+}
+
+func TestRestoreDropsGamesThatCantResume(t *testing.T) {
+	store := NewMemoryStore()
+	before := NewManager(store, nil, Options{})
+	conn1, conn2 := &websocket.Conn{}, &websocket.Conn{}
+	tournamentGame := before.CreateTournamentGame(humans(conn1, conn2))
+	before.MakeMove(tournamentGame.ID, 3, conn1)
+
+	// A game saved as finished whose active record was never removed
+	conn3, conn4 := &websocket.Conn{}, &websocket.Conn{}
+	finished := before.CreateGame(humans(conn3, conn4))
+	stale := before.MakeMove(finished.ID, 3, conn3).Game
+	record := stale.snapshotActive()
+	for i := 0; i < 2; i++ {
+		before.MakeMove(finished.ID, 0, conn4)
+		before.MakeMove(finished.ID, 3, conn3)
+	}
+	before.MakeMove(finished.ID, 0, conn4)
+	before.SaveGame(before.MakeMove(finished.ID, 3, conn3).Game)
+	store.SaveActiveGame(*record)
+
+	after := NewManager(store, nil, Options{})
+	restored, err := after.RestoreActiveGames(nil)
+	if err != nil || len(restored) != 0 {
+		t.Fatalf("RestoreActiveGames = %+v, %v, want nothing restored", restored, err)
+	}
+	if records, _ := store.ActiveGames(); len(records) != 0 {
+		t.Errorf("active records = %+v, want them dropped", records)
+	}
+}
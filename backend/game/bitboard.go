@@ -0,0 +1,131 @@
+package game
+
+import (
+	"math/bits"
+	"sync"
+)
+
+// Bitboard holds a board as one uint64 of discs per player. Column c takes
+// bits c*(Rows+1) up to c*(Rows+1)+Rows-1, bottom row first, with one spare
+// bit on top of each column so shifted lines never wrap into the next
+// column. Only boards with (Rows+1)*Cols <= 64 can be encoded, which
+// includes StandardDimensions. Searches that play many moves, such as the
+// bot's, work on the uint64s directly with MoveBit and HasLine.
+type Bitboard struct {
+	Discs [2]uint64
+}
+
+// fitsBitboard reports whether boards of this size fit in a bitboard. The
+// standard board takes 49 bits.
+func (d Dimensions) fitsBitboard() bool {
+	return (d.Rows+1)*d.Cols <= 64
+}
+
+// bit is the bitboard bit of the cell at (row, col)
+func (d Dimensions) bit(row, col int) uint64 {
+	return uint64(1) << uint(col*(d.Rows+1)+d.Rows-1-row)
+}
+
+// NewBitboard converts a board. Discs[0] holds first's discs and Discs[1]
+// second's; any other cell contents count as empty.
+func (d Dimensions) NewBitboard(board [][]interface{}, first, second interface{}) Bitboard {
+	var b Bitboard
+	for row := 0; row < d.Rows; row++ {
+		for col := 0; col < d.Cols; col++ {
+			switch board[row][col] {
+			case nil:
+			case first:
+				b.Discs[0] |= d.bit(row, col)
+			case second:
+				b.Discs[1] |= d.bit(row, col)
+			}
+		}
+	}
+	return b
+}
+
+// HasLine reports whether discs contain a run of WinLength in any direction.
+// The shifts step one cell vertically, horizontally and along both
+// diagonals.
+func (d Dimensions) HasLine(discs uint64) bool {
+	height := uint(d.Rows + 1)
+	for _, shift := range []uint{1, height, height - 1, height + 1} {
+		run := discs
+		for i := uint(1); i < uint(d.WinLength) && run != 0; i++ {
+			run &= discs >> (i * shift)
+		}
+		if run != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// columnMask is the bits of every cell in col
+func (d Dimensions) columnMask(col int) uint64 {
+	return (uint64(1)<<uint(d.Rows) - 1) << uint(col*(d.Rows+1))
+}
+
+// MoveBit returns the bit a disc dropped in col would take given the
+// occupied cells, the union of both players' discs, or 0 if the column is
+// full
+func (d Dimensions) MoveBit(occupied uint64, col int) uint64 {
+	column := d.columnMask(col)
+	if occupied&column == column {
+		return 0
+	}
+	return (occupied&column + uint64(1)<<uint(col*(d.Rows+1))) & column
+}
+
+// MirrorDiscs flips discs left to right
+func (d Dimensions) MirrorDiscs(discs uint64) uint64 {
+	var mirrored uint64
+	for col := 0; col < d.Cols; col++ {
+		column := discs & d.columnMask(col) >> uint(col*(d.Rows+1))
+		mirrored |= column << uint((d.Cols-1-col)*(d.Rows+1))
+	}
+	return mirrored
+}
+
+// lineMasksBySize caches lineMasks per Dimensions
+var lineMasksBySize sync.Map
+
+// lineMasks returns a mask for every run of WinLength cells that fits on
+// the board, the same runs EvaluatePosition scores
+func (d Dimensions) lineMasks() []uint64 {
+	if masks, ok := lineMasksBySize.Load(d); ok {
+		return masks.([]uint64)
+	}
+
+	var masks []uint64
+	for row := 0; row < d.Rows; row++ {
+		for col := 0; col < d.Cols; col++ {
+			for _, delta := range [][2]int{{0, 1}, {1, 0}, {1, 1}, {1, -1}} {
+				endRow, endCol := row+(d.WinLength-1)*delta[0], col+(d.WinLength-1)*delta[1]
+				if endRow >= d.Rows || endCol < 0 || endCol >= d.Cols {
+					continue
+				}
+				var mask uint64
+				for i := 0; i < d.WinLength; i++ {
+					mask |= d.bit(row+i*delta[0], col+i*delta[1])
+				}
+				masks = append(masks, mask)
+			}
+		}
+	}
+
+	lineMasksBySize.Store(d, masks)
+	return masks
+}
+
+// evaluateBitboard is EvaluatePosition on a bitboard with playerID's discs in
+// Discs[0]
+func (d Dimensions) evaluateBitboard(b Bitboard) int {
+	score := 0
+	for _, mask := range d.lineMasks() {
+		playerCount := bits.OnesCount64(b.Discs[0] & mask)
+		opponentCount := bits.OnesCount64(b.Discs[1] & mask)
+		score += d.scoreLine(playerCount, opponentCount, d.WinLength-playerCount-opponentCount)
+	}
+	return score
+}
@@ -0,0 +1,137 @@
+package game
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// referenceEvaluatePosition and referenceEvaluateLine are EvaluatePosition
+// as it was before bitboards, kept to check the bitboard scores against
+func referenceEvaluatePosition(d Dimensions, board [][]interface{}, playerID, opponentID interface{}) int {
+	score := 0
+	for row := 0; row < d.Rows; row++ {
+		for col := 0; col < d.Cols; col++ {
+			score += referenceEvaluateLine(d, board, row, col, 0, 1, playerID, opponentID)
+			score += referenceEvaluateLine(d, board, row, col, 1, 0, playerID, opponentID)
+			score += referenceEvaluateLine(d, board, row, col, 1, 1, playerID, opponentID)
+			score += referenceEvaluateLine(d, board, row, col, 1, -1, playerID, opponentID)
+		}
+	}
+	return score
+}
+
+func referenceEvaluateLine(d Dimensions, board [][]interface{}, startRow, startCol, deltaRow, deltaCol int, playerID, opponentID interface{}) int {
+	playerCount, opponentCount, emptyCount := 0, 0, 0
+	for i := 0; i < d.WinLength; i++ {
+		row, col := startRow+i*deltaRow, startCol+i*deltaCol
+		if row < 0 || row >= d.Rows || col < 0 || col >= d.Cols {
+			return 0
+		}
+		switch board[row][col] {
+		case playerID:
+			playerCount++
+		case opponentID:
+			opponentCount++
+		default:
+			emptyCount++
+		}
+	}
+
+	switch {
+	case opponentCount > 0 && playerCount > 0:
+		return 0
+	case playerCount == d.WinLength:
+		return 10000
+	case opponentCount == d.WinLength:
+		return -10000
+	case opponentCount == d.WinLength-1 && emptyCount == 1:
+		return -1000
+	case playerCount == d.WinLength-1 && emptyCount == 1:
+		return 1000
+	case playerCount == d.WinLength-2 && emptyCount == 2:
+		return 100
+	case opponentCount == d.WinLength-2 && emptyCount == 2:
+		return -100
+	}
+	return playerCount*10 - opponentCount*10
+}
+
+// referenceCheckAllWins is CheckAllWins as it was before bitboards
+func referenceCheckAllWins(d Dimensions, board [][]interface{}, player1, player2 interface{}) (p1Won, p2Won bool) {
+	for row := 0; row < d.Rows; row++ {
+		for col := 0; col < d.Cols; col++ {
+			if cell := board[row][col]; cell != nil && d.CheckWin(board, row, col).Won {
+				p1Won = p1Won || cell == player1
+				p2Won = p2Won || cell == player2
+			}
+		}
+	}
+	return p1Won, p2Won
+}
+
+// randomBoard drops discs for "a" and "b" in turn into random columns,
+// ignoring wins, until the given number of moves or the board is full
+func randomBoard(d Dimensions, rng *rand.Rand, moves int) [][]interface{} {
+	board := d.CreateBoard()
+	for i := 0; i < moves; i++ {
+		valid := GetValidMoves(board)
+		if len(valid) == 0 {
+			break
+		}
+		MakeMove(board, valid[rng.Intn(len(valid))], []string{"a", "b"}[i%2])
+	}
+	return board
+}
+
+func TestBitboardMatchesTheCellWalk(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	sizes := []Dimensions{
+		StandardDimensions,
+		{Rows: 4, Cols: 5, WinLength: 3},
+		{Rows: 6, Cols: 9, WinLength: 4},
+		{Rows: 7, Cols: 8, WinLength: 5},
+	}
+	for _, d := range sizes {
+		if !d.fitsBitboard() {
+			t.Fatalf("%dx%d doesn't fit a bitboard", d.Rows, d.Cols)
+		}
+		for i := 0; i < 2000; i++ {
+			board := randomBoard(d, rng, rng.Intn(d.Rows*d.Cols+1))
+			for _, players := range [][2]string{{"a", "b"}, {"b", "a"}} {
+				want := referenceEvaluatePosition(d, board, players[0], players[1])
+				if got := d.EvaluatePosition(board, players[0], players[1]); got != want {
+					t.Fatalf("%dx%d: EvaluatePosition for %s = %d, want %d on %v", d.Rows, d.Cols, players[0], got, want, board)
+				}
+			}
+			want1, want2 := referenceCheckAllWins(d, board, "a", "b")
+			if got1, got2 := d.CheckAllWins(board, "a", "b"); got1 != want1 || got2 != want2 {
+				t.Fatalf("%dx%d: CheckAllWins = %v, %v, want %v, %v on %v", d.Rows, d.Cols, got1, got2, want1, want2, board)
+			}
+		}
+	}
+}
+
+// midgameBoard is a 12-move position with no four in a row
+func midgameBoard() [][]interface{} {
+	board := CreateBoard()
+	for i, col := range []int{3, 3, 2, 4, 4, 2, 5, 1, 1, 5, 0, 6} {
+		MakeMove(board, col, []string{"a", "b"}[i%2])
+	}
+	return board
+}
+
+func BenchmarkEvaluatePosition(b *testing.B) {
+	board := midgameBoard()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		EvaluatePosition(board, "a", "b")
+	}
+}
+
+func BenchmarkEvaluatePositionCellWalk(b *testing.B) {
+	board := midgameBoard()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		referenceEvaluatePosition(StandardDimensions, board, "a", "b")
+	}
+}
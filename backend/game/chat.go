@@ -0,0 +1,53 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/gorilla/websocket"
+)
+
+// MaxChatLength is the longest chat message relayed, in characters
+const MaxChatLength = 200
+
+// ChatRecipients returns the username of the player on conn and the
+// connections their chat messages in the game go to: the opponent (unless it
+// is the bot) and every spectator
+func (m *Manager) ChatRecipients(gameID string, conn *websocket.Conn) (string, []*websocket.Conn, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	game, exists := m.games[gameID]
+	if !exists {
+		return "", nil, fmt.Errorf("game not found")
+	}
+	player := game.playerByConn(conn)
+	if player == nil {
+		return "", nil, fmt.Errorf("not a player in this game")
+	}
+
+	var recipients []*websocket.Conn
+	if opponent := game.Opponent(player); !opponent.IsBot && opponent.Conn != nil {
+		recipients = append(recipients, opponent.Conn)
+	}
+	recipients = append(recipients, game.Spectators...)
+	return player.Username, recipients, nil
+}
+
+// SanitizeChat strips control characters from text, trims surrounding space
+// and cuts it to MaxChatLength characters
+func SanitizeChat(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, text)
+	text = strings.TrimSpace(text)
+
+	if runes := []rune(text); len(runes) > MaxChatLength {
+		text = strings.TrimSpace(string(runes[:MaxChatLength]))
+	}
+	return text
+}
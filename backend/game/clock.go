@@ -0,0 +1,98 @@
+package game
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// What the move clock does when a player runs out of time
+const (
+	MoveClockForfeit    = "forfeit"
+	MoveClockRandomMove = "randomMove"
+)
+
+// OnTurnTimeout registers fn to hear about what the move clock did for a
+// player who ran out of time, in the form MakeMove returns it: the game lost
+// on time, or the random move played for them. fn runs with mu released;
+// like a MakeMove caller, it notifies the players and saves, and so scores, a
+// finished game. Set it before any game starts.
+func (m *Manager) OnTurnTimeout(fn func(*GameMoveResult)) {
+	m.turnTimeout = fn
+}
+
+// startTurnClock restarts the move clock for whoever is to move. The bot is
+// never on the clock. mu must be held.
+func (m *Manager) startTurnClock(game *Game) {
+	game.stopTurnClock()
+	if m.options.MoveClock <= 0 || game.Status != "active" || game.CurrentPlayer == BotID {
+		return
+	}
+
+	deadline := time.Now().Add(m.options.MoveClock)
+	game.TurnDeadline = &deadline
+	gameID, moves := game.ID, len(game.Moves)
+	game.turnTimer = time.AfterFunc(m.options.MoveClock, func() {
+		m.expireTurn(gameID, moves)
+	})
+}
+
+// stopTurnClock cancels the move clock. mu must be held.
+func (g *Game) stopTurnClock() {
+	if g.turnTimer != nil {
+		g.turnTimer.Stop()
+		g.turnTimer = nil
+	}
+	g.TurnDeadline = nil
+}
+
+// expireTurn acts on the player to move running out of time, unless the game
+// has moved on since the clock was started with moves played
+func (m *Manager) expireTurn(gameID string, moves int) {
+	result, _ := m.locked(func() *GameMoveResult {
+		game, exists := m.games[gameID]
+		if !exists || game.Status != "active" || len(game.Moves) != moves {
+			return &GameMoveResult{Success: false}
+		}
+		game.TurnDeadline = nil
+		game.turnTimer = nil
+
+		player := game.Player1
+		if game.CurrentPlayer != player.ID {
+			player = game.Player2
+		}
+
+		if m.options.MoveClockAction == MoveClockRandomMove {
+			if column, ok := m.randomMove(game, player); ok {
+				game.logEvent("moveClock", fmt.Sprintf("player=%s randomMove column=%d", player.ID, column))
+				return m.applyMove(game, player, column)
+			}
+		}
+
+		game.logEvent("moveClock", "player="+player.ID+" timeout")
+		game.finishWithResult(game.Opponent(player).ID, ResultTimeout)
+		m.closeReconnectWindow(gameID)
+		return &GameMoveResult{Success: true, Game: game}
+	})
+	if !result.Success {
+		return
+	}
+	if m.turnTimeout != nil {
+		m.turnTimeout(result)
+	}
+}
+
+// randomMove picks a column the player could legally drop a disc in, move
+// rules included. mu must be held.
+func (m *Manager) randomMove(game *Game, player *Player) (int, bool) {
+	var columns []int
+	for _, column := range GetValidMoves(game.Board) {
+		if m.checkMoveRules(game, player.ID, column) == nil {
+			columns = append(columns, column)
+		}
+	}
+	if len(columns) == 0 {
+		return 0, false
+	}
+	return columns[rand.Intn(len(columns))], true
+}
@@ -0,0 +1,104 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// turnClock returns the live game's deadline and whether its timer is set
+func turnClock(m *Manager, gameID string) (*time.Time, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	game := m.games[gameID]
+	return game.TurnDeadline, game.turnTimer != nil
+}
+
+func TestTurnClockRestartsOnEveryMoveAndStopsAtTheEnd(t *testing.T) {
+	m := newTestManager(Options{MoveClock: time.Hour, MoveClockAction: MoveClockForfeit})
+	conn1, conn2 := &websocket.Conn{}, &websocket.Conn{}
+	player1, player2 := humans(conn1, conn2)
+	g := m.CreateGame(player1, player2)
+
+	first, running := turnClock(m, g.ID)
+	if first == nil || !running || g.TurnDeadline == nil {
+		t.Fatal("no move clock for the first player")
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	result := m.MakeMove(g.ID, 0, conn1)
+	second, running := turnClock(m, g.ID)
+	if second == nil || !running || !second.After(*first) {
+		t.Fatalf("clock not restarted for the second player: %v then %v", first, second)
+	}
+	if result.Game.TurnDeadline == nil || !result.Game.TurnDeadline.Equal(*second) {
+		t.Errorf("result deadline = %v, want %v", result.Game.TurnDeadline, second)
+	}
+
+	// A timer left over from the first turn must not act on the second
+	m.expireTurn(g.ID, 0)
+	if snapshot := m.GetGame(g.ID); snapshot.Status != "active" {
+		t.Fatalf("stale timer ended the game: %s", snapshot.ResultType)
+	}
+
+	m.Resign(g.ID, conn2)
+	deadline, running := turnClock(m, g.ID)
+	if deadline != nil || running {
+		t.Errorf("clock still running after the game ended: %v", deadline)
+	}
+}
+
+func TestTurnClockSkipsTheBot(t *testing.T) {
+	m := newTestManager(Options{MoveClock: time.Hour, MoveClockAction: MoveClockForfeit})
+	human, bot := withBot(&websocket.Conn{})
+	g := m.CreateGameWithFirstMove(human, bot, StandardDimensions, FirstMovePlayer1)
+
+	m.MakeMove(g.ID, 3, human.Conn)
+	if deadline, running := turnClock(m, g.ID); deadline != nil || running {
+		t.Errorf("bot is on the clock until %v", deadline)
+	}
+	m.BotMakeMove(g.ID, 3)
+	if deadline, running := turnClock(m, g.ID); deadline == nil || !running {
+		t.Error("clock not restarted once the bot moved")
+	}
+}
+
+func TestTurnClockTimeout(t *testing.T) {
+	for _, action := range []string{MoveClockForfeit, MoveClockRandomMove} {
+		t.Run(action, func(t *testing.T) {
+			m := newTestManager(Options{MoveClock: 20 * time.Millisecond, MoveClockAction: action})
+			timeouts := make(chan *GameMoveResult, 1)
+			m.OnTurnTimeout(func(result *GameMoveResult) {
+				select {
+				case timeouts <- result:
+				default:
+				}
+			})
+			player1, player2 := humans(&websocket.Conn{}, &websocket.Conn{})
+			g := m.CreateGameWithFirstMove(player1, player2, StandardDimensions, FirstMovePlayer1)
+
+			var result *GameMoveResult
+			select {
+			case result = <-timeouts:
+			case <-time.After(2 * time.Second):
+				t.Fatal("the move clock never ran out")
+			}
+
+			switch action {
+			case MoveClockForfeit:
+				if result.Game.Status != "finished" || result.Game.Winner != player2.ID || result.Game.ResultType != ResultTimeout {
+					t.Errorf("got %s won by %q (%s), want a timeout win for %s", result.Game.Status, result.Game.Winner, result.Game.ResultType, player2.ID)
+				}
+			case MoveClockRandomMove:
+				if len(result.Game.Moves) != 1 || result.Game.Moves[0].Player != player1.ID {
+					t.Fatalf("moves = %v, want one random move for %s", result.Game.Moves, player1.ID)
+				}
+				if result.Game.CurrentPlayer != player2.ID || result.Game.TurnDeadline == nil {
+					t.Errorf("clock not handed to %s after the random move", player2.ID)
+				}
+				m.AbandonGame(g.ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package game
+
+import (
+	"github.com/gorilla/websocket"
+)
+
+// PendingMove is a move a player has announced with IntendMove but not yet
+// confirmed. Row is where the disc will land.
+type PendingMove struct {
+	PlayerID string
+	Column   int
+	Row      int
+}
+
+// requiresConfirmation reports whether moves in this game must be announced
+// with IntendMove and applied with ConfirmMove
+func (m *Manager) requiresConfirmation(game *Game) bool {
+	for _, tag := range m.options.ConfirmMoveTags {
+		for _, gameTag := range game.Tags {
+			if gameTag == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IntendMove validates a move without playing it and records it as the
+// player's pending move, replacing any earlier intention. The landing spot
+// is in the returned game's PendingMove.
+func (m *Manager) IntendMove(gameID string, column int, conn *websocket.Conn) *GameMoveResult {
+	m.mu.Lock()
+	defer m.unlock()
+
+	game, player, failure := m.checkHumanMove(gameID, column, conn)
+	if failure != nil {
+		return failure
+	}
+	if !m.requiresConfirmation(game) {
+		return &GameMoveResult{Success: false, Message: "This game doesn't use move confirmation"}
+	}
+
+	row := landingRow(game.Board, column)
+	if row < 0 {
+		return &GameMoveResult{Success: false, Message: "Column is full", Code: CodeColumnFull}
+	}
+
+	game.PendingMove = &PendingMove{PlayerID: player.ID, Column: column, Row: row}
+	return &GameMoveResult{Success: true, Game: game.snapshot()}
+}
+
+// ConfirmMove plays the pending move of the player on conn
+func (m *Manager) ConfirmMove(gameID string, conn *websocket.Conn) *GameMoveResult {
+	result, _ := m.locked(func() *GameMoveResult { return m.confirmMove(gameID, conn) })
+	return result
+}
+
+func (m *Manager) confirmMove(gameID string, conn *websocket.Conn) *GameMoveResult {
+	game, exists := m.games[gameID]
+	if !exists {
+		return &GameMoveResult{Success: false, Message: "Game not found", Code: CodeGameNotFound}
+	}
+	player := game.playerByConn(conn)
+	pending := game.PendingMove
+	if player == nil || pending == nil || pending.PlayerID != player.ID {
+		return &GameMoveResult{Success: false, Message: "No move to confirm", Code: "noPendingMove"}
+	}
+
+	game, player, failure := m.checkHumanMove(gameID, pending.Column, conn)
+	if failure != nil {
+		return failure
+	}
+	return m.applyMove(game, player, pending.Column)
+}
+
+// landingRow returns the row a disc dropped in column would land in, or -1
+// if the column is full
+func landingRow(board [][]interface{}, column int) int {
+	for row := len(board) - 1; row >= 0; row-- {
+		if board[row][column] == nil {
+			return row
+		}
+	}
+	return -1
+}
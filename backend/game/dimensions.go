@@ -0,0 +1,53 @@
+package game
+
+import (
+	"fmt"
+)
+
+// Limits on the board size and win length a game can ask for
+const (
+	MinBoardSize = 4
+	MaxBoardSize = 12
+	MinWinLength = 3
+)
+
+// Dimensions is the board size of a game and the run length it takes to
+// win. The package-level board functions play on StandardDimensions; games
+// with another size use the methods of their own Dimensions.
+type Dimensions struct {
+	Rows      int `json:"rows"`
+	Cols      int `json:"cols"`
+	WinLength int `json:"winLength"`
+}
+
+// StandardDimensions is classic Connect Four: 6 rows, 7 columns, four in a row
+var StandardDimensions = Dimensions{Rows: ROWS, Cols: COLS, WinLength: WIN_LENGTH}
+
+// Validate checks the size limits: MinBoardSize to MaxBoardSize rows and
+// columns, and a win length from MinWinLength up to the smaller of the two
+func (d Dimensions) Validate() error {
+	if d.Rows < MinBoardSize || d.Rows > MaxBoardSize {
+		return fmt.Errorf("rows must be between %d and %d", MinBoardSize, MaxBoardSize)
+	}
+	if d.Cols < MinBoardSize || d.Cols > MaxBoardSize {
+		return fmt.Errorf("cols must be between %d and %d", MinBoardSize, MaxBoardSize)
+	}
+	if d.WinLength < MinWinLength || d.WinLength > d.Rows || d.WinLength > d.Cols {
+		return fmt.Errorf("winLength must be between %d and the smaller of rows and cols", MinWinLength)
+	}
+	return nil
+}
+
+// IsStandard reports whether d is the classic 6x7, four in a row board
+func (d Dimensions) IsStandard() bool {
+	return d == StandardDimensions
+}
+
+// CreateBoard returns an empty board of this size
+func (d Dimensions) CreateBoard() [][]interface{} {
+	board := make([][]interface{}, d.Rows)
+	for i := range board {
+		board[i] = make([]interface{}, d.Cols)
+	}
+	return board
+}
@@ -0,0 +1,71 @@
+package game
+
+import (
+	"connect-four/socket"
+
+	"github.com/gorilla/websocket"
+)
+
+// SubscribeFeed registers conn for a "gameFinished" message whenever any game
+// ends. Unlike spectating, the feed carries only results, never moves.
+func (m *Manager) SubscribeFeed(conn *websocket.Conn) {
+	m.mu.Lock()
+	defer m.unlock()
+	m.feedSubscribers[conn] = true
+}
+
+// UnsubscribeFeed stops the feed for conn; unknown connections are ignored
+func (m *Manager) UnsubscribeFeed(conn *websocket.Conn) {
+	m.mu.Lock()
+	defer m.unlock()
+	delete(m.feedSubscribers, conn)
+}
+
+// broadcastGameFinished tells every feed subscriber how a game ended. winner
+// is a username, "draw", or empty for an abandoned game. Subscribers whose
+// connection can't be written to are dropped from the feed. mu must not be
+// held.
+func (m *Manager) broadcastGameFinished(game *Game, durationSeconds *int) {
+	m.mu.RLock()
+	subscribers := make([]*websocket.Conn, 0, len(m.feedSubscribers))
+	for conn := range m.feedSubscribers {
+		subscribers = append(subscribers, conn)
+	}
+	m.mu.RUnlock()
+
+	if len(subscribers) == 0 {
+		return
+	}
+
+	winner := game.Winner
+	switch winner {
+	case game.Player1.ID:
+		winner = game.Player1.Username
+	case game.Player2.ID:
+		winner = game.Player2.Username
+	}
+
+	msg := map[string]interface{}{
+		"type":            "gameFinished",
+		"gameId":          game.ID,
+		"player1":         game.Player1.Username,
+		"player2":         game.Player2.Username,
+		"winner":          winner,
+		"resultType":      game.ResultType,
+		"durationSeconds": durationSeconds,
+	}
+	var failed []*websocket.Conn
+	for _, conn := range subscribers {
+		if err := socket.WriteJSON(conn, msg); err != nil {
+			failed = append(failed, conn)
+		}
+	}
+
+	if len(failed) > 0 {
+		m.mu.Lock()
+		for _, conn := range failed {
+			delete(m.feedSubscribers, conn)
+		}
+		m.unlock()
+	}
+}
@@ -0,0 +1,31 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestFeedDropsSubscribersThatCannotBeWritten(t *testing.T) {
+	m := newTestManager(Options{})
+	watcher := dial(t)
+	// Never opened, so every write to it fails
+	gone := &websocket.Conn{}
+	m.SubscribeFeed(watcher.server)
+	m.SubscribeFeed(gone)
+
+	player1, player2 := humans(nil, nil)
+	g := m.CreateGame(player1, player2)
+	m.ForfeitGame(g.ID, player1.ID, nil)
+
+	msg := watcher.nextOfType(t, "gameFinished")
+	if msg["gameId"] != g.ID || msg["winner"] != "bob" {
+		t.Errorf("gameFinished = %v, want game %s won by bob", msg, g.ID)
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if !m.feedSubscribers[watcher.server] || m.feedSubscribers[gone] || len(m.feedSubscribers) != 1 {
+		t.Errorf("feed subscribers = %v, want only the open connection", m.feedSubscribers)
+	}
+}
@@ -0,0 +1,39 @@
+package game
+
+// FirstMove is a policy for who moves first in a game from an empty board.
+// player1 is the player who was already waiting, the host of a private room
+// or the human in a bot game; player2, the challenger, is the one who
+// completed the match. Rematches ignore the policy and swap the first mover.
+type FirstMove string
+
+const (
+	FirstMovePlayer1    FirstMove = "player1"
+	FirstMoveChallenger FirstMove = "challenger"
+	// FirstMoveCoinFlip picks the first mover with a seeded coin flip,
+	// recorded on the game as its CoinFlip
+	FirstMoveCoinFlip FirstMove = "coinFlip"
+)
+
+// ParseFirstMove reads a FIRST_MOVE value
+func ParseFirstMove(value string) (FirstMove, bool) {
+	switch policy := FirstMove(value); policy {
+	case FirstMovePlayer1, FirstMoveChallenger, FirstMoveCoinFlip:
+		return policy, true
+	}
+	return "", false
+}
+
+// firstMovePolicy returns the policy for a new game: the one its players
+// chose, else a coin flip for bot games, so neither the bot nor the human
+// always starts, else the server's FIRST_MOVE
+func (m *Manager) firstMovePolicy(chosen FirstMove, player1, player2 *Player) FirstMove {
+	switch {
+	case chosen != "":
+		return chosen
+	case player1.IsBot || player2.IsBot:
+		return FirstMoveCoinFlip
+	case m.options.FirstMove != "":
+		return m.options.FirstMove
+	}
+	return FirstMovePlayer1
+}
@@ -0,0 +1,132 @@
+package game
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestCoinFlipStarterMatchesTheSeed(t *testing.T) {
+	m := newTestManager(Options{FirstMove: FirstMoveCoinFlip})
+	starters := make(map[string]int)
+	for i := 0; i < 50; i++ {
+		g := m.CreateGame(humans(nil, nil))
+		if g.CoinFlip == nil {
+			t.Fatal("coin-flip game has no CoinFlip")
+		}
+
+		// Replaying the recorded seed as CoinFlip documents gives the starter
+		want := g.Player1.ID
+		if rand.New(rand.NewSource(g.CoinFlip.Seed)).Intn(2) == 1 {
+			want = g.Player2.ID
+		}
+		if g.CoinFlip.Starter != want {
+			t.Fatalf("seed %d: recorded starter %s, replay gives %s", g.CoinFlip.Seed, g.CoinFlip.Starter, want)
+		}
+		if g.CurrentPlayer != g.CoinFlip.Starter {
+			t.Fatalf("seed %d: %s moves first, but %s won the flip", g.CoinFlip.Seed, g.CurrentPlayer, g.CoinFlip.Starter)
+		}
+		starters[g.CoinFlip.Starter]++
+	}
+	if len(starters) != 2 {
+		t.Errorf("starters over 50 flips = %v, want both players", starters)
+	}
+}
+
+func TestFlipCoinIsReproducible(t *testing.T) {
+	player1, player2 := humans(nil, nil)
+	for seed := int64(0); seed < 20; seed++ {
+		first, second := flipCoin(seed, player1, player2), flipCoin(seed, player1, player2)
+		if *first != *second {
+			t.Fatalf("seed %d flipped %+v then %+v", seed, first, second)
+		}
+		if first.Seed != seed {
+			t.Fatalf("recorded seed %d, want %d", first.Seed, seed)
+		}
+	}
+}
+
+func TestFirstMovePolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		server FirstMove
+		chosen FirstMove
+		vsBot  bool
+		want   FirstMove
+	}{
+		{"server default", "", "", false, FirstMovePlayer1},
+		{"server challenger", FirstMoveChallenger, "", false, FirstMoveChallenger},
+		{"server coin flip", FirstMoveCoinFlip, "", false, FirstMoveCoinFlip},
+		{"chosen over the server", FirstMoveChallenger, FirstMovePlayer1, false, FirstMovePlayer1},
+		{"bot game", FirstMovePlayer1, "", true, FirstMoveCoinFlip},
+		{"bot game with a choice", "", FirstMoveChallenger, true, FirstMoveChallenger},
+	}
+	for _, tt := range tests {
+		m := newTestManager(Options{FirstMove: tt.server})
+		player1, player2 := humans(nil, nil)
+		if tt.vsBot {
+			player1, player2 = withBot(nil)
+		}
+		if got := m.firstMovePolicy(tt.chosen, player1, player2); got != tt.want {
+			t.Errorf("%s: firstMovePolicy = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEachPolicyPicksItsStarter(t *testing.T) {
+	m := newTestManager(Options{})
+	for policy, want := range map[FirstMove]string{FirstMovePlayer1: "p1", FirstMoveChallenger: "p2"} {
+		player1, player2 := humans(nil, nil)
+		g := m.CreateGameWithFirstMove(player1, player2, StandardDimensions, policy)
+		if g.CurrentPlayer != want || g.CoinFlip != nil {
+			t.Errorf("%s: %s moves first with coin flip %+v, want %s and no flip", policy, g.CurrentPlayer, g.CoinFlip, want)
+		}
+	}
+
+	// Left to the server, bot games flip a coin, so the human doesn't always
+	// start
+	starters := make(map[string]int)
+	for i := 0; i < 50; i++ {
+		g := m.CreateGame(withBot(nil))
+		if g.CoinFlip == nil || g.CurrentPlayer != g.CoinFlip.Starter {
+			t.Fatalf("bot game: %s moves first with coin flip %+v", g.CurrentPlayer, g.CoinFlip)
+		}
+		starters[g.CurrentPlayer]++
+	}
+	if len(starters) != 2 {
+		t.Errorf("bot game starters over 50 games = %v, want both", starters)
+	}
+}
+
+func TestRematchesAlternateTheFirstMover(t *testing.T) {
+	m := newTestManager(Options{FirstMove: FirstMoveChallenger})
+	conns := map[string]*websocket.Conn{"p1": {}, "p2": {}}
+	g := m.CreateGame(humans(conns["p1"], conns["p2"]))
+
+	for _, want := range []string{"p2", "p1", "p2"} {
+		if g.CurrentPlayer != want {
+			t.Fatalf("game %s: %s moves first, want %s", g.ID, g.CurrentPlayer, want)
+		}
+
+		// The starter takes column 0 four times while the other player
+		// stacks column 1
+		other := "p1"
+		if want == "p1" {
+			other = "p2"
+		}
+		for i := 0; i < 4; i++ {
+			m.MakeMove(g.ID, 0, conns[want])
+			if i < 3 {
+				m.MakeMove(g.ID, 1, conns[other])
+			}
+		}
+
+		m.RequestRematch(g.ID, conns["p1"])
+		result := m.RequestRematch(g.ID, conns["p2"])
+		if result.NewGame == nil {
+			t.Fatalf("game %s: rematch = %+v, want a new game", g.ID, result)
+		}
+		g = result.NewGame
+	}
+}
@@ -1,16 +1,22 @@
 package game
 
 import (
+	"connect-four/metrics"
+	"connect-four/socket"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
+	"math/rand"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
-	_ "github.com/lib/pq"
 )
 
 type Game struct {
@@ -18,6 +24,9 @@ type Game struct {
 	Player1      *Player
 	Player2      *Player
 	Board        [][]interface{}
+	// Dimensions is the board size and win length, StandardDimensions
+	// unless the players asked for a variant
+	Dimensions    Dimensions
 	CurrentPlayer string
 	Status       string
 	Winner       string
@@ -25,67 +34,626 @@ type Game struct {
 	StartedAt    time.Time
 	EndedAt      *time.Time
 	LastMoveAt   time.Time
+	Events       []GameEvent
+	// StartingBoard holds the handicap position the game started from, or nil
+	// for games that started from an empty board
+	StartingBoard [][]interface{}
+	Tags          []string
+	Spectators    []*websocket.Conn
+	ResultType    string
+	// DrawOfferBy is the ID of the player with a pending draw offer, or "".
+	// The offer is withdrawn when that player moves.
+	DrawOfferBy string
+	// UndoOfferBy is the ID of the player asking to take back their last
+	// move, or "". Any move played drops the request.
+	UndoOfferBy string
+	// PendingMove is the move awaiting ConfirmMove in games that require
+	// confirmation; any move played clears it
+	PendingMove *PendingMove
+	// lastRequests holds the move count at each player's last request of a
+	// kind, keyed "kind:playerID", for the request cooldown
+	lastRequests map[string]int
+	// DiscsRemaining counts each player's unplayed discs by player ID when a
+	// disc limit is in force, or is nil for unlimited discs
+	DiscsRemaining map[string]int
+	// CoinFlip records how the first player was chosen when coin-flip starts
+	// are enabled, or nil
+	CoinFlip *CoinFlip
+	// WinningCells holds the [row, col] of the four discs that won the game,
+	// or nil. Wins completed by a pop out are not recorded.
+	WinningCells [][2]int
+	// RematchBy is the ID of the player waiting for the opponent to agree
+	// to a rematch, or ""; rematchTimer expires the request
+	RematchBy    string
+	rematchTimer *time.Timer
+	// RematchGameID is the game the rematch started, once agreed
+	RematchGameID string
+	// playerLeft is set when a player disconnects after the game ended,
+	// which rules out a rematch
+	playerLeft bool
+	// settled is set once a finished game has been scored, after which its
+	// last move can no longer be taken back; see settle
+	settled bool
+	// TurnDeadline is when the player to move runs out of time under the
+	// move clock, or nil; turnTimer acts on it
+	TurnDeadline *time.Time
+	turnTimer    *time.Timer
+	// ctx is cancelled once the game ends so deferred work (bot moves,
+	// forfeit timers) can tell it must not touch the game any more
+	ctx    context.Context
+	cancel context.CancelFunc
+	// final is shared with the game's snapshots and holds the game as it
+	// ended; see Final
+	final *finalGame
 }
 
+type finalGame struct {
+	game *Game
+}
+
+// Context is cancelled when the game finishes or is abandoned
+func (g *Game) Context() context.Context {
+	return g.ctx
+}
+
+// Final returns the game as it ended, however old the snapshot it is called
+// on. It may only be called once Context is done.
+func (g *Game) Final() *Game {
+	return g.final.game
+}
+
+// end records the game's final state for Final and cancels its context. mu
+// must be held.
+func (g *Game) end() {
+	g.final.game = g.snapshot()
+	g.cancel()
+}
+
+// snapshot copies the game for reading once mu is released. The Manager
+// only ever hands out snapshots: the board, moves, players and everything
+// else a caller may read are copied, so they don't change under the caller
+// as play goes on. mu must be held.
+func (g *Game) snapshot() *Game {
+	s := *g
+	player1, player2 := *g.Player1, *g.Player2
+	s.Player1, s.Player2 = &player1, &player2
+
+	s.Board = copyBoard(g.Board)
+	if g.StartingBoard != nil {
+		s.StartingBoard = copyBoard(g.StartingBoard)
+	}
+	s.Moves = append(make([]Move, 0, len(g.Moves)), g.Moves...)
+	s.Events = append([]GameEvent(nil), g.Events...)
+	s.Tags = append([]string(nil), g.Tags...)
+	s.Spectators = append([]*websocket.Conn(nil), g.Spectators...)
+	s.WinningCells = append([][2]int(nil), g.WinningCells...)
+	if g.DiscsRemaining != nil {
+		s.DiscsRemaining = make(map[string]int, len(g.DiscsRemaining))
+		for playerID, discs := range g.DiscsRemaining {
+			s.DiscsRemaining[playerID] = discs
+		}
+	}
+	if g.PendingMove != nil {
+		pending := *g.PendingMove
+		s.PendingMove = &pending
+	}
+	if g.CoinFlip != nil {
+		flip := *g.CoinFlip
+		s.CoinFlip = &flip
+	}
+	if g.TurnDeadline != nil {
+		deadline := *g.TurnDeadline
+		s.TurnDeadline = &deadline
+	}
+	if g.EndedAt != nil {
+		endedAt := *g.EndedAt
+		s.EndedAt = &endedAt
+	}
+
+	// Bookkeeping only the live game uses
+	s.lastRequests = nil
+	s.rematchTimer = nil
+	s.turnTimer = nil
+	return &s
+}
+
+// Game tags describe how a game was formed and are stored with the game so
+// history and stats can separate e.g. ranked from casual play
+const (
+	TagRanked     = "ranked"
+	TagBot        = "bot"
+	TagHandicap   = "handicap"
+	TagPrivate    = "private"
+	TagTournament = "tournament"
+	// TagPractice marks bot games the player asked for straight away
+	// instead of waiting out matchmaking
+	TagPractice = "practice"
+)
+
+// AddTag attaches a tag to the game if it isn't already present
+func (g *Game) AddTag(tag string) {
+	for _, existing := range g.Tags {
+		if existing == tag {
+			return
+		}
+	}
+	g.Tags = append(g.Tags, tag)
+}
+
+// GameEvent is an entry in a game's in-memory debug log. The log lives only
+// as long as the game stays in the manager and is never persisted.
+type GameEvent struct {
+	Type      string    `json:"type"`
+	Detail    string    `json:"detail,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func (g *Game) logEvent(eventType, detail string) {
+	g.Events = append(g.Events, GameEvent{
+		Type:      eventType,
+		Detail:    detail,
+		Timestamp: time.Now(),
+	})
+	slog.Debug("Game event", "gameId", g.ID, "event", eventType, "detail", detail)
+}
+
+// Result types explain how a game ended
+const (
+	ResultWin          = "win"
+	ResultDraw         = "draw"
+	ResultForfeit      = "forfeit"
+	ResultAbandoned    = "abandoned"
+	ResultDrawnByProof = "drawnByProof"
+	ResultDrawAgreed   = "drawAgreed"
+	ResultOutOfDiscs   = "outOfDiscs"
+	ResultResigned     = "resigned"
+	// ResultTimeout is a loss on time under the move clock
+	ResultTimeout = "timeout"
+	// ResultDeadPosition is a draw declared once no line can be completed
+	ResultDeadPosition = "deadPosition"
+)
+
+func (g *Game) recordMove(playerID string, column, row int) {
+	now := time.Now()
+	g.Moves = append(g.Moves, Move{
+		Player:    playerID,
+		Column:    column,
+		Row:       row,
+		Timestamp: now,
+		OffsetMs:  now.Sub(g.StartedAt).Milliseconds(),
+	})
+	g.LastMoveAt = now
+	g.PendingMove = nil
+	g.UndoOfferBy = ""
+	g.withdrawDrawOffer(playerID)
+	if g.DiscsRemaining != nil {
+		g.DiscsRemaining[playerID]--
+	}
+	metrics.RecordMove(playerID == BotID)
+}
+
+// recordPop logs a Pop Out move; the popped disc goes back to the player
+func (g *Game) recordPop(playerID string, column int) {
+	now := time.Now()
+	g.Moves = append(g.Moves, Move{
+		Player:    playerID,
+		Column:    column,
+		Row:       len(g.Board) - 1,
+		Timestamp: now,
+		OffsetMs:  now.Sub(g.StartedAt).Milliseconds(),
+		Pop:       true,
+	})
+	metrics.RecordMove(playerID == BotID)
+	g.LastMoveAt = now
+	g.PendingMove = nil
+	g.UndoOfferBy = ""
+	g.withdrawDrawOffer(playerID)
+	if g.DiscsRemaining != nil {
+		g.DiscsRemaining[playerID]++
+	}
+}
+
+// switchTurn hands the move to the other player
+func (g *Game) switchTurn() {
+	if g.CurrentPlayer == g.Player1.ID {
+		if g.Player2.IsBot {
+			g.CurrentPlayer = BotID
+		} else {
+			g.CurrentPlayer = g.Player2.ID
+		}
+	} else {
+		g.CurrentPlayer = g.Player1.ID
+	}
+}
+
+// endIfOutOfDiscs ends the game as a draw when the player to move has no
+// discs left under the disc-limit variant, even though the board has room
+func (g *Game) endIfOutOfDiscs() bool {
+	if g.DiscsRemaining == nil || g.DiscsRemaining[g.CurrentPlayer] > 0 {
+		return false
+	}
+	g.finishWithResult("draw", ResultOutOfDiscs)
+	return true
+}
+
+// finish marks the game as finished with the given winner ID (or "draw")
+func (g *Game) finish(winner string) {
+	result := ResultWin
+	if winner == "draw" {
+		result = ResultDraw
+	}
+	g.finishWithResult(winner, result)
+}
+
+func (g *Game) finishWithResult(winner, result string) {
+	g.Status = "finished"
+	g.Winner = winner
+	g.ResultType = result
+	now := time.Now()
+	g.EndedAt = &now
+	g.logEvent("finished", "winner="+winner+" result="+result)
+	g.stopTurnClock()
+	g.end()
+	metrics.RecordGameEnd(result, now.Sub(g.StartedAt))
+}
+
+// BotID is the player ID used for the bot in every game. The bot's display
+// name is configurable, but board cells, CurrentPlayer and Winner always
+// refer to it by this sentinel.
+const BotID = "bot"
+
 type Player struct {
 	ID       string
 	Username string
 	Conn     *websocket.Conn
 	IsBot    bool
+	// Seat (1 or 2) and Color are assigned when the game is created and never
+	// change, so rendering doesn't depend on matching usernames
+	Seat  int
+	Color string
+	// ReconnectToken must be presented to rejoin the game. It is rotated
+	// after each of the player's moves and only the latest one is accepted.
+	ReconnectToken string
+	// DebugBot streams the bot's candidate evaluations to this player
+	// (development only, see EnableBotDebug)
+	DebugBot bool
+	// BotDifficulty is the bot's strength ("easy", "medium" or "hard") when
+	// this player is the bot; see bot.Difficulty
+	BotDifficulty string
+	// disconnected is set when Conn closes, until the player rejoins
+	disconnected bool
 }
 
+const (
+	ColorRed    = "red"
+	ColorYellow = "yellow"
+)
+
 type Move struct {
 	Player    string
 	Column    int
 	Row       int
 	Timestamp time.Time
+	// OffsetMs is the time since the game started, for replay and timing analysis
+	OffsetMs int64
+	// Pop marks a Pop Out move: the player's bottom disc in Column was removed
+	Pop bool
 }
 
 // Analytics interface to avoid circular dependency
 type Analytics interface {
 	TrackGameStart(game *Game)
-	TrackMove(game *Game, column, row int)
+	// TrackMove reports a move by the player with moverID and username;
+	// by the time it is called the turn may have passed to the opponent
+	TrackMove(game *Game, moverID, username string, column, row int)
 	TrackGameEnd(game *Game)
+	// TrackReconnect reports a player disconnecting and how their reconnect
+	// window closed; elapsed is the time since they disconnected and window
+	// how long they were given to rejoin
+	TrackReconnect(game *Game, playerID, outcome string, elapsed, window time.Duration)
 }
 
+// Manager owns the games in play.
+//
+// Locking: mu guards games, reconnectWindows, windowGeneration,
+// feedSubscribers and outbox, and also the state of every game in games, so
+// game fields only change with mu held. Reads take RLock, anything that
+// changes a map or a game takes Lock. Games never leave the Manager: callers
+// get a snapshot copied with mu held, which they can read freely. mu is never
+// held while calling back out of the Manager or writing to a connection:
+// messages are queued with send and go out when unlock releases mu, notify
+// callbacks run, and ended games are scored and saved, only after it has
+// been released. Services with a
+// lock of their own that call into the Manager (tournament.Service) take
+// their lock first; the Manager never calls them with mu held, since game
+// end hooks run on their own goroutine via context.AfterFunc.
 type Manager struct {
+	mu             sync.RWMutex
 	games          map[string]*Game
-	db             *sql.DB
+	store          Store
 	analyticsService Analytics
 	reconnectWindows map[string]*ReconnectWindow
+	// windowGeneration numbers reconnect windows as they open
+	windowGeneration uint64
+	options          Options
+	feedSubscribers  map[*websocket.Conn]bool
+	// outbox holds the messages queued by send until unlock
+	outbox []outgoingMessage
+	// turnTimeout hears about moves made by the move clock; see OnTurnTimeout
+	turnTimeout func(*GameMoveResult)
+}
+
+type outgoingMessage struct {
+	conn *websocket.Conn
+	msg  map[string]interface{}
+}
+
+// send queues msg for conn, to be written once mu is released. A nil conn is
+// ignored. mu must be held.
+func (m *Manager) send(conn *websocket.Conn, msg map[string]interface{}) {
+	if conn != nil {
+		m.outbox = append(m.outbox, outgoingMessage{conn: conn, msg: msg})
+	}
+}
+
+// unlock releases mu, then writes the messages queued with send while it was
+// held
+func (m *Manager) unlock() {
+	outbox := m.outbox
+	m.outbox = nil
+	m.mu.Unlock()
+
+	for _, out := range outbox {
+		socket.WriteJSON(out.conn, out.msg)
+	}
+}
+
+// Options tune game rules for a Manager
+type Options struct {
+	// RequestCooldownMoves limits how often a player can make requests such
+	// as draw offers of their opponent
+	RequestCooldownMoves int
+	// MoveRules are extra legality checks for training variants
+	MoveRules []MoveRule
+	// DiscLimit gives each player a fixed number of discs; running out ends
+	// the game as a draw. 0 means unlimited.
+	DiscLimit int
+	// PopOut allows the Pop Out variant's popOut moves
+	PopOut bool
+	// PopOutTieRule decides a pop out that completes lines for both players:
+	// PopOutTieDraw or PopOutTieMoverLoses
+	PopOutTieRule string
+	// FirstMove decides who moves first in games between two humans from an
+	// empty board when the players didn't choose; see firstMovePolicy
+	FirstMove FirstMove
+	// ConfirmMoveTags lists game tags (e.g. TagTournament) whose games take
+	// moves in two steps, IntendMove then ConfirmMove, to guard against
+	// misclicks
+	ConfirmMoveTags []string
+	// RematchTimeout is how long a rematch request waits for the opponent
+	RematchTimeout time.Duration
+	// MoveClock is how long a player has for each move; 0 means no limit.
+	// MoveClockAction (MoveClockForfeit or MoveClockRandomMove) decides what
+	// happens when it runs out.
+	MoveClock       time.Duration
+	MoveClockAction string
+	// ReconnectWindow is how long a disconnected player has to rejoin
+	// before forfeiting; 0 means DefaultReconnectWindow
+	ReconnectWindow time.Duration
+}
+
+// DefaultReconnectWindow is how long a disconnected player has to rejoin
+// before forfeiting, unless Options.ReconnectWindow says otherwise
+const DefaultReconnectWindow = 30 * time.Second
+
+// reconnectWindow is how long a disconnected player has to rejoin
+func (m *Manager) reconnectWindow() time.Duration {
+	if m.options.ReconnectWindow > 0 {
+		return m.options.ReconnectWindow
+	}
+	return DefaultReconnectWindow
 }
 
 type ReconnectWindow struct {
-	PlayerID  string
-	ExpiresAt time.Time
+	// PlayerID is the disconnected player, or "" for a game restored after
+	// a restart, which waits for both players
+	PlayerID       string
+	DisconnectedAt time.Time
+	ExpiresAt      time.Time
+	// Generation identifies this window, so a forfeit timer left over from
+	// an earlier window on the same game can tell it is stale
+	Generation uint64
+	// timer forfeits the game when the window runs out, after which notify
+	// is called
+	timer  *time.Timer
+	notify func(*Game)
+}
+
+// openReconnectWindow gives playerID the reconnect window to rejoin the
+// game before expireReconnectWindow ends it, replacing any window already
+// open on the game. mu must be held.
+func (m *Manager) openReconnectWindow(game *Game, playerID string, notifyCallback func(*Game)) *ReconnectWindow {
+	now := time.Now()
+	m.closeReconnectWindow(game.ID)
+	m.windowGeneration++
+	window := &ReconnectWindow{
+		PlayerID:       playerID,
+		DisconnectedAt: now,
+		ExpiresAt:      now.Add(m.reconnectWindow()),
+		Generation:     m.windowGeneration,
+		notify:         notifyCallback,
+	}
+	m.reconnectWindows[game.ID] = window
+
+	gameID, generation := game.ID, window.Generation
+	window.timer = time.AfterFunc(window.ExpiresAt.Sub(now), func() {
+		m.expireReconnectWindow(gameID, generation)
+	})
+	forfeitTimer := window.timer
+	context.AfterFunc(game.Context(), func() { forfeitTimer.Stop() })
+	return window
+}
+
+// expireWindow ends the game whose reconnect window has run out: the
+// disconnected player forfeits, or a restored game nobody came back to is
+// abandoned. mu must be held; the caller finalizes the game once released.
+func (m *Manager) expireWindow(gameID string, window *ReconnectWindow) *Game {
+	if window.PlayerID != "" {
+		return m.forfeitGame(gameID, window.PlayerID)
+	}
+	if game, exists := m.games[gameID]; exists {
+		m.trackReconnect(game, window, ReconnectAbandoned)
+	}
+	return m.abandonGame(gameID)
+}
+
+// closeReconnectWindow stops the forfeit timer of the game's reconnect
+// window, if any, and removes the window. mu must be held.
+func (m *Manager) closeReconnectWindow(gameID string) {
+	if window, exists := m.reconnectWindows[gameID]; exists {
+		if window.timer != nil {
+			window.timer.Stop()
+		}
+		delete(m.reconnectWindows, gameID)
+	}
+}
+
+// Reconnect outcomes reported to analytics. ReconnectDisconnected opens a
+// window; the others close it.
+const (
+	ReconnectDisconnected = "disconnected"
+	ReconnectSucceeded    = "reconnected"
+	ReconnectForfeited    = "forfeited"
+	ReconnectAbandoned    = "abandoned"
+)
+
+// trackReconnect reports a reconnect window event to analytics and, once the
+// window closes, to the reconnect metrics
+func (m *Manager) trackReconnect(game *Game, window *ReconnectWindow, outcome string) {
+	elapsed := time.Since(window.DisconnectedAt)
+	if outcome != ReconnectDisconnected {
+		metrics.RecordReconnect(outcome, outcome == ReconnectSucceeded, elapsed)
+	}
+	if m.analyticsService != nil {
+		m.analyticsService.TrackReconnect(game, window.PlayerID, outcome, elapsed, window.ExpiresAt.Sub(window.DisconnectedAt))
+	}
 }
 
+// Codes sent to clients with common errors, so they can tell them apart
+// without matching the message
+const (
+	CodeInvalidColumn = "invalidColumn"
+	CodeColumnFull    = "columnFull"
+	CodeNotYourTurn   = "notYourTurn"
+	CodeGameNotFound  = "gameNotFound"
+	CodeGameNotActive = "gameNotActive"
+	// CodeNotYourGame rejects an action by someone who isn't playing
+	CodeNotYourGame = "notYourGame"
+)
+
 type GameMoveResult struct {
 	Success bool
 	Message string
-	Game    *Game
+	// Code identifies why an action was rejected: one of the Code constants
+	// above, the rule that rejected a move, or "" if there is no code
+	Code string
+	Game *Game
+	// WinningCells holds the [row, col] of the winning run when the move
+	// won the game
+	WinningCells [][2]int
+	// Move is the move that was played, if any
+	Move *Move
 }
 
 type RejoinResult struct {
 	Success bool
 	Message string
+	Code    string
 	Game    *Game
 }
 
 type LeaderboardEntry struct {
-	Username   string `json:"username"`
-	Wins       int    `json:"wins"`
-	Losses     int    `json:"losses"`
-	Draws      int    `json:"draws"`
-	TotalGames int    `json:"total_games"`
+	Username   string  `json:"username"`
+	Wins       int     `json:"wins"`
+	Losses     int     `json:"losses"`
+	Draws      int     `json:"draws"`
+	TotalGames int     `json:"total_games"`
+	WinRate    float64 `json:"win_rate"`
+	Rank       int     `json:"rank"`
+	// Rating is the player's Elo rating from games against other humans
+	Rating int `json:"rating"`
+}
+
+// LeaderboardSort selects the ordering used by GetLeaderboard.
+//
+//   - LeaderboardSortWins (default): wins, then total games played.
+//   - LeaderboardSortWinRate: players with at least MinGamesForWinRate games
+//     first, ordered by win rate, then wins. Players below the threshold
+//     follow, so a 1-0 record can't outrank an established player.
+type LeaderboardSort string
+
+const (
+	LeaderboardSortWins    LeaderboardSort = "wins"
+	LeaderboardSortWinRate LeaderboardSort = "win_rate"
+)
+
+// Leaderboard page size limits
+const (
+	DefaultLeaderboardLimit = 100
+	MaxLeaderboardLimit     = 100
+)
+
+// LeaderboardQuery selects a page of the leaderboard. Players with fewer than
+// MinGames games are left out of the ranking altogether, so ranks stay
+// consistent from page to page.
+type LeaderboardQuery struct {
+	Sort     LeaderboardSort
+	Limit    int
+	Offset   int
+	MinGames int
+}
+
+// LeaderboardPage is one page of the leaderboard. Total counts every ranked
+// player, for rendering pagination.
+type LeaderboardPage struct {
+	Entries []LeaderboardEntry `json:"entries"`
+	Total   int                `json:"total"`
+	Limit   int                `json:"limit"`
+	Offset  int                `json:"offset"`
 }
 
-func NewManager(db *sql.DB, analyticsService Analytics) *Manager {
+// MinGamesForWinRate is the number of games a player needs before their
+// win rate counts towards win-rate ordering.
+const MinGamesForWinRate = 10
+
+// ParseLeaderboardSort maps a query value to a LeaderboardSort, falling back
+// to the default ordering for empty or unknown values.
+func ParseLeaderboardSort(value string) LeaderboardSort {
+	switch LeaderboardSort(value) {
+	case LeaderboardSortWinRate:
+		return LeaderboardSortWinRate
+	default:
+		return LeaderboardSortWins
+	}
+}
+
+func (s LeaderboardSort) orderBy() string {
+	switch s {
+	case LeaderboardSortWinRate:
+		return fmt.Sprintf("(total_games >= %d) DESC, win_rate DESC, wins DESC, total_games DESC", MinGamesForWinRate)
+	default:
+		return "wins DESC, total_games DESC"
+	}
+}
+
+func NewManager(store Store, analyticsService Analytics, options Options) *Manager {
 	return &Manager{
 		games:            make(map[string]*Game),
-		db:                db,
+		store:             store,
 		analyticsService:  analyticsService,
 		reconnectWindows:  make(map[string]*ReconnectWindow),
+		options:          options,
+		feedSubscribers:   make(map[*websocket.Conn]bool),
 	}
 }
 
@@ -134,6 +702,46 @@ func createTables(db *sql.DB) error {
 		return err
 	}
 
+	// Handicap games record the position they started from
+	_, err = db.Exec(`ALTER TABLE games ADD COLUMN IF NOT EXISTS starting_board JSONB`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`ALTER TABLE games ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}'`)
+	if err != nil {
+		return err
+	}
+
+	// Games on a non-standard board record its size and win length
+	_, err = db.Exec(`ALTER TABLE games ADD COLUMN IF NOT EXISTS dimensions JSONB`)
+	if err != nil {
+		return err
+	}
+
+	// Player IDs tie the IDs in winner and moves to the usernames
+	_, err = db.Exec(`ALTER TABLE games ADD COLUMN IF NOT EXISTS player1_id VARCHAR(255)`)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`ALTER TABLE games ADD COLUMN IF NOT EXISTS player2_id VARCHAR(255)`)
+	if err != nil {
+		return err
+	}
+
+	// Games in play, so they survive a restart
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS active_games (
+			id VARCHAR(255) PRIMARY KEY,
+			moves INTEGER NOT NULL,
+			state JSONB NOT NULL,
+			updated_at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
 	_, err = db.Exec(`
 		CREATE TABLE IF NOT EXISTS leaderboard (
 			username VARCHAR(255) PRIMARY KEY,
@@ -143,6 +751,11 @@ func createTables(db *sql.DB) error {
 			total_games INTEGER DEFAULT 0
 		)
 	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf(`ALTER TABLE leaderboard ADD COLUMN IF NOT EXISTS rating INTEGER NOT NULL DEFAULT %d`, DefaultRating))
 	return err
 }
 
@@ -154,39 +767,245 @@ func getEnv(key, defaultValue string) string {
 	return value
 }
 
-func (m *Manager) CreateGame(player1, player2 *Player) *Game {
+func (m *Manager) CreateGame(player1, player2 *Player, tags ...string) *Game {
+	return m.CreateGameWithDimensions(player1, player2, StandardDimensions, tags...)
+}
+
+// CreateGameWithDimensions starts a game on an empty board of the given
+// size, which the caller has checked with Dimensions.Validate
+func (m *Manager) CreateGameWithDimensions(player1, player2 *Player, dimensions Dimensions, tags ...string) *Game {
+	return m.CreateGameWithFirstMove(player1, player2, dimensions, "", tags...)
+}
+
+// CreateGameWithFirstMove is CreateGameWithDimensions with the first mover
+// chosen by the players; an empty firstMove leaves it to the server
+func (m *Manager) CreateGameWithFirstMove(player1, player2 *Player, dimensions Dimensions, firstMove FirstMove, tags ...string) *Game {
+	return m.addGame(m.startFromEmptyBoard(player1, player2, dimensions, firstMove), tags)
+}
+
+// CreatePrivateGame starts a game between two players who met through a
+// private room code, on the board size and with the first mover the host
+// asked for
+func (m *Manager) CreatePrivateGame(player1, player2 *Player, dimensions Dimensions, firstMove FirstMove) *Game {
+	return m.CreateGameWithFirstMove(player1, player2, dimensions, firstMove, TagPrivate)
+}
+
+// CreateTournamentGame starts a match game in a tournament bracket
+func (m *Manager) CreateTournamentGame(player1, player2 *Player) *Game {
+	return m.CreateGame(player1, player2, TagTournament)
+}
+
+// CoinFlip is the auditable record of a first-move coin flip: replaying
+// rand.New(rand.NewSource(Seed)).Intn(2) gives 0 for player1, 1 for player2.
+type CoinFlip struct {
+	Seed    int64  `json:"seed"`
+	Starter string `json:"starter"`
+}
+
+func flipCoin(seed int64, player1, player2 *Player) *CoinFlip {
+	starter := player1
+	if rand.New(rand.NewSource(seed)).Intn(2) == 1 {
+		starter = player2
+	}
+	return &CoinFlip{Seed: seed, Starter: starter.ID}
+}
+
+func (m *Manager) startFromEmptyBoard(player1, player2 *Player, dimensions Dimensions, firstMove FirstMove) *Game {
+	policy := m.firstMovePolicy(firstMove, player1, player2)
+	if policy == FirstMoveChallenger {
+		return m.newGame(player1, player2, dimensions, dimensions.CreateBoard(), player2.ID)
+	}
+	if policy != FirstMoveCoinFlip {
+		return m.newGame(player1, player2, dimensions, dimensions.CreateBoard(), player1.ID)
+	}
+
+	flip := flipCoin(time.Now().UnixNano(), player1, player2)
+	game := m.newGame(player1, player2, dimensions, dimensions.CreateBoard(), flip.Starter)
+	game.CoinFlip = flip
+	game.logEvent("coinFlip", fmt.Sprintf("seed=%d starter=%s", flip.Seed, flip.Starter))
+	return game
+}
+
+// CreateGameWithBoard starts a game from a pre-filled handicap position. Every
+// disc on the board must belong to player1 or player2 (by ID), the position
+// must pass ValidateHandicapBoard and it must not already be won or full. The
+// player with fewer discs moves first, player1 on a tie.
+func (m *Manager) CreateGameWithBoard(player1, player2 *Player, board [][]interface{}, tags ...string) (*Game, error) {
+	if err := ValidateHandicapBoard(board); err != nil {
+		return nil, fmt.Errorf("invalid starting board: %v", err)
+	}
+
+	var player1Discs, player2Discs int
+	for row := 0; row < ROWS; row++ {
+		for col := 0; col < COLS; col++ {
+			switch board[row][col] {
+			case nil:
+				continue
+			case player1.ID:
+				player1Discs++
+			case player2.ID:
+				player2Discs++
+			default:
+				return nil, fmt.Errorf("invalid starting board: unknown player at row %d, column %d", row, col)
+			}
+			if CheckWin(board, row, col).Won {
+				return nil, fmt.Errorf("invalid starting board: position is already won")
+			}
+		}
+	}
+	if IsBoardFull(board) {
+		return nil, fmt.Errorf("invalid starting board: board is full")
+	}
+
+	startingBoard := make([][]interface{}, ROWS)
+	liveBoard := make([][]interface{}, ROWS)
+	for row := range board {
+		startingBoard[row] = append([]interface{}{}, board[row]...)
+		liveBoard[row] = append([]interface{}{}, board[row]...)
+	}
+
+	firstPlayer := player1.ID
+	if player2Discs < player1Discs {
+		firstPlayer = player2.ID
+	}
+
+	game := m.newGame(player1, player2, StandardDimensions, liveBoard, firstPlayer)
+	game.StartingBoard = startingBoard
+	game.AddTag(TagHandicap)
+	game.logEvent("handicap", fmt.Sprintf("player1Discs=%d player2Discs=%d", player1Discs, player2Discs))
+	return m.addGame(game, tags), nil
+}
+
+// newGame sets up a game without registering it, so it can be finished off
+// before other goroutines can see it
+func (m *Manager) newGame(player1, player2 *Player, dimensions Dimensions, board [][]interface{}, firstPlayer string) *Game {
+	player1.Seat, player1.Color = 1, ColorRed
+	player2.Seat, player2.Color = 2, ColorYellow
+	for _, player := range []*Player{player1, player2} {
+		if !player.IsBot {
+			player.ReconnectToken = uuid.New().String()
+		}
+	}
+
 	gameID := uuid.New().String()
+	ctx, cancel := context.WithCancel(context.Background())
 	game := &Game{
 		ID:            gameID,
 		Player1:       player1,
 		Player2:       player2,
-		Board:         CreateBoard(),
-		CurrentPlayer: player1.ID,
+		Board:         board,
+		Dimensions:    dimensions,
+		CurrentPlayer: firstPlayer,
 		Status:        "active",
 		Winner:        "",
 		Moves:         []Move{},
 		StartedAt:     time.Now(),
 		LastMoveAt:    time.Now(),
+		ctx:           ctx,
+		cancel:        cancel,
+		final:         &finalGame{},
+	}
+	if m.options.DiscLimit > 0 {
+		game.DiscsRemaining = map[string]int{
+			player1.ID: m.options.DiscLimit,
+			player2.ID: m.options.DiscLimit,
+		}
+	}
+	if player1.IsBot || player2.IsBot {
+		game.AddTag(TagBot)
+	} else {
+		game.AddTag(TagRanked)
 	}
 
-	m.games[gameID] = game
+	game.logEvent("created", fmt.Sprintf("player1=%s player2=%s", player1.Username, player2.Username))
+	return game
+}
+
+// addGame tags a game from newGame and registers it with the manager
+func (m *Manager) addGame(game *Game, tags []string) *Game {
+	for _, tag := range tags {
+		game.AddTag(tag)
+	}
+
+	m.mu.Lock()
+	m.games[game.ID] = game
+	m.startTurnClock(game)
+	active := game.snapshotActive()
+	started := game.snapshot()
+	m.unlock()
+	m.saveActive(active)
+	metrics.GamesStarted.Inc()
 
 	// Track game start
 	if m.analyticsService != nil {
-		m.analyticsService.TrackGameStart(game)
+		m.analyticsService.TrackGameStart(started)
 	}
 
-	return game
+	return started
+}
+
+// locked runs fn with mu held and reports whether it finished its game, so
+// the caller can score the game once the lock is released
+func (m *Manager) locked(fn func() *GameMoveResult) (*GameMoveResult, bool) {
+	m.mu.Lock()
+	result := fn()
+	var status string
+	var active *ActiveGameRecord
+	if result.Success {
+		status = result.Game.Status
+		active = result.Game.snapshotActive()
+	}
+	if result.Game != nil {
+		result.Game = result.Game.snapshot()
+	}
+	m.unlock()
+
+	// Keep the active games store in step; finished games are dropped from
+	// it when they are saved
+	if active != nil {
+		m.saveActive(active)
+	} else if status == "aborted" {
+		m.dropActive(result.Game.ID)
+	}
+	return result, status == "finished"
 }
 
+// finalize scores, saves and reports a game that has just ended. It writes
+// to the store, so mu must not be held.
+func (m *Manager) finalize(game *Game) {
+	m.SaveGame(game)
+	if m.analyticsService != nil {
+		m.analyticsService.TrackGameEnd(game)
+	}
+}
+
+// MakeMove plays column for the player on conn. A game the move finishes is
+// scored when it is saved, so the move can still be taken back until then.
 func (m *Manager) MakeMove(gameID string, column int, conn *websocket.Conn) *GameMoveResult {
+	result, _ := m.locked(func() *GameMoveResult {
+		game, player, failure := m.checkHumanMove(gameID, column, conn)
+		if failure != nil {
+			return failure
+		}
+		if m.requiresConfirmation(game) {
+			return &GameMoveResult{Success: false, Message: "Moves in this game must be confirmed", Code: "confirmationRequired"}
+		}
+		return m.applyMove(game, player, column)
+	})
+	return result
+}
+
+// checkHumanMove returns the game and the player on conn if it is their turn
+// and column passes the manager's move rules, or a failed result otherwise.
+// mu must be held.
+func (m *Manager) checkHumanMove(gameID string, column int, conn *websocket.Conn) (*Game, *Player, *GameMoveResult) {
 	game, exists := m.games[gameID]
 	if !exists {
-		return &GameMoveResult{Success: false, Message: "Game not found"}
+		return nil, nil, &GameMoveResult{Success: false, Message: "Game not found", Code: CodeGameNotFound}
 	}
 
 	if game.Status != "active" {
-		return &GameMoveResult{Success: false, Message: "Game is not active"}
+		return nil, nil, &GameMoveResult{Success: false, Message: "Game is not active", Code: CodeGameNotActive}
 	}
 
 	// Verify it's the player's turn
@@ -198,151 +1017,279 @@ func (m *Manager) MakeMove(gameID string, column int, conn *websocket.Conn) *Gam
 	}
 
 	if player.IsBot {
-		return &GameMoveResult{Success: false, Message: "Not your turn"}
+		return nil, nil, &GameMoveResult{Success: false, Message: "Not your turn", Code: CodeNotYourTurn}
 	}
 	if player.Conn != conn {
-		return &GameMoveResult{Success: false, Message: "Not your turn"}
+		return nil, nil, &GameMoveResult{Success: false, Message: "Not your turn", Code: CodeNotYourTurn}
 	}
 
 	// Validate column
-	if column < 0 || column >= 7 {
-		return &GameMoveResult{Success: false, Message: "Invalid column"}
+	if column < 0 || column >= game.Dimensions.Cols {
+		return nil, nil, &GameMoveResult{Success: false, Message: "Invalid column", Code: CodeInvalidColumn}
+	}
+
+	if violation := m.checkMoveRules(game, player.ID, column); violation != nil {
+		return nil, nil, &GameMoveResult{Success: false, Message: violation.Message, Code: violation.Code}
 	}
 
+	return game, player, nil
+}
+
+// applyMove drops the current player's disc in column and settles the
+// result, apart from scoring. mu must be held.
+func (m *Manager) applyMove(game *Game, player *Player, column int) *GameMoveResult {
 	// Make move
 	moveResult := MakeMove(game.Board, column, game.CurrentPlayer)
 	if !moveResult.Success {
-		return &GameMoveResult{Success: false, Message: moveResult.Message}
+		return &GameMoveResult{Success: false, Message: moveResult.Message, Code: moveResult.Code}
 	}
 
 	// Record move
-	game.Moves = append(game.Moves, Move{
-		Player:    game.CurrentPlayer,
-		Column:    column,
-		Row:       moveResult.Row,
-		Timestamp: time.Now(),
-	})
-
-	game.LastMoveAt = time.Now()
+	game.recordMove(game.CurrentPlayer, column, moveResult.Row)
+	player.ReconnectToken = uuid.New().String()
+	if m.abortIfCorrupted(game) {
+		return &GameMoveResult{Success: true, Game: game}
+	}
 
 	// Check for win
-	winResult := CheckWin(game.Board, moveResult.Row, column)
+	game.logEvent("move", fmt.Sprintf("player=%s column=%d row=%d", game.CurrentPlayer, column, moveResult.Row))
+
+	winResult := game.Dimensions.CheckWin(game.Board, moveResult.Row, column)
 	if winResult.Won {
-		game.Status = "finished"
-		game.Winner = game.CurrentPlayer
-		now := time.Now()
-		game.EndedAt = &now
-		m.UpdateLeaderboard(game)
+		game.WinningCells = winResult.Cells
+		game.finish(game.CurrentPlayer)
 	} else if IsBoardFull(game.Board) {
-		game.Status = "finished"
-		game.Winner = "draw"
-		now := time.Now()
-		game.EndedAt = &now
-		m.UpdateLeaderboard(game)
+		game.finish("draw")
+	} else if m.isDeadPosition(game) {
+		game.finishWithResult("draw", ResultDeadPosition)
 	} else {
-		// Switch turns
-		if game.CurrentPlayer == game.Player1.ID {
-			if game.Player2.IsBot {
-				game.CurrentPlayer = "bot"
-			} else {
-				game.CurrentPlayer = game.Player2.ID
-			}
+		game.switchTurn()
+		game.endIfOutOfDiscs()
+		m.startTurnClock(game)
+	}
+
+	// Track move
+	if m.analyticsService != nil {
+		m.analyticsService.TrackMove(game, player.ID, player.Username, column, moveResult.Row)
+	}
+
+	move := game.Moves[len(game.Moves)-1]
+	return &GameMoveResult{Success: true, Game: game, WinningCells: winResult.Cells, Move: &move}
+}
+
+// Tie rules for a pop out that completes four in a row for both players
+const (
+	PopOutTieDraw       = "draw"
+	PopOutTieMoverLoses = "moverLoses"
+)
+
+// PopOut plays a Pop Out move for the player on conn: their own disc at the
+// bottom of column is removed and the column drops by one. Because every
+// disc above shifts, the whole board is checked for wins afterwards.
+func (m *Manager) PopOut(gameID string, column int, conn *websocket.Conn) *GameMoveResult {
+	if !m.options.PopOut {
+		return &GameMoveResult{Success: false, Message: "Pop out is not enabled"}
+	}
+
+	result, _ := m.locked(func() *GameMoveResult { return m.popOut(gameID, column, conn) })
+	return result
+}
+
+func (m *Manager) popOut(gameID string, column int, conn *websocket.Conn) *GameMoveResult {
+	game, exists := m.games[gameID]
+	if !exists {
+		return &GameMoveResult{Success: false, Message: "Game not found", Code: CodeGameNotFound}
+	}
+	if game.Status != "active" {
+		return &GameMoveResult{Success: false, Message: "Game is not active", Code: CodeGameNotActive}
+	}
+
+	player := game.Player1
+	if game.CurrentPlayer != game.Player1.ID {
+		player = game.Player2
+	}
+	if player.IsBot || player.Conn != conn {
+		return &GameMoveResult{Success: false, Message: "Not your turn", Code: CodeNotYourTurn}
+	}
+
+	moveResult := PopOut(game.Board, column, player.ID)
+	if !moveResult.Success {
+		return &GameMoveResult{Success: false, Message: moveResult.Message, Code: moveResult.Code}
+	}
+
+	game.recordPop(player.ID, column)
+	player.ReconnectToken = uuid.New().String()
+	if m.abortIfCorrupted(game) {
+		return &GameMoveResult{Success: true, Game: game}
+	}
+	game.logEvent("popOut", fmt.Sprintf("player=%s column=%d", player.ID, column))
+
+	p1Won, p2Won := game.Dimensions.CheckAllWins(game.Board, game.Player1.ID, game.Player2.ID)
+	switch {
+	case p1Won && p2Won:
+		if m.options.PopOutTieRule == PopOutTieMoverLoses {
+			game.finish(game.Opponent(player).ID)
 		} else {
-			game.CurrentPlayer = game.Player1.ID
+			game.finish("draw")
 		}
+	case p1Won:
+		game.finish(game.Player1.ID)
+	case p2Won:
+		game.finish(game.Player2.ID)
+	default:
+		game.switchTurn()
+		game.endIfOutOfDiscs()
+		m.startTurnClock(game)
 	}
 
-	// Track move
 	if m.analyticsService != nil {
-		m.analyticsService.TrackMove(game, column, moveResult.Row)
+		m.analyticsService.TrackMove(game, player.ID, player.Username, column, moveResult.Row)
 	}
 
-	return &GameMoveResult{Success: true, Game: game}
+	move := game.Moves[len(game.Moves)-1]
+	return &GameMoveResult{Success: true, Game: game, Move: &move}
+}
+
+// isDeadPosition reports whether neither player can complete a line any
+// more. Pop Out lets discs be removed again, so it never applies there.
+func (m *Manager) isDeadPosition(game *Game) bool {
+	return !m.options.PopOut && !game.Dimensions.CanAnyoneStillWin(game.Board, game.Player1.ID, game.Player2.ID)
 }
 
 func (m *Manager) BotMakeMove(gameID string, column int) *GameMoveResult {
+	result, finished := m.locked(func() *GameMoveResult { return m.botMakeMove(gameID, column) })
+	if finished && m.settle(result.Game.ID) {
+		m.UpdateLeaderboard(result.Game)
+	}
+	return result
+}
+
+func (m *Manager) botMakeMove(gameID string, column int) *GameMoveResult {
 	game, exists := m.games[gameID]
 	if !exists || game.Status != "active" {
 		return &GameMoveResult{Success: false}
 	}
 
-	if game.CurrentPlayer != "bot" {
+	if game.CurrentPlayer != BotID {
 		return &GameMoveResult{Success: false}
 	}
 
-	moveResult := MakeMove(game.Board, column, "bot")
+	moveResult := MakeMove(game.Board, column, BotID)
 	if !moveResult.Success {
 		return &GameMoveResult{Success: false}
 	}
 
-	game.Moves = append(game.Moves, Move{
-		Player:    "bot",
-		Column:    column,
-		Row:       moveResult.Row,
-		Timestamp: time.Now(),
-	})
+	game.recordMove(BotID, column, moveResult.Row)
+	if m.abortIfCorrupted(game) {
+		return &GameMoveResult{Success: true, Game: game}
+	}
 
-	game.LastMoveAt = time.Now()
+	game.logEvent("move", fmt.Sprintf("player=bot column=%d row=%d", column, moveResult.Row))
 
-	winResult := CheckWin(game.Board, moveResult.Row, column)
+	winResult := game.Dimensions.CheckWin(game.Board, moveResult.Row, column)
 	if winResult.Won {
-		game.Status = "finished"
-		game.Winner = "bot"
-		now := time.Now()
-		game.EndedAt = &now
-		m.UpdateLeaderboard(game)
+		game.WinningCells = winResult.Cells
+		game.finish(BotID)
 	} else if IsBoardFull(game.Board) {
-		game.Status = "finished"
-		game.Winner = "draw"
-		now := time.Now()
-		game.EndedAt = &now
-		m.UpdateLeaderboard(game)
+		game.finish("draw")
+	} else if m.isDeadPosition(game) {
+		game.finishWithResult("draw", ResultDeadPosition)
 	} else {
 		game.CurrentPlayer = game.Player1.ID
+		game.endIfOutOfDiscs()
+		m.startTurnClock(game)
 	}
 
 	if m.analyticsService != nil {
-		m.analyticsService.TrackMove(game, column, moveResult.Row)
+		m.analyticsService.TrackMove(game, BotID, game.Player2.Username, column, moveResult.Row)
 	}
 
-	return &GameMoveResult{Success: true, Game: game}
+	return &GameMoveResult{Success: true, Game: game, WinningCells: winResult.Cells}
+}
+
+func (m *Manager) RejoinGame(conn *websocket.Conn, username, gameID, reconnectToken string) *RejoinResult {
+	m.mu.Lock()
+	window := m.reconnectWindows[gameID]
+	result, forfeited := m.rejoinGame(conn, username, gameID, reconnectToken)
+	if result.Game != nil {
+		result.Game = result.Game.snapshot()
+	}
+	if forfeited != nil {
+		forfeited = forfeited.snapshot()
+	}
+	m.unlock()
+
+	if forfeited != nil {
+		m.settleExpiredWindow(forfeited, window)
+	}
+	return result
 }
 
-func (m *Manager) RejoinGame(conn *websocket.Conn, username, gameID string) *RejoinResult {
+// rejoinGame also returns the game if it ended because the reconnect window
+// had already run out
+func (m *Manager) rejoinGame(conn *websocket.Conn, username, gameID, reconnectToken string) (*RejoinResult, *Game) {
 	game, exists := m.games[gameID]
 	if !exists {
-		return &RejoinResult{Success: false, Message: "Game not found"}
+		return &RejoinResult{Success: false, Message: "Game not found", Code: CodeGameNotFound}, nil
 	}
 
 	// Check reconnect window
 	reconnectInfo, hasWindow := m.reconnectWindows[gameID]
 	if !hasWindow {
-		return &RejoinResult{Success: false, Message: "Reconnection window expired"}
+		return &RejoinResult{Success: false, Message: "Reconnection window expired"}, nil
 	}
 
 	now := time.Now()
 	if now.After(reconnectInfo.ExpiresAt) {
-		delete(m.reconnectWindows, gameID)
-		m.ForfeitGame(gameID, reconnectInfo.PlayerID, nil)
-		return &RejoinResult{Success: false, Message: "Reconnection window expired"}
+		ended := m.expireWindow(gameID, reconnectInfo)
+		return &RejoinResult{Success: false, Message: "Reconnection window expired"}, ended
 	}
 
 	// Reconnect player
+	var player *Player
 	if game.Player1.Username == username {
-		game.Player1.Conn = conn
-		delete(m.reconnectWindows, gameID)
-		return &RejoinResult{Success: true, Game: game}
+		player = game.Player1
 	} else if game.Player2.Username == username {
-		game.Player2.Conn = conn
-		delete(m.reconnectWindows, gameID)
-		return &RejoinResult{Success: true, Game: game}
+		player = game.Player2
+	} else {
+		return &RejoinResult{Success: false, Message: "Username does not match this game"}, nil
 	}
 
-	return &RejoinResult{Success: false, Message: "Username does not match this game"}
+	if reconnectToken == "" || reconnectToken != player.ReconnectToken {
+		return &RejoinResult{Success: false, Message: "Invalid reconnect token"}, nil
+	}
+
+	player.Conn = conn
+	player.disconnected = false
+	m.closeReconnectWindow(gameID)
+	m.trackReconnect(game, reconnectInfo, ReconnectSucceeded)
+	game.logEvent("reconnect", "player="+player.ID)
+
+	// In a restored game the opponent may not be back yet, and now forfeits
+	// if they don't make it in time
+	if opponent := game.Opponent(player); reconnectInfo.PlayerID == "" && !opponent.IsBot && opponent.Conn == nil {
+		window := m.openReconnectWindow(game, opponent.ID, reconnectInfo.notify)
+		m.trackReconnect(game, window, ReconnectDisconnected)
+	}
+	return &RejoinResult{Success: true, Game: game}, nil
 }
 
 func (m *Manager) HandleDisconnect(conn *websocket.Conn, notifyCallback func(*Game)) {
+	m.mu.Lock()
+	delete(m.feedSubscribers, conn)
+
+	var abandoned []*Game
 	for gameID, game := range m.games {
+		game.removeSpectator(conn)
+		for _, player := range []*Player{game.Player1, game.Player2} {
+			if player.Conn == conn {
+				player.disconnected = true
+			}
+		}
+
+		if game.Status == "finished" {
+			m.leaveFinishedGame(game, conn)
+		}
 		if game.Status != "active" {
 			continue
 		}
@@ -355,13 +1302,21 @@ func (m *Manager) HandleDisconnect(conn *websocket.Conn, notifyCallback func(*Ga
 		}
 
 		if disconnectedPlayer != nil {
-			// Set 30 second reconnect window
-			expiresAt := time.Now().Add(30 * time.Second)
-			m.reconnectWindows[gameID] = &ReconnectWindow{
-				PlayerID:  disconnectedPlayer.ID,
-				ExpiresAt: expiresAt,
+			game.logEvent("disconnect", "player="+disconnectedPlayer.ID)
+
+			// If the opponent is already inside their own reconnect window,
+			// nobody is left to award the win to
+			if window, exists := m.reconnectWindows[gameID]; exists && window.PlayerID != disconnectedPlayer.ID {
+				m.trackReconnect(game, window, ReconnectAbandoned)
+				if abandonedGame := m.abandonGame(gameID); abandonedGame != nil {
+					abandoned = append(abandoned, abandonedGame.snapshot())
+				}
+				continue
 			}
 
+			window := m.openReconnectWindow(game, disconnectedPlayer.ID, notifyCallback)
+			m.trackReconnect(game, window, ReconnectDisconnected)
+
 			// Notify opponent
 			var opponent *Player
 			if disconnectedPlayer == game.Player1 {
@@ -370,68 +1325,214 @@ func (m *Manager) HandleDisconnect(conn *websocket.Conn, notifyCallback func(*Ga
 				opponent = game.Player1
 			}
 
-			if opponent.Conn != nil {
-				opponent.Conn.WriteJSON(map[string]interface{}{
-					"type":    "playerDisconnected",
-					"message": fmt.Sprintf("%s disconnected. Reconnecting...", disconnectedPlayer.Username),
-				})
-			}
+			m.send(opponent.Conn, map[string]interface{}{
+				"type":             "playerDisconnected",
+				"message":          fmt.Sprintf("%s disconnected. Reconnecting...", disconnectedPlayer.Username),
+				"expiresAt":        window.ExpiresAt.Format(time.RFC3339),
+				"secondsRemaining": int(math.Ceil(time.Until(window.ExpiresAt).Seconds())),
+			})
+		}
+	}
+	m.unlock()
 
-			// Schedule forfeit if not reconnected
-			forfeitGameID := gameID
-			forfeitPlayerID := disconnectedPlayer.ID
-			time.AfterFunc(30*time.Second, func() {
-				if _, exists := m.reconnectWindows[forfeitGameID]; exists {
-					forfeitedGame := m.ForfeitGame(forfeitGameID, forfeitPlayerID, notifyCallback)
-					if forfeitedGame != nil && notifyCallback != nil {
-						notifyCallback(forfeitedGame)
-					}
-				}
+	for _, game := range abandoned {
+		m.finalize(game)
+	}
+}
+
+// expireReconnectWindow ends the game with expireWindow if the reconnect
+// window still open on it is the one the timer was set for
+func (m *Manager) expireReconnectWindow(gameID string, generation uint64) {
+	m.mu.Lock()
+	var game *Game
+	window, exists := m.reconnectWindows[gameID]
+	if exists && window.Generation == generation {
+		if ended := m.expireWindow(gameID, window); ended != nil {
+			game = ended.snapshot()
+		}
+	}
+	m.unlock()
+
+	if game != nil {
+		m.settleExpiredWindow(game, window)
+	}
+}
+
+// settleExpiredWindow scores and saves a game ended by its reconnect window
+// running out, then tells the players. A player whose opponent didn't come
+// back gets a reconnectExpired message explaining the win before the final
+// gameState. It runs once per window, as the window is closed when the game
+// ends, and never for games that had already ended. mu must not be held.
+func (m *Manager) settleExpiredWindow(game *Game, window *ReconnectWindow) {
+	m.finalize(game)
+
+	if window.PlayerID != "" {
+		for _, player := range []*Player{game.Player1, game.Player2} {
+			if player.ID == window.PlayerID || player.Conn == nil {
+				continue
+			}
+			missing := game.Opponent(player)
+			socket.WriteJSON(player.Conn, map[string]interface{}{
+				"type":     "reconnectExpired",
+				"gameId":   game.ID,
+				"username": missing.Username,
+				"message":  fmt.Sprintf("%s did not reconnect in time. You win!", missing.Username),
 			})
 		}
 	}
+	if window.notify != nil {
+		window.notify(game)
+	}
 }
 
 func (m *Manager) ForfeitGame(gameID, forfeitingPlayerID string, notifyCallback func(*Game)) *Game {
+	m.mu.Lock()
+	game := m.forfeitGame(gameID, forfeitingPlayerID)
+	if game != nil {
+		game = game.snapshot()
+	}
+	m.unlock()
+
+	if game == nil {
+		return nil
+	}
+	m.finalize(game)
+
+	// Notify players if callback provided
+	if notifyCallback != nil {
+		notifyCallback(game)
+	}
+
+	return game
+}
+
+// Resign concedes the game for the player on conn. The opponent wins, and the
+// game is scored and saved like any other win.
+func (m *Manager) Resign(gameID string, conn *websocket.Conn) *GameMoveResult {
+	result, finished := m.locked(func() *GameMoveResult {
+		game, exists := m.games[gameID]
+		if !exists {
+			return &GameMoveResult{Success: false, Message: "Game not found", Code: CodeGameNotFound}
+		}
+		if game.Status != "active" {
+			return &GameMoveResult{Success: false, Message: "Game is not active", Code: CodeGameNotActive}
+		}
+
+		player := game.playerByConn(conn)
+		if player == nil {
+			return &GameMoveResult{Success: false, Message: "Not a player in this game", Code: CodeNotYourGame}
+		}
+
+		game.logEvent("resign", "player="+player.ID)
+		game.finishWithResult(game.Opponent(player).ID, ResultResigned)
+		m.closeReconnectWindow(gameID)
+		return &GameMoveResult{Success: true, Game: game}
+	})
+	if finished {
+		m.finalize(result.Game)
+	}
+	return result
+}
+
+// forfeitGame ends the game in the opponent's favour and drops it from the
+// manager. mu must be held; the caller finalizes the game once released.
+func (m *Manager) forfeitGame(gameID, forfeitingPlayerID string) *Game {
 	game, exists := m.games[gameID]
 	if !exists || game.Status != "active" {
 		return nil
 	}
 
-	game.Status = "finished"
-	now := time.Now()
-	game.EndedAt = &now
+	game.logEvent("forfeit", "player="+forfeitingPlayerID)
+	if window, exists := m.reconnectWindows[gameID]; exists && window.PlayerID == forfeitingPlayerID {
+		m.trackReconnect(game, window, ReconnectForfeited)
+	}
 
 	// Determine winner
 	if game.Player1.ID == forfeitingPlayerID {
 		if game.Player2.IsBot {
-			game.Winner = "bot"
+			game.finishWithResult(BotID, ResultForfeit)
 		} else {
-			game.Winner = game.Player2.ID
+			game.finishWithResult(game.Player2.ID, ResultForfeit)
 		}
 	} else {
-		game.Winner = game.Player1.ID
+		game.finishWithResult(game.Player1.ID, ResultForfeit)
 	}
 
-	m.SaveGame(game)
-	m.UpdateLeaderboard(game)
-	if m.analyticsService != nil {
-		m.analyticsService.TrackGameEnd(game)
+	delete(m.games, gameID)
+	m.closeReconnectWindow(gameID)
+	metrics.GamesForfeited.Inc()
+
+	return game
+}
+
+// DeclareDrawByProof ends an active game as a draw because a solver proved
+// neither side can still win. The game is scored and saved like a normal draw.
+func (m *Manager) DeclareDrawByProof(gameID string) *Game {
+	m.mu.Lock()
+	game, exists := m.games[gameID]
+	if !exists || game.Status != "active" {
+		m.unlock()
+		return nil
 	}
+	game.finishWithResult("draw", ResultDrawnByProof)
+	game = game.snapshot()
+	m.unlock()
 
-	// Notify players if callback provided
-	if notifyCallback != nil {
-		notifyCallback(game)
+	m.finalize(game)
+	return game
+}
+
+// AbandonGame ends a game both players walked away from. It is saved with
+// status "abandoned" and no winner, and the leaderboard is left untouched.
+func (m *Manager) AbandonGame(gameID string) *Game {
+	m.mu.Lock()
+	game := m.abandonGame(gameID)
+	if game != nil {
+		game = game.snapshot()
+	}
+	m.unlock()
+
+	if game != nil {
+		m.finalize(game)
 	}
+	return game
+}
+
+// abandonGame marks the game abandoned and drops it from the manager. mu must
+// be held; the caller finalizes the game once released.
+func (m *Manager) abandonGame(gameID string) *Game {
+	game, exists := m.games[gameID]
+	if !exists || game.Status != "active" {
+		return nil
+	}
+
+	game.Status = "abandoned"
+	game.ResultType = ResultAbandoned
+	now := time.Now()
+	game.EndedAt = &now
+	game.logEvent("abandoned", "")
+	game.stopTurnClock()
+	game.end()
+	metrics.RecordGameEnd(ResultAbandoned, now.Sub(game.StartedAt))
 
 	delete(m.games, gameID)
-	delete(m.reconnectWindows, gameID)
+	m.closeReconnectWindow(gameID)
 
 	return game
 }
 
+const (
+	saveAttempts       = 3
+	saveInitialBackoff = 200 * time.Millisecond
+)
+
+// SaveGame scores a finished game, unless that was done already, and writes
+// it to the store, retrying transient failures with exponential backoff. If
+// every attempt fails the record is appended to the unsaved games file
+// (UNSAVED_GAMES_FILE) for reconciliation. Feed subscribers hear about the
+// result before the first attempt.
 func (m *Manager) SaveGame(game *Game) {
-	if game.Status != "finished" {
+	if game.Status != "finished" && game.Status != "abandoned" {
 		return
 	}
 
@@ -440,18 +1541,89 @@ func (m *Manager) SaveGame(game *Game) {
 		d := int(game.EndedAt.Sub(game.StartedAt).Seconds())
 		duration = &d
 	}
+	if m.settle(game.ID) {
+		m.UpdateLeaderboard(game)
+	}
+	m.broadcastGameFinished(game, duration)
+	m.dropActive(game.ID)
 
 	movesJSON, _ := json.Marshal(game.Moves)
 
-	_, err := m.db.Exec(
-		`INSERT INTO games (id, player1_username, player2_username, winner, status, started_at, ended_at, duration_seconds, moves)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
-		game.ID, game.Player1.Username, game.Player2.Username, game.Winner, game.Status,
-		game.StartedAt, game.EndedAt, duration, movesJSON,
-	)
+	var startingBoardJSON []byte
+	if game.StartingBoard != nil {
+		startingBoardJSON, _ = json.Marshal(game.StartingBoard)
+	}
+
+	record := GameRecord{
+		ID:              game.ID,
+		Player1:         game.Player1.Username,
+		Player2:         game.Player2.Username,
+		Player1ID:       game.Player1.ID,
+		Player2ID:       game.Player2.ID,
+		Winner:          game.Winner,
+		Status:          game.Status,
+		StartedAt:       game.StartedAt,
+		EndedAt:         game.EndedAt,
+		DurationSeconds: duration,
+		Moves:           movesJSON,
+		StartingBoard:   startingBoardJSON,
+		Tags:            game.Tags,
+	}
+	if !game.Dimensions.IsStandard() {
+		dimensions := game.Dimensions
+		record.Dimensions = &dimensions
+	}
+
+	backoff := saveInitialBackoff
+	var err error
+	for attempt := 1; attempt <= saveAttempts; attempt++ {
+		if err = m.store.SaveGame(record); err == nil {
+			return
+		}
+		slog.Error("Error saving game", "gameId", game.ID, "attempt", attempt, "attempts", saveAttempts, "error", err)
+		if attempt < saveAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	metrics.GameSaveFailures.Inc()
+	if err := writeUnsavedGame(record); err != nil {
+		slog.Error("Error writing unsaved game to fallback file", "gameId", game.ID, "error", err)
+	}
+}
+
+// settle marks the game as scored, reporting false if it already was. A game
+// the manager no longer holds is always scored, as nothing can reopen it.
+func (m *Manager) settle(gameID string) bool {
+	m.mu.Lock()
+	defer m.unlock()
+
+	game, exists := m.games[gameID]
+	if !exists {
+		return true
+	}
+	if game.settled {
+		return false
+	}
+	game.settled = true
+	return true
+}
+
+func writeUnsavedGame(record GameRecord) error {
+	line, err := json.Marshal(record)
 	if err != nil {
-		log.Printf("Error saving game: %v", err)
+		return err
 	}
+
+	f, err := os.OpenFile(getEnv("UNSAVED_GAMES_FILE", "unsaved_games.jsonl"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
 }
 
 func (m *Manager) UpdateLeaderboard(game *Game) {
@@ -459,87 +1631,279 @@ func (m *Manager) UpdateLeaderboard(game *Game) {
 		return
 	}
 
-	// Update player1
-	var player1Wins, player1Losses, player1Draws int
-	if game.Winner == game.Player1.ID {
-		player1Wins = 1
-	} else if game.Winner != "draw" {
-		player1Losses = 1
-	} else {
-		player1Draws = 1
-	}
-
-	_, err := m.db.Exec(
-		`INSERT INTO leaderboard (username, wins, losses, draws, total_games)
-		 VALUES ($1, $2, $3, $4, $5)
-		 ON CONFLICT (username) 
-		 DO UPDATE SET 
-		   wins = leaderboard.wins + $2,
-		   losses = leaderboard.losses + $3,
-		   draws = leaderboard.draws + $4,
-		   total_games = leaderboard.total_games + $5`,
-		game.Player1.Username, player1Wins, player1Losses, player1Draws, 1,
-	)
-	if err != nil {
-		log.Printf("Error updating leaderboard: %v", err)
-	}
-
-	// Update player2 (skip bot)
-	if !game.Player2.IsBot {
-		var player2Wins, player2Losses, player2Draws int
-		if game.Winner == "bot" {
-			player2Wins = 1
-		} else if game.Winner == game.Player2.ID {
-			player2Wins = 1
-		} else if game.Winner != "draw" && game.Winner != game.Player2.ID {
-			player2Losses = 1
-		} else if game.Winner == "draw" {
-			player2Draws = 1
-		}
-
-		_, err := m.db.Exec(
-			`INSERT INTO leaderboard (username, wins, losses, draws, total_games)
-			 VALUES ($1, $2, $3, $4, $5)
-			 ON CONFLICT (username) 
-			 DO UPDATE SET 
-			   wins = leaderboard.wins + $2,
-			   losses = leaderboard.losses + $3,
-			   draws = leaderboard.draws + $4,
-			   total_games = leaderboard.total_games + $5`,
-			game.Player2.Username, player2Wins, player2Losses, player2Draws, 1,
-		)
-		if err != nil {
-			log.Printf("Error updating leaderboard: %v", err)
+	// The bot has no leaderboard row
+	for _, player := range []*Player{game.Player1, game.Player2} {
+		if player.IsBot {
+			continue
+		}
+		wins, losses, draws := resultCounts(game.Winner, player.ID, game.Winner == "draw")
+		if err := m.store.RecordResult(player.Username, wins, losses, draws); err != nil {
+			slog.Error("Error updating leaderboard", "gameId", game.ID, "playerId", player.ID, "error", err)
 		}
 	}
+
+	m.updateRatings(game)
 }
 
-func (m *Manager) GetLeaderboard() ([]LeaderboardEntry, error) {
-	rows, err := m.db.Query(`
-		SELECT username, wins, losses, draws, total_games
-		FROM leaderboard
-		ORDER BY wins DESC, total_games DESC
-		LIMIT 100
-	`)
+// resultCounts is the (wins, losses, draws) a finished game adds to
+// playerID's leaderboard row: exactly one of them is 1
+func resultCounts(winnerID, playerID string, isDraw bool) (wins, losses, draws int) {
+	switch {
+	case isDraw:
+		return 0, 0, 1
+	case winnerID == playerID:
+		return 1, 0, 0
+	default:
+		return 0, 1, 0
+	}
+}
+
+// GetLeaderboard returns the page of the leaderboard described by query
+func (m *Manager) GetLeaderboard(query LeaderboardQuery) (*LeaderboardPage, error) {
+	return m.store.Leaderboard(query)
+}
+
+// GetLeaderboardAround returns the rows ranked up to window places above and
+// below username. The result is empty if the user has no leaderboard row.
+func (m *Manager) GetLeaderboardAround(username string, window int, sortBy LeaderboardSort) ([]LeaderboardEntry, error) {
+	return m.store.LeaderboardAround(username, window, sortBy)
+}
+
+// CanonicalUsername returns username as already spelled on the leaderboard,
+// so names differing only in case share one row. On a store error the name is
+// used as given.
+func (m *Manager) CanonicalUsername(username string) string {
+	canonical, err := m.store.CanonicalUsername(username)
 	if err != nil {
-		return nil, err
+		slog.Error("Error looking up username", "username", username, "error", err)
+		return username
+	}
+	return canonical
+}
+
+// GameSummary is a saved game as listed in the game history
+type GameSummary struct {
+	ID              string     `json:"id"`
+	Player1         string     `json:"player1"`
+	Player2         string     `json:"player2"`
+	Winner          string     `json:"winner"`
+	Status          string     `json:"status"`
+	StartedAt       time.Time  `json:"started_at"`
+	EndedAt         *time.Time `json:"ended_at"`
+	DurationSeconds *int       `json:"duration_seconds"`
+	Tags            []string   `json:"tags"`
+}
+
+// ListGames returns the most recently finished saved games, newest first.
+// A non-empty tag only returns games carrying that tag.
+func (m *Manager) ListGames(tag string, limit int) ([]GameSummary, error) {
+	return m.store.ListGames(tag, limit)
+}
+
+// LiveGame is an active game listed in the spectator lobby. Player names are
+// anonymized since the lobby is public.
+type LiveGame struct {
+	ID      string `json:"id"`
+	Player1 string `json:"player1"`
+	Player2 string `json:"player2"`
+	Moves   int    `json:"moves"`
+}
+
+// liveGameMinMovesRemaining keeps games that are about to end out of the lobby
+const liveGameMinMovesRemaining = 6
+
+// LiveGames lists active games worth spectating
+func (m *Manager) LiveGames() []LiveGame {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.liveGames()
+}
+
+func (m *Manager) liveGames() []LiveGame {
+	live := []LiveGame{}
+	for _, game := range m.games {
+		if game.Status != "active" || MovesRemaining(game.Board) < liveGameMinMovesRemaining {
+			continue
+		}
+		live = append(live, LiveGame{
+			ID:      game.ID,
+			Player1: anonymizeUsername(game.Player1),
+			Player2: anonymizeUsername(game.Player2),
+			Moves:   len(game.Moves),
+		})
+	}
+	return live
+}
+
+func anonymizeUsername(player *Player) string {
+	if player.IsBot || len(player.Username) == 0 {
+		return player.Username
+	}
+	return string([]rune(player.Username)[:1]) + "***"
+}
+
+// AddSpectator attaches conn to an active game so it receives every gameState.
+// An empty gameID picks a random game from LiveGames.
+func (m *Manager) AddSpectator(gameID string, conn *websocket.Conn) (*Game, bool) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	if gameID == "" {
+		live := m.liveGames()
+		if len(live) == 0 {
+			return nil, false
+		}
+		gameID = live[rand.Intn(len(live))].ID
+	}
+
+	game, exists := m.games[gameID]
+	if !exists || game.Status != "active" {
+		return nil, false
+	}
+	if game.Player1.Conn == conn || game.Player2.Conn == conn {
+		return nil, false
+	}
+
+	for _, spectator := range game.Spectators {
+		if spectator == conn {
+			return game.snapshot(), true
+		}
+	}
+	game.Spectators = append(game.Spectators, conn)
+	return game.snapshot(), true
+}
+
+func (g *Game) removeSpectator(conn *websocket.Conn) {
+	for i, spectator := range g.Spectators {
+		if spectator == conn {
+			g.Spectators = append(g.Spectators[:i], g.Spectators[i+1:]...)
+			return
+		}
+	}
+}
+
+// ActiveGameCount returns the number of games still being played
+func (m *Manager) ActiveGameCount() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	count := 0
+	for _, game := range m.games {
+		if game.Status == "active" {
+			count++
+		}
 	}
-	defer rows.Close()
+	return count
+}
+
+// Status is a snapshot of the games a Manager holds, for operators
+type Status struct {
+	ActiveGames int `json:"activeGames"`
+	// FinishedGames are kept for rematches until the sweeper drops them
+	FinishedGames    int `json:"finishedGames"`
+	ReconnectWindows int `json:"reconnectWindows"`
+}
+
+// Status counts the games held by status and the open reconnect windows
+func (m *Manager) Status() Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	status := Status{ReconnectWindows: len(m.reconnectWindows)}
+	for _, game := range m.games {
+		switch game.Status {
+		case "active":
+			status.ActiveGames++
+		case "finished":
+			status.FinishedGames++
+		}
+	}
+	return status
+}
 
-	var entries []LeaderboardEntry
-	for rows.Next() {
-		var entry LeaderboardEntry
-		err := rows.Scan(&entry.Username, &entry.Wins, &entry.Losses, &entry.Draws, &entry.TotalGames)
-		if err != nil {
-			return nil, err
+// PlayingGameID returns the ID of the active game username is playing in,
+// connected or not, or "" if there is none. Usernames are compared ignoring
+// case, as on the leaderboard.
+func (m *Manager) PlayingGameID(username string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, game := range m.games {
+		if game.Status != "active" {
+			continue
+		}
+		for _, player := range []*Player{game.Player1, game.Player2} {
+			if !player.IsBot && strings.EqualFold(player.Username, username) {
+				return game.ID
+			}
 		}
-		entries = append(entries, entry)
 	}
+	return ""
+}
+
+// ReconnectWindowCount returns the number of games waiting for a
+// disconnected player to rejoin
+func (m *Manager) ReconnectWindowCount() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.reconnectWindows)
+}
+
+// EnableBotDebug opts the human player on conn into botThinking messages
+// for their bot game
+func (m *Manager) EnableBotDebug(gameID string, conn *websocket.Conn) *GameMoveResult {
+	m.mu.Lock()
+	defer m.unlock()
+
+	game, exists := m.games[gameID]
+	if !exists || game.Status != "active" {
+		return &GameMoveResult{Success: false, Message: "Game not found", Code: CodeGameNotFound}
+	}
+	if !game.Player2.IsBot || game.Player1.Conn != conn {
+		return &GameMoveResult{Success: false, Message: "Only the player in a bot game can watch the bot think"}
+	}
+
+	game.Player1.DebugBot = true
+	return &GameMoveResult{Success: true, Game: game.snapshot()}
+}
+
+// GameForPlayer returns the active game the player is in, or nil
+func (m *Manager) GameForPlayer(playerID string) *Game {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
-	return entries, nil
+	for _, game := range m.games {
+		if game.Status == "active" && (game.Player1.ID == playerID || game.Player2.ID == playerID) {
+			return game.snapshot()
+		}
+	}
+	return nil
 }
 
+// GetGame returns a snapshot of the game, or nil if the manager doesn't hold
+// it
 func (m *Manager) GetGame(gameID string) *Game {
-	return m.games[gameID]
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	game, exists := m.games[gameID]
+	if !exists {
+		return nil
+	}
+	return game.snapshot()
+}
+
+// GetGameEvents returns a copy of the event log for a game still held in memory
+func (m *Manager) GetGameEvents(gameID string) ([]GameEvent, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	game, exists := m.games[gameID]
+	if !exists {
+		return nil, false
+	}
+	events := make([]GameEvent, len(game.Events))
+	copy(events, game.Events)
+	return events, true
 }
 
@@ -0,0 +1,290 @@
+package game
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSnapshotsDoNotChangeAsPlayContinues(t *testing.T) {
+	m := newTestManager(Options{DiscLimit: 21})
+	conn1, conn2 := &websocket.Conn{}, &websocket.Conn{}
+	player1, player2 := humans(conn1, conn2)
+	g := m.CreateGame(player1, player2)
+
+	before := m.GetGame(g.ID)
+	if result := m.MakeMove(g.ID, 3, conn1); !result.Success {
+		t.Fatalf("MakeMove: %s", result.Message)
+	}
+
+	if len(before.Moves) != 0 || before.Board[ROWS-1][3] != nil {
+		t.Errorf("snapshot taken before the move saw it: moves=%v", before.Moves)
+	}
+	if before.DiscsRemaining[player1.ID] != 21 {
+		t.Errorf("snapshot discsRemaining = %d, want 21", before.DiscsRemaining[player1.ID])
+	}
+	if before.CurrentPlayer != player1.ID {
+		t.Errorf("snapshot currentPlayer = %s, want %s", before.CurrentPlayer, player1.ID)
+	}
+
+	after := m.GetGame(g.ID)
+	if len(after.Moves) != 1 || after.Board[ROWS-1][3] != player1.ID || after.DiscsRemaining[player1.ID] != 20 {
+		t.Errorf("snapshot after the move is missing it: moves=%v", after.Moves)
+	}
+	if after.Opponent(after.Player1) != after.Player2 {
+		t.Error("Opponent doesn't work on a snapshot's own players")
+	}
+}
+
+// TestConcurrentPlayAndReads is meant for go test -race: moves, spectators
+// coming and going and readers of the game all run at once
+func TestConcurrentPlayAndReads(t *testing.T) {
+	m := newTestManager(Options{DiscLimit: 21})
+	conn1, conn2 := &websocket.Conn{}, &websocket.Conn{}
+	player1, player2 := humans(conn1, conn2)
+	g := m.CreateGame(player1, player2)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 30; i++ {
+			conn := conn1
+			if i%2 == 1 {
+				conn = conn2
+			}
+			m.MakeMove(g.ID, i%COLS, conn)
+		}
+	}()
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			spectator := &websocket.Conn{}
+			for j := 0; j < 50; j++ {
+				m.AddSpectator(g.ID, spectator)
+				if snapshot := m.GetGame(g.ID); snapshot != nil {
+					_ = GetValidMoves(snapshot.Board)
+					_ = snapshot.DiscsRemaining[player1.ID]
+					_ = len(snapshot.Moves) + len(snapshot.Spectators)
+				}
+				m.HandleDisconnect(spectator, nil)
+			}
+		}()
+	}
+	wg.Wait()
+
+	final := m.GetGame(g.ID)
+	if final == nil {
+		t.Fatal("game disappeared")
+	}
+	discs := 0
+	for _, row := range final.Board {
+		for _, cell := range row {
+			if cell != nil {
+				discs++
+			}
+		}
+	}
+	if discs != len(final.Moves) {
+		t.Errorf("board holds %d discs for %d moves", discs, len(final.Moves))
+	}
+	if len(final.Spectators) != 0 {
+		t.Errorf("%d spectators left after they all disconnected", len(final.Spectators))
+	}
+}
+
+func TestHandleDisconnectTellsOpponent(t *testing.T) {
+	m := newTestManager(Options{})
+	opponent := dial(t)
+	conn1 := &websocket.Conn{}
+	player1, player2 := humans(conn1, opponent.server)
+	g := m.CreateGame(player1, player2)
+
+	m.HandleDisconnect(conn1, nil)
+
+	msg := opponent.nextOfType(t, "playerDisconnected")
+	if msg["secondsRemaining"] == nil || msg["expiresAt"] == nil {
+		t.Errorf("playerDisconnected = %v, want the reconnect deadline", msg)
+	}
+	// The message went out after mu was released, so the manager is free
+	if snapshot := m.GetGame(g.ID); snapshot == nil || snapshot.Status != "active" {
+		t.Errorf("game = %+v, want it active while the window is open", snapshot)
+	}
+	m.mu.RLock()
+	queued := len(m.outbox)
+	m.mu.RUnlock()
+	if queued != 0 {
+		t.Errorf("%d messages left queued", queued)
+	}
+}
+
+func TestFinalSeesTheEndFromAnOlderSnapshot(t *testing.T) {
+	m := newTestManager(Options{})
+	player1, player2 := humans(nil, nil)
+	g := m.CreateGame(player1, player2)
+
+	m.ForfeitGame(g.ID, player1.ID, nil)
+
+	<-g.Context().Done()
+	if g.Status != "active" {
+		t.Errorf("snapshot from CreateGame changed to %s", g.Status)
+	}
+	if final := g.Final(); final.Status != "finished" || final.Winner != player2.ID {
+		t.Errorf("Final() = %s won by %q, want finished won by %s", final.Status, final.Winner, player2.ID)
+	}
+}
+
+func TestTrackMoveReportsTheMover(t *testing.T) {
+	tracker := &recordingAnalytics{}
+	m := NewManager(NewMemoryStore(), tracker, Options{})
+	conn1, conn2 := &websocket.Conn{}, &websocket.Conn{}
+	player1, player2 := humans(conn1, conn2)
+	g := m.CreateGame(player1, player2)
+	m.MakeMove(g.ID, 0, conn1)
+	m.MakeMove(g.ID, 1, conn2)
+
+	want := []trackedMove{
+		{"p1", "alice", 0, ROWS - 1},
+		{"p2", "bob", 1, ROWS - 1},
+	}
+
+	// Either side may start against the bot
+	human, bot := withBot(&websocket.Conn{})
+	botGame := m.CreateGame(human, bot)
+	if botGame.CurrentPlayer == BotID {
+		m.BotMakeMove(botGame.ID, 3)
+		want = append(want, trackedMove{BotID, "Bot", 3, ROWS - 1})
+	}
+	m.MakeMove(botGame.ID, 2, human.Conn)
+	m.BotMakeMove(botGame.ID, 3)
+	want = append(want, trackedMove{"p1", "alice", 2, ROWS - 1}, trackedMove{BotID, "Bot", 3, ROWS - 2})
+	if botGame.CurrentPlayer != BotID {
+		want[len(want)-1].row = ROWS - 1
+	}
+	if len(tracker.moves) != len(want) {
+		t.Fatalf("tracked %d moves, want %d", len(tracker.moves), len(want))
+	}
+	for i, move := range tracker.moves {
+		if move != want[i] {
+			t.Errorf("move %d tracked as %+v, want %+v", i, move, want[i])
+		}
+	}
+}
+
+func TestCreateGameWithBoardAcceptsHandicaps(t *testing.T) {
+	m := newTestManager(Options{})
+	human, bot := withBot(nil)
+	board := CreateBoard()
+	// Three bot discs head start: the human moves first
+	for _, col := range []int{0, 2, 4} {
+		board[ROWS-1][col] = BotID
+	}
+
+	g, err := m.CreateGameWithBoard(human, bot, board)
+	if err != nil {
+		t.Fatalf("CreateGameWithBoard: %v", err)
+	}
+	if g.CurrentPlayer != human.ID {
+		t.Errorf("currentPlayer = %s, want the player with fewer discs", g.CurrentPlayer)
+	}
+	if !hasTag(g.Tags, TagHandicap) {
+		t.Errorf("tags = %v, want %s", g.Tags, TagHandicap)
+	}
+
+	board[ROWS-1][1], board[ROWS-1][3] = BotID, BotID
+	if _, err := m.CreateGameWithBoard(human, bot, board); err == nil {
+		t.Error("accepted a position that is already won")
+	}
+}
+
+func TestPopOutDoubleWinFollowsTheTieRule(t *testing.T) {
+	for rule, winner := range map[string]string{PopOutTieDraw: "draw", PopOutTieMoverLoses: "p2"} {
+		t.Run(rule, func(t *testing.T) {
+			m := newTestManager(Options{PopOut: true, PopOutTieRule: rule})
+			conn1 := &websocket.Conn{}
+			g := m.CreateGame(humans(conn1, &websocket.Conn{}))
+			m.mu.Lock()
+			m.games[g.ID].Board = doubleWinBeforePop()
+			m.games[g.ID].CurrentPlayer = "p1"
+			m.mu.Unlock()
+
+			result := m.PopOut(g.ID, 0, conn1)
+			if !result.Success {
+				t.Fatalf("pop out failed: %s", result.Message)
+			}
+			if result.Game.Status != "finished" || result.Game.Winner != winner {
+				t.Errorf("game is %s with winner %q, want finished with %q", result.Game.Status, result.Game.Winner, winner)
+			}
+		})
+	}
+}
+
+func TestDeadPositionEndsTheGameEarly(t *testing.T) {
+	t.Run("human move", func(t *testing.T) {
+		m := newTestManager(Options{})
+		conn1 := &websocket.Conn{}
+		g := m.CreateGame(humans(conn1, &websocket.Conn{}))
+		m.mu.Lock()
+		m.games[g.ID].Board = deadDraw()
+		m.games[g.ID].Board[0][2] = nil
+		m.games[g.ID].CurrentPlayer = "p1"
+		m.mu.Unlock()
+
+		result := m.MakeMove(g.ID, 2, conn1)
+		if !result.Success {
+			t.Fatalf("move failed: %s", result.Message)
+		}
+		if result.Game.Status != "finished" || result.Game.Winner != "draw" || result.Game.ResultType != ResultDeadPosition {
+			t.Errorf("game is %s/%s with winner %q, want a dead position draw", result.Game.Status, result.Game.ResultType, result.Game.Winner)
+		}
+		if IsBoardFull(result.Game.Board) {
+			t.Error("the board filled up before the draw")
+		}
+	})
+
+	t.Run("bot move", func(t *testing.T) {
+		m := newTestManager(Options{})
+		g := m.CreateGame(withBot(&websocket.Conn{}))
+		// The bot plays p2's discs
+		board := deadDraw()
+		for _, row := range board {
+			for col, cell := range row {
+				if cell == "p2" {
+					row[col] = BotID
+				}
+			}
+		}
+		board[0][3] = nil
+		m.mu.Lock()
+		m.games[g.ID].Board = board
+		m.games[g.ID].CurrentPlayer = BotID
+		m.mu.Unlock()
+
+		result := m.BotMakeMove(g.ID, 3)
+		if !result.Success {
+			t.Fatalf("bot move failed: %s", result.Message)
+		}
+		if result.Game.Winner != "draw" || result.Game.ResultType != ResultDeadPosition {
+			t.Errorf("game ended %s with winner %q, want a dead position draw", result.Game.ResultType, result.Game.Winner)
+		}
+	})
+
+	t.Run("pop out", func(t *testing.T) {
+		// Discs can still be popped, so the same position plays on
+		m := newTestManager(Options{PopOut: true})
+		conn1 := &websocket.Conn{}
+		g := m.CreateGame(humans(conn1, &websocket.Conn{}))
+		m.mu.Lock()
+		m.games[g.ID].Board = deadDraw()
+		m.games[g.ID].Board[0][2] = nil
+		m.games[g.ID].CurrentPlayer = "p1"
+		m.mu.Unlock()
+
+		if result := m.MakeMove(g.ID, 2, conn1); !result.Success || result.Game.Status != "active" {
+			t.Errorf("move result = %+v, want the game still active", result)
+		}
+	})
+}
@@ -0,0 +1,83 @@
+package game
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// Heatmap aggregates where discs end up across all finished games. Occupied
+// counts how often each cell is filled on the final board; WinnerOccupied
+// counts how often it holds one of the winner's discs.
+type Heatmap struct {
+	Games          int     `json:"games"`
+	Occupied       [][]int `json:"occupied"`
+	WinnerOccupied [][]int `json:"winnerOccupied"`
+}
+
+// GetHeatmap replays the stored moves of every finished game to rebuild its
+// final board and aggregates the results into a ROWS x COLS grid. Games on
+// other board sizes are left out.
+func (m *Manager) GetHeatmap() (*Heatmap, error) {
+	games, err := m.store.FinishedGames()
+	if err != nil {
+		return nil, err
+	}
+
+	heatmap := &Heatmap{
+		Occupied:       newGrid(),
+		WinnerOccupied: newGrid(),
+	}
+
+	for _, saved := range games {
+		if !saved.Dimensions.IsStandard() {
+			continue
+		}
+		board, err := replayFinalBoard(saved)
+		if err != nil {
+			slog.Warn("Skipping game in heatmap", "gameId", saved.ID, "error", err)
+			continue
+		}
+
+		heatmap.Games++
+		for row := 0; row < ROWS; row++ {
+			for col := 0; col < COLS; col++ {
+				cell := board[row][col]
+				if cell == nil {
+					continue
+				}
+				heatmap.Occupied[row][col]++
+				if cell == saved.Winner {
+					heatmap.WinnerOccupied[row][col]++
+				}
+			}
+		}
+	}
+
+	return heatmap, nil
+}
+
+func replayFinalBoard(saved *SavedGame) ([][]interface{}, error) {
+	board := saved.InitialBoard()
+	for _, move := range saved.Moves {
+		if result := ReplayMove(board, move); !result.Success {
+			return nil, fmt.Errorf("replaying move in column %d: %s", move.Column, result.Message)
+		}
+	}
+	return board, nil
+}
+
+// ReplayMove applies a recorded move, drop or pop out, to board
+func ReplayMove(board [][]interface{}, move Move) *MoveResult {
+	if move.Pop {
+		return PopOut(board, move.Column, move.Player)
+	}
+	return MakeMove(board, move.Column, move.Player)
+}
+
+func newGrid() [][]int {
+	grid := make([][]int, ROWS)
+	for i := range grid {
+		grid[i] = make([]int, COLS)
+	}
+	return grid
+}
@@ -0,0 +1,115 @@
+package game
+
+import (
+	"connect-four/socket"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newTestManager returns a Manager backed by a MemoryStore
+func newTestManager(options Options) *Manager {
+	return NewManager(NewMemoryStore(), nil, options)
+}
+
+// testConn is one WebSocket connection: server is the end the Manager
+// writes to, client the end a test reads from
+type testConn struct {
+	server *websocket.Conn
+	client *websocket.Conn
+}
+
+// dial opens a connection registered with the socket package, as the server
+// does for every client
+func dial(t *testing.T) *testConn {
+	t.Helper()
+	conns := make(chan *websocket.Conn, 1)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		conns <- conn
+	}))
+	t.Cleanup(srv.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	server := <-conns
+	socket.Open(server)
+	t.Cleanup(func() {
+		socket.Close(server)
+		client.Close()
+		server.Close()
+	})
+	return &testConn{server: server, client: client}
+}
+
+// next reads the next message sent to c, failing the test if none arrives
+func (c *testConn) next(t *testing.T) map[string]interface{} {
+	t.Helper()
+	c.client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg map[string]interface{}
+	if err := c.client.ReadJSON(&msg); err != nil {
+		t.Fatalf("reading message: %v", err)
+	}
+	return msg
+}
+
+// nextOfType skips messages until one of the given type arrives
+func (c *testConn) nextOfType(t *testing.T, msgType string) map[string]interface{} {
+	t.Helper()
+	for {
+		if msg := c.next(t); msg["type"] == msgType {
+			return msg
+		}
+	}
+}
+
+// humans returns two human players on the given connections, which may be nil
+func humans(conn1, conn2 *websocket.Conn) (*Player, *Player) {
+	return &Player{ID: "p1", Username: "alice", Conn: conn1},
+		&Player{ID: "p2", Username: "bob", Conn: conn2}
+}
+
+// withBot returns a human player on conn and the bot
+func withBot(conn *websocket.Conn) (*Player, *Player) {
+	return &Player{ID: "p1", Username: "alice", Conn: conn},
+		&Player{ID: BotID, Username: "Bot", IsBot: true}
+}
+
+// recordingAnalytics keeps the events a Manager reports
+type recordingAnalytics struct {
+	mu         sync.Mutex
+	moves      []trackedMove
+	reconnects []string
+}
+
+type trackedMove struct {
+	moverID, username string
+	column, row       int
+}
+
+func (a *recordingAnalytics) TrackGameStart(game *Game) {}
+func (a *recordingAnalytics) TrackGameEnd(game *Game)   {}
+
+func (a *recordingAnalytics) TrackMove(game *Game, moverID, username string, column, row int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.moves = append(a.moves, trackedMove{moverID, username, column, row})
+}
+
+func (a *recordingAnalytics) TrackReconnect(game *Game, playerID, outcome string, elapsed, window time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.reconnects = append(a.reconnects, playerID+":"+outcome)
+}
@@ -0,0 +1,68 @@
+package game
+
+import (
+	"encoding/json"
+	"log/slog"
+	"time"
+)
+
+// ResultAborted ends a game whose board was found corrupted mid-game. It is
+// neither saved nor scored.
+const ResultAborted = "aborted"
+
+// abortedMessage is sent to everyone in a game aborted for a corrupted board
+const abortedMessage = "Sorry, something went wrong with this game and it had to be stopped. It won't count towards your record."
+
+// abortIfCorrupted checks the live board after a move has been applied. A
+// board that breaks the invariants of ValidateBoard can only come from an
+// engine bug, so rather than play on (or panic) the game is aborted: the
+// board and moves are logged, players and spectators are told, and the game
+// is dropped without touching the leaderboard. It reports whether the game
+// was aborted.
+func (m *Manager) abortIfCorrupted(game *Game) bool {
+	err := m.validateLiveBoard(game.Dimensions, game.Board)
+	if err == nil {
+		return false
+	}
+
+	boardJSON, _ := json.Marshal(game.Board)
+	movesJSON, _ := json.Marshal(game.Moves)
+	slog.Error("Aborting game, corrupted board", "gameId", game.ID, "error", err, "board", string(boardJSON), "moves", string(movesJSON))
+
+	game.Status = "aborted"
+	game.Winner = ""
+	game.ResultType = ResultAborted
+	now := time.Now()
+	game.EndedAt = &now
+	game.logEvent("aborted", err.Error())
+	game.end()
+
+	msg := map[string]interface{}{
+		"type":    "error",
+		"code":    "gameAborted",
+		"gameId":  game.ID,
+		"message": abortedMessage,
+	}
+	for _, player := range []*Player{game.Player1, game.Player2} {
+		if !player.IsBot {
+			m.send(player.Conn, msg)
+		}
+	}
+	for _, spectator := range game.Spectators {
+		m.send(spectator, msg)
+	}
+
+	delete(m.games, game.ID)
+	m.closeReconnectWindow(game.ID)
+	return true
+}
+
+// validateLiveBoard checks a board in play against ValidateBoard, or only its
+// shape when pop outs, which legitimately unbalance the piece counts, are on
+func (m *Manager) validateLiveBoard(dimensions Dimensions, board [][]interface{}) error {
+	if m.options.PopOut {
+		_, err := dimensions.validateBoardShape(board)
+		return err
+	}
+	return dimensions.ValidateBoard(board)
+}
@@ -0,0 +1,53 @@
+package game
+
+import "testing"
+
+func TestCorruptedBoardAbortsTheGame(t *testing.T) {
+	store := NewMemoryStore()
+	m := NewManager(store, nil, Options{})
+	conn1, conn2, watcher := dial(t), dial(t), dial(t)
+	g := m.CreateGame(humans(conn1.server, conn2.server))
+	if _, ok := m.AddSpectator(g.ID, watcher.server); !ok {
+		t.Fatal("AddSpectator failed")
+	}
+
+	// An engine bug leaves a disc floating in the top row
+	m.mu.Lock()
+	m.games[g.ID].Board[0][6] = "p2"
+	m.mu.Unlock()
+
+	result := m.MakeMove(g.ID, 0, conn1.server)
+	if !result.Success || result.Game.Status != "aborted" || result.Game.ResultType != ResultAborted {
+		t.Fatalf("move result = %+v, want the game aborted", result)
+	}
+	if result.Game.Winner != "" {
+		t.Errorf("aborted game has winner %q", result.Game.Winner)
+	}
+	if g.Context().Err() == nil {
+		t.Error("aborted game's context not cancelled")
+	}
+	if final := g.Final(); final == nil || final.Status != "aborted" {
+		t.Errorf("Final = %+v, want the aborted game", final)
+	}
+
+	for name, c := range map[string]*testConn{"player 1": conn1, "player 2": conn2, "spectator": watcher} {
+		msg := c.nextOfType(t, "error")
+		if msg["code"] != "gameAborted" || msg["gameId"] != g.ID || msg["message"] != abortedMessage {
+			t.Errorf("%s got %v, want the gameAborted error", name, msg)
+		}
+	}
+
+	if m.GetGame(g.ID) != nil {
+		t.Error("aborted game still held by the manager")
+	}
+	if m.MakeMove(g.ID, 1, conn2.server).Success {
+		t.Error("move accepted on an aborted game")
+	}
+	m.SaveGame(result.Game)
+	if saved, _ := store.LoadGame(g.ID); saved != nil {
+		t.Errorf("aborted game saved: %+v", saved)
+	}
+	if page, _ := store.Leaderboard(LeaderboardQuery{Limit: 10}); page.Total != 0 {
+		t.Errorf("leaderboard has %d entries after an aborted game", page.Total)
+	}
+}
@@ -0,0 +1,71 @@
+package game
+
+import "testing"
+
+func TestResultCounts(t *testing.T) {
+	tests := []struct {
+		name                string
+		winnerID, playerID  string
+		isDraw              bool
+		wins, losses, draws int
+	}{
+		{"won", "p1", "p1", false, 1, 0, 0},
+		{"lost to a human", "p2", "p1", false, 0, 1, 0},
+		{"lost to the bot", BotID, "p2", false, 0, 1, 0},
+		{"drew", "draw", "p2", true, 0, 0, 1},
+	}
+	for _, tt := range tests {
+		wins, losses, draws := resultCounts(tt.winnerID, tt.playerID, tt.isDraw)
+		if wins != tt.wins || losses != tt.losses || draws != tt.draws {
+			t.Errorf("%s: resultCounts = %d, %d, %d, want %d, %d, %d", tt.name, wins, losses, draws, tt.wins, tt.losses, tt.draws)
+		}
+	}
+}
+
+func TestUpdateLeaderboardAttribution(t *testing.T) {
+	alice := &Player{ID: "p1", Username: "alice"}
+	bob := &Player{ID: "p2", Username: "bob"}
+	bot := &Player{ID: BotID, Username: "Bot", IsBot: true}
+
+	// want holds the wins, losses and draws each username should end up with
+	tests := []struct {
+		name             string
+		player1, player2 *Player
+		winner           string
+		want             map[string][3]int
+	}{
+		{"player 1 beats a human", alice, bob, "p1", map[string][3]int{"alice": {1, 0, 0}, "bob": {0, 1, 0}}},
+		{"player 2 beats a human", alice, bob, "p2", map[string][3]int{"alice": {0, 1, 0}, "bob": {1, 0, 0}}},
+		{"humans draw", alice, bob, "draw", map[string][3]int{"alice": {0, 0, 1}, "bob": {0, 0, 1}}},
+		{"bot beats player 1", alice, bot, BotID, map[string][3]int{"alice": {0, 1, 0}}},
+		{"bot beats player 2", bot, bob, BotID, map[string][3]int{"bob": {0, 1, 0}}},
+		{"player 1 beats the bot", alice, bot, "p1", map[string][3]int{"alice": {1, 0, 0}}},
+		{"player 2 beats the bot", bot, bob, "p2", map[string][3]int{"bob": {1, 0, 0}}},
+		{"draw with the bot", alice, bot, "draw", map[string][3]int{"alice": {0, 0, 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			m := NewManager(store, nil, Options{})
+			m.UpdateLeaderboard(&Game{ID: "game", Player1: tt.player1, Player2: tt.player2, Winner: tt.winner, Status: "finished"})
+
+			page, err := store.Leaderboard(LeaderboardQuery{Limit: 10})
+			if err != nil {
+				t.Fatalf("Leaderboard: %v", err)
+			}
+			if len(page.Entries) != len(tt.want) {
+				t.Fatalf("leaderboard = %+v, want rows for %v", page.Entries, tt.want)
+			}
+			for _, entry := range page.Entries {
+				want, ok := tt.want[entry.Username]
+				if !ok {
+					t.Errorf("unexpected row for %s", entry.Username)
+					continue
+				}
+				if got := [3]int{entry.Wins, entry.Losses, entry.Draws}; got != want {
+					t.Errorf("%s: wins, losses, draws = %v, want %v", entry.Username, got, want)
+				}
+			}
+		})
+	}
+}
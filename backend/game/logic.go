@@ -1,5 +1,10 @@
 package game
 
+import (
+	"fmt"
+	"hash/crc32"
+)
+
 const (
 	ROWS      = 6
 	COLS      = 7
@@ -9,93 +14,211 @@ const (
 type MoveResult struct {
 	Success bool
 	Message string
-	Row     int
+	// Code is CodeInvalidColumn or CodeColumnFull for a rejected move
+	Code string
+	Row  int
 }
 
 type WinResult struct {
 	Won       bool
 	Direction string
+	// Cells holds the [row, col] of the winning run, exactly as long as the
+	// win length and always including the checked disc
+	Cells [][2]int
 }
 
+// CreateBoard returns an empty standard board
 func CreateBoard() [][]interface{} {
-	board := make([][]interface{}, ROWS)
-	for i := range board {
-		board[i] = make([]interface{}, COLS)
-	}
-	return board
+	return StandardDimensions.CreateBoard()
 }
 
+// MakeMove, PopOut and the other functions that only need the board's size
+// take it from the board itself, so they work on boards of any Dimensions.
+
 func MakeMove(board [][]interface{}, column int, playerID interface{}) *MoveResult {
-	if column < 0 || column >= COLS {
-		return &MoveResult{Success: false, Message: "Invalid column"}
+	if column < 0 || column >= len(board[0]) {
+		return &MoveResult{Success: false, Message: "Invalid column", Code: CodeInvalidColumn}
 	}
 
 	// Find the lowest available row in the column
-	for row := ROWS - 1; row >= 0; row-- {
+	for row := len(board) - 1; row >= 0; row-- {
 		if board[row][column] == nil {
 			board[row][column] = playerID
 			return &MoveResult{Success: true, Row: row}
 		}
 	}
 
-	return &MoveResult{Success: false, Message: "Column is full"}
+	return &MoveResult{Success: false, Message: "Column is full", Code: CodeColumnFull}
+}
+
+// PopOut removes playerID's disc from the bottom of column and drops the
+// rest of the column by one row (Pop Out variant). It fails on an empty
+// column and unless the bottom disc belongs to playerID.
+func PopOut(board [][]interface{}, column int, playerID interface{}) *MoveResult {
+	if column < 0 || column >= len(board[0]) {
+		return &MoveResult{Success: false, Message: "Invalid column", Code: CodeInvalidColumn}
+	}
+	bottom := len(board) - 1
+	if board[bottom][column] == nil {
+		return &MoveResult{Success: false, Message: "Column is empty"}
+	}
+	if board[bottom][column] != playerID {
+		return &MoveResult{Success: false, Message: "You can only pop out your own disc"}
+	}
+
+	for row := bottom; row > 0; row-- {
+		board[row][column] = board[row-1][column]
+	}
+	board[0][column] = nil
+	return &MoveResult{Success: true, Row: bottom}
+}
+
+// CheckAllWins scans the whole board for four in a row by either player.
+// Needed after actions such as a pop out that move many discs at once and
+// can complete lines for both sides.
+func (d Dimensions) CheckAllWins(board [][]interface{}, player1, player2 interface{}) (p1Won, p2Won bool) {
+	if d.fitsBitboard() {
+		b := d.NewBitboard(board, player1, player2)
+		return d.HasLine(b.Discs[0]), d.HasLine(b.Discs[1])
+	}
+
+	for row := 0; row < d.Rows; row++ {
+		for col := 0; col < d.Cols; col++ {
+			cell := board[row][col]
+			if cell == nil || (cell == player1 && p1Won) || (cell == player2 && p2Won) {
+				continue
+			}
+			if d.CheckWin(board, row, col).Won {
+				if cell == player1 {
+					p1Won = true
+				} else if cell == player2 {
+					p2Won = true
+				}
+			}
+		}
+	}
+	return p1Won, p2Won
+}
+
+// CanAnyoneStillWin reports whether a standard board still has a line of
+// four that either player could complete; see Dimensions.CanAnyoneStillWin
+func CanAnyoneStillWin(board [][]interface{}, player1, player2 interface{}) bool {
+	return StandardDimensions.CanAnyoneStillWin(board, player1, player2)
+}
+
+// CanAnyoneStillWin reports whether some run of WinLength cells holds discs
+// of at most one player, so it could still be completed. If not, the game
+// can only end in a draw, however many empty cells are left.
+func (d Dimensions) CanAnyoneStillWin(board [][]interface{}, player1, player2 interface{}) bool {
+	if d.fitsBitboard() {
+		b := d.NewBitboard(board, player1, player2)
+		for _, mask := range d.lineMasks() {
+			if b.Discs[0]&mask == 0 || b.Discs[1]&mask == 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	for row := 0; row < d.Rows; row++ {
+		for col := 0; col < d.Cols; col++ {
+			for _, delta := range [][2]int{{0, 1}, {1, 0}, {1, 1}, {1, -1}} {
+				endRow, endCol := row+(d.WinLength-1)*delta[0], col+(d.WinLength-1)*delta[1]
+				if endRow >= d.Rows || endCol < 0 || endCol >= d.Cols {
+					continue
+				}
+				has1, has2 := false, false
+				for i := 0; i < d.WinLength; i++ {
+					switch board[row+i*delta[0]][col+i*delta[1]] {
+					case player1:
+						has1 = true
+					case player2:
+						has2 = true
+					}
+				}
+				if !has1 || !has2 {
+					return true
+				}
+			}
+		}
+	}
+	return false
 }
 
+// CheckWin checks for four in a row through the disc at (row, col) on a
+// standard board
 func CheckWin(board [][]interface{}, row, col int) *WinResult {
+	return StandardDimensions.CheckWin(board, row, col)
+}
+
+// CheckWin checks for a run of WinLength through the disc at (row, col)
+func (d Dimensions) CheckWin(board [][]interface{}, row, col int) *WinResult {
 	playerID := board[row][col]
 	if playerID == nil {
 		return &WinResult{Won: false}
 	}
 
 	// Check horizontal
-	if checkDirection(board, row, col, 0, 1, playerID) {
-		return &WinResult{Won: true, Direction: "horizontal"}
+	if cells := d.checkDirection(board, row, col, 0, 1, playerID); cells != nil {
+		return &WinResult{Won: true, Direction: "horizontal", Cells: cells}
 	}
 
 	// Check vertical
-	if checkDirection(board, row, col, 1, 0, playerID) {
-		return &WinResult{Won: true, Direction: "vertical"}
+	if cells := d.checkDirection(board, row, col, 1, 0, playerID); cells != nil {
+		return &WinResult{Won: true, Direction: "vertical", Cells: cells}
 	}
 
 	// Check diagonal (top-left to bottom-right)
-	if checkDirection(board, row, col, 1, 1, playerID) {
-		return &WinResult{Won: true, Direction: "diagonal"}
+	if cells := d.checkDirection(board, row, col, 1, 1, playerID); cells != nil {
+		return &WinResult{Won: true, Direction: "diagonal", Cells: cells}
 	}
 
 	// Check diagonal (top-right to bottom-left)
-	if checkDirection(board, row, col, 1, -1, playerID) {
-		return &WinResult{Won: true, Direction: "diagonal"}
+	if cells := d.checkDirection(board, row, col, 1, -1, playerID); cells != nil {
+		return &WinResult{Won: true, Direction: "diagonal", Cells: cells}
 	}
 
 	return &WinResult{Won: false}
 }
 
-func checkDirection(board [][]interface{}, startRow, startCol, deltaRow, deltaCol int, playerID interface{}) bool {
-	count := 1 // Count the current piece
+// checkDirection walks the run of playerID's discs through the start cell
+// along (deltaRow, deltaCol). It returns WinLength cells of the run that
+// include the start cell, or nil if the run is too short.
+func (d Dimensions) checkDirection(board [][]interface{}, startRow, startCol, deltaRow, deltaCol int, playerID interface{}) [][2]int {
+	// Walk back to the start of the run in the negative direction
+	row := startRow
+	col := startCol
+	for row-deltaRow >= 0 && row-deltaRow < d.Rows && col-deltaCol >= 0 && col-deltaCol < d.Cols && board[row-deltaRow][col-deltaCol] == playerID {
+		row -= deltaRow
+		col -= deltaCol
+	}
 
-	// Check in positive direction
-	row := startRow + deltaRow
-	col := startCol + deltaCol
-	for row >= 0 && row < ROWS && col >= 0 && col < COLS && board[row][col] == playerID {
-		count++
+	// Record the run in the positive direction
+	var run [][2]int
+	startIndex := 0
+	for row >= 0 && row < d.Rows && col >= 0 && col < d.Cols && board[row][col] == playerID {
+		if row == startRow && col == startCol {
+			startIndex = len(run)
+		}
+		run = append(run, [2]int{row, col})
 		row += deltaRow
 		col += deltaCol
 	}
 
-	// Check in negative direction
-	row = startRow - deltaRow
-	col = startCol - deltaCol
-	for row >= 0 && row < ROWS && col >= 0 && col < COLS && board[row][col] == playerID {
-		count++
-		row -= deltaRow
-		col -= deltaCol
+	if len(run) < d.WinLength {
+		return nil
 	}
 
-	return count >= WIN_LENGTH
+	// A longer run is trimmed to WinLength cells, keeping the start cell
+	first := startIndex
+	if first > len(run)-d.WinLength {
+		first = len(run) - d.WinLength
+	}
+	return run[first : first+d.WinLength]
 }
 
 func IsBoardFull(board [][]interface{}) bool {
-	for col := 0; col < COLS; col++ {
+	for col := range board[0] {
 		if board[0][col] == nil {
 			return false
 		}
@@ -103,9 +226,157 @@ func IsBoardFull(board [][]interface{}) bool {
 	return true
 }
 
+// ValidateBoard checks a standard board; see Dimensions.ValidateBoard
+func ValidateBoard(board [][]interface{}) error {
+	return StandardDimensions.ValidateBoard(board)
+}
+
+// MaxHandicap is the most extra discs a handicap position may give one
+// player
+const MaxHandicap = 6
+
+// ValidateHandicapBoard checks a handicap starting position on the standard
+// board. A handicap gives one player extra discs, so instead of the balanced
+// piece counts of ValidateBoard the players may differ by up to MaxHandicap
+// discs; the other invariants still apply.
+func ValidateHandicapBoard(board [][]interface{}) error {
+	counts, err := StandardDimensions.validateBoardShape(board)
+	if err != nil {
+		return err
+	}
+
+	// validateBoardShape allows at most two players
+	var totals [2]int
+	i := 0
+	for _, count := range counts {
+		totals[i] = count
+		i++
+	}
+	if handicap := abs(totals[0] - totals[1]); handicap > MaxHandicap {
+		return fmt.Errorf("handicap of %d discs is more than the %d allowed", handicap, MaxHandicap)
+	}
+	return nil
+}
+
+// ValidateBoard checks the invariants every reachable position satisfies:
+// correct dimensions, no floating discs, at most two players on the board and
+// piece counts that differ by no more than one.
+func (d Dimensions) ValidateBoard(board [][]interface{}) error {
+	counts, err := d.validateBoardShape(board)
+	if err != nil {
+		return err
+	}
+
+	var totals []int
+	for _, count := range counts {
+		totals = append(totals, count)
+	}
+	if len(totals) == 2 && abs(totals[0]-totals[1]) > 1 {
+		return fmt.Errorf("unbalanced piece counts %d and %d", totals[0], totals[1])
+	}
+	if len(totals) == 1 && totals[0] > 1 {
+		return fmt.Errorf("single player has %d pieces", totals[0])
+	}
+
+	return nil
+}
+
+// validateBoardShape checks the invariants that hold even in Pop Out, where
+// piece counts drift apart: dimensions, no floating discs and at most two
+// players. It returns each player's piece count.
+func (d Dimensions) validateBoardShape(board [][]interface{}) (map[interface{}]int, error) {
+	if len(board) != d.Rows {
+		return nil, fmt.Errorf("board has %d rows, expected %d", len(board), d.Rows)
+	}
+	for row := range board {
+		if len(board[row]) != d.Cols {
+			return nil, fmt.Errorf("row %d has %d columns, expected %d", row, len(board[row]), d.Cols)
+		}
+	}
+
+	counts := make(map[interface{}]int)
+	for col := 0; col < d.Cols; col++ {
+		filled := false
+		for row := 0; row < d.Rows; row++ {
+			cell := board[row][col]
+			if cell == nil {
+				if filled {
+					return nil, fmt.Errorf("floating disc above empty cell at row %d, column %d", row, col)
+				}
+				continue
+			}
+			filled = true
+			counts[cell]++
+		}
+	}
+
+	if len(counts) > 2 {
+		return nil, fmt.Errorf("board has pieces from %d players", len(counts))
+	}
+
+	return counts, nil
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// BoardChecksum returns a CRC-32 (IEEE) of the board as a row-major string,
+// top row first, with '0' for empty cells, '1' for player1 and '2' for
+// player2. It only depends on which seat owns each cell, so clients can
+// recompute it from the board they render.
+func BoardChecksum(board [][]interface{}, player1ID, player2ID interface{}) string {
+	cells := make([]byte, 0, len(board)*len(board[0]))
+	for _, row := range board {
+		for _, cell := range row {
+			switch {
+			case cell == nil:
+				cells = append(cells, '0')
+			case cell == player1ID:
+				cells = append(cells, '1')
+			case cell == player2ID:
+				cells = append(cells, '2')
+			default:
+				cells = append(cells, '?')
+			}
+		}
+	}
+	return fmt.Sprintf("%08x", crc32.ChecksumIEEE(cells))
+}
+
+// MovesRemaining counts the empty cells left on the board
+func MovesRemaining(board [][]interface{}) int {
+	remaining := 0
+	for _, row := range board {
+		for _, cell := range row {
+			if cell == nil {
+				remaining++
+			}
+		}
+	}
+	return remaining
+}
+
 func GetValidMoves(board [][]interface{}) []int {
 	validMoves := []int{}
-	for col := 0; col < COLS; col++ {
+	for col := range board[0] {
+		if board[0][col] == nil {
+			validMoves = append(validMoves, col)
+		}
+	}
+	return validMoves
+}
+
+// GetValidMovesCenterFirst returns the same columns as GetValidMoves ordered
+// from the center outwards (3, 2, 4, 1, 5, 0, 6 on a 7-column board). Search
+// visits strong moves first in this order, and equal scores break toward the
+// center the same way every time.
+func GetValidMovesCenterFirst(board [][]interface{}) []int {
+	validMoves := []int{}
+	for _, col := range centerOutColumns(len(board[0])) {
 		if board[0][col] == nil {
 			validMoves = append(validMoves, col)
 		}
@@ -113,36 +384,68 @@ func GetValidMoves(board [][]interface{}) []int {
 	return validMoves
 }
 
+// CenterOutColumns lists every column of a standard board from the center
+// outwards, the left one first at equal distance
+func CenterOutColumns() []int {
+	return centerOutColumns(COLS)
+}
+
+func centerOutColumns(cols int) []int {
+	center := (cols - 1) / 2
+	columns := []int{center}
+	for offset := 1; len(columns) < cols; offset++ {
+		if center-offset >= 0 {
+			columns = append(columns, center-offset)
+		}
+		if center+offset < cols {
+			columns = append(columns, center+offset)
+		}
+	}
+	return columns
+}
+
+// EvaluatePosition scores a standard board for playerID
 func EvaluatePosition(board [][]interface{}, playerID, opponentID interface{}) int {
+	return StandardDimensions.EvaluatePosition(board, playerID, opponentID)
+}
+
+// EvaluatePosition scores the board for playerID from every possible
+// winning run, positive when playerID is ahead. Boards that fit a bitboard
+// are scored on one, which gives the same result as walking the cells.
+func (d Dimensions) EvaluatePosition(board [][]interface{}, playerID, opponentID interface{}) int {
+	if d.fitsBitboard() {
+		return d.evaluateBitboard(d.NewBitboard(board, playerID, opponentID))
+	}
+
 	score := 0
 
-	// Check all possible 4-in-a-row positions
-	for row := 0; row < ROWS; row++ {
-		for col := 0; col < COLS; col++ {
+	// Check all possible winning runs
+	for row := 0; row < d.Rows; row++ {
+		for col := 0; col < d.Cols; col++ {
 			// Horizontal
-			score += evaluateLine(board, row, col, 0, 1, playerID, opponentID)
+			score += d.evaluateLine(board, row, col, 0, 1, playerID, opponentID)
 			// Vertical
-			score += evaluateLine(board, row, col, 1, 0, playerID, opponentID)
+			score += d.evaluateLine(board, row, col, 1, 0, playerID, opponentID)
 			// Diagonal \
-			score += evaluateLine(board, row, col, 1, 1, playerID, opponentID)
+			score += d.evaluateLine(board, row, col, 1, 1, playerID, opponentID)
 			// Diagonal /
-			score += evaluateLine(board, row, col, 1, -1, playerID, opponentID)
+			score += d.evaluateLine(board, row, col, 1, -1, playerID, opponentID)
 		}
 	}
 
 	return score
 }
 
-func evaluateLine(board [][]interface{}, startRow, startCol, deltaRow, deltaCol int, playerID, opponentID interface{}) int {
+func (d Dimensions) evaluateLine(board [][]interface{}, startRow, startCol, deltaRow, deltaCol int, playerID, opponentID interface{}) int {
 	playerCount := 0
 	opponentCount := 0
 	emptyCount := 0
 
-	for i := 0; i < WIN_LENGTH; i++ {
+	for i := 0; i < d.WinLength; i++ {
 		row := startRow + i*deltaRow
 		col := startCol + i*deltaCol
 
-		if row < 0 || row >= ROWS || col < 0 || col >= COLS {
+		if row < 0 || row >= d.Rows || col < 0 || col >= d.Cols {
 			return 0 // Out of bounds
 		}
 
@@ -156,27 +459,32 @@ func evaluateLine(board [][]interface{}, startRow, startCol, deltaRow, deltaCol
 		}
 	}
 
-	// Scoring
+	return d.scoreLine(playerCount, opponentCount, emptyCount)
+}
+
+// scoreLine scores one run of WinLength cells from how many hold each
+// player's discs and how many are empty
+func (d Dimensions) scoreLine(playerCount, opponentCount, emptyCount int) int {
 	if opponentCount > 0 && playerCount > 0 {
 		return 0 // Blocked line
 	}
 
-	if playerCount == WIN_LENGTH {
+	if playerCount == d.WinLength {
 		return 10000 // Win
 	}
-	if opponentCount == WIN_LENGTH {
+	if opponentCount == d.WinLength {
 		return -10000 // Opponent wins (should be blocked)
 	}
-	if opponentCount == WIN_LENGTH-1 && emptyCount == 1 {
+	if opponentCount == d.WinLength-1 && emptyCount == 1 {
 		return -1000 // Opponent about to win (must block)
 	}
-	if playerCount == WIN_LENGTH-1 && emptyCount == 1 {
+	if playerCount == d.WinLength-1 && emptyCount == 1 {
 		return 1000 // Bot about to win
 	}
-	if playerCount == WIN_LENGTH-2 && emptyCount == 2 {
+	if playerCount == d.WinLength-2 && emptyCount == 2 {
 		return 100 // Potential win
 	}
-	if opponentCount == WIN_LENGTH-2 && emptyCount == 2 {
+	if opponentCount == d.WinLength-2 && emptyCount == 2 {
 		return -100 // Opponent potential win
 	}
 
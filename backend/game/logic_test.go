@@ -0,0 +1,288 @@
+package game
+
+import (
+	"strings"
+	"testing"
+)
+
+// boardFromRows builds a standard board from rows drawn top to bottom with
+// '.' for empty, 'X' for p1 and 'O' for p2
+func boardFromRows(rows ...string) [][]interface{} {
+	board := CreateBoard()
+	offset := ROWS - len(rows)
+	for i, row := range rows {
+		for col, cell := range row {
+			switch cell {
+			case 'X':
+				board[offset+i][col] = "p1"
+			case 'O':
+				board[offset+i][col] = "p2"
+			}
+		}
+	}
+	return board
+}
+
+func TestValidateHandicapBoard(t *testing.T) {
+	tests := []struct {
+		name    string
+		board   [][]interface{}
+		wantErr string
+	}{
+		{"empty", CreateBoard(), ""},
+		{"three extra discs", boardFromRows("X.X.X.."), ""},
+		{"the most allowed", boardFromRows("X.X....", "XXXX..."), ""},
+		{"extra discs for the second player", boardFromRows("O.O.O.X"), ""},
+		{"too many", boardFromRows("X.X.X..", "XXXXX.."), "handicap of 8 discs"},
+		{"floating disc", boardFromRows("X......", "......."), "floating disc"},
+		{"three players", func() [][]interface{} {
+			board := boardFromRows("XO.....")
+			board[ROWS-1][2] = "p3"
+			return board
+		}(), "3 players"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateHandicapBoard(tt.board)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("got %v, want nil", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("got %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMovesRemainingOnANearlyFullBoard(t *testing.T) {
+	board := boardFromRows(
+		"XOX.XOX",
+		"XOXOXOX",
+		"OXOXOXO",
+		"OXOXOXO",
+		"XOXOXOX",
+		"XOXOXOX",
+	)
+	if got := MovesRemaining(board); got != 1 {
+		t.Fatalf("MovesRemaining = %d, want 1", got)
+	}
+	if IsBoardFull(board) {
+		t.Fatal("IsBoardFull = true with a cell left")
+	}
+	if moves := GetValidMoves(board); len(moves) != 1 || moves[0] != 3 {
+		t.Fatalf("GetValidMoves = %v, want [3]", moves)
+	}
+
+	if result := MakeMove(board, 3, "p2"); !result.Success {
+		t.Fatalf("last move failed: %s", result.Message)
+	}
+	if got := MovesRemaining(board); got != 0 {
+		t.Errorf("MovesRemaining = %d on a full board", got)
+	}
+	if !IsBoardFull(board) {
+		t.Error("IsBoardFull = false once the last cell is filled")
+	}
+	if got := MovesRemaining(CreateBoard()); got != ROWS*COLS {
+		t.Errorf("MovesRemaining = %d on an empty board, want %d", got, ROWS*COLS)
+	}
+}
+
+// doubleWinBeforePop is a position where p1 popping out column 0 drops a
+// disc into each player's row of three, completing lines for both
+func doubleWinBeforePop() [][]interface{} {
+	return boardFromRows(
+		"O......",
+		"XOOO...",
+		"OXXX...",
+		"XOXO...",
+	)
+}
+
+func TestCheckAllWinsAfterADoubleWinPop(t *testing.T) {
+	board := doubleWinBeforePop()
+	if p1Won, p2Won := StandardDimensions.CheckAllWins(board, "p1", "p2"); p1Won || p2Won {
+		t.Fatalf("before the pop: CheckAllWins = %v, %v, want no wins", p1Won, p2Won)
+	}
+	if result := PopOut(board, 0, "p1"); !result.Success {
+		t.Fatalf("pop out failed: %s", result.Message)
+	}
+	if p1Won, p2Won := StandardDimensions.CheckAllWins(board, "p1", "p2"); !p1Won || !p2Won {
+		t.Errorf("after the pop: CheckAllWins = %v, %v, want both", p1Won, p2Won)
+	}
+
+	// Larger boards take the cell-by-cell scan instead of bitboards
+	wide := Dimensions{Rows: 6, Cols: 12, WinLength: 4}
+	board = wide.CreateBoard()
+	for row, cells := range doubleWinBeforePop() {
+		copy(board[row], cells)
+	}
+	PopOut(board, 0, "p1")
+	if p1Won, p2Won := wide.CheckAllWins(board, "p1", "p2"); !p1Won || !p2Won {
+		t.Errorf("on a %dx%d board: CheckAllWins = %v, %v, want both", wide.Rows, wide.Cols, p1Won, p2Won)
+	}
+}
+
+// deadDraw is a drawn position with three cells still empty: every run of
+// four, including those through the gaps in the top row, already holds
+// discs of both players
+func deadDraw() [][]interface{} {
+	return boardFromRows(
+		"..XO.OX",
+		"XOXOXOX",
+		"OXOXOXO",
+		"OXOXOXO",
+		"XOXOXOX",
+		"XOXOXOX",
+	)
+}
+
+func TestCanAnyoneStillWin(t *testing.T) {
+	openTop := deadDraw()
+	openTop[0][2] = nil
+
+	tests := []struct {
+		name  string
+		board [][]interface{}
+		want  bool
+	}{
+		{"empty board", CreateBoard(), true},
+		{"dead with three cells left", deadDraw(), false},
+		{"top row run open to p2", openTop, true},
+		{"column run open to p1", boardFromRows(
+			"...O.OX",
+			"..XOXOX",
+			"XOXOXOX",
+			"OXOXOXO",
+			"OXOXOXO",
+			"XOXOXOX",
+		), true},
+	}
+	for _, tt := range tests {
+		if got := CanAnyoneStillWin(tt.board, "p1", "p2"); got != tt.want {
+			t.Errorf("%s: CanAnyoneStillWin = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Larger boards take the cell-by-cell scan instead of bitboards
+	wide := Dimensions{Rows: 6, Cols: 12, WinLength: 4}
+	// The same pattern as deadDraw's lower rows: discs alternate along each
+	// row, and the middle two rows start with p2
+	board := wide.CreateBoard()
+	for row := range board {
+		for col := range board[row] {
+			board[row][col] = "p1"
+			if (col+row/2)%2 == 1 {
+				board[row][col] = "p2"
+			}
+		}
+	}
+	board[0][0], board[0][1] = nil, nil
+	if wide.CanAnyoneStillWin(board, "p1", "p2") {
+		t.Errorf("on a %dx%d board: dead position reported as winnable", wide.Rows, wide.Cols)
+	}
+}
+
+// fourThrough reports, by brute force, whether the disc at (row, col) is
+// part of WIN_LENGTH in a row
+func fourThrough(board [][]interface{}, row, col int) bool {
+	player := board[row][col]
+	for _, delta := range [][2]int{{0, 1}, {1, 0}, {1, 1}, {1, -1}} {
+		for start := -(WIN_LENGTH - 1); start <= 0; start++ {
+			count := 0
+			for i := start; i < start+WIN_LENGTH; i++ {
+				r, c := row+i*delta[0], col+i*delta[1]
+				if r < 0 || r >= ROWS || c < 0 || c >= COLS || board[r][c] != player {
+					break
+				}
+				count++
+			}
+			if count == WIN_LENGTH {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FuzzBoard plays the columns in each input (byte % 8, so column 7 is out
+// of range) for two alternating players and checks the engine's invariants
+// after every move
+func FuzzBoard(f *testing.F) {
+	f.Add([]byte{3, 3, 4, 4, 5, 5, 6})
+	f.Add([]byte{0, 0, 0, 0, 0, 0, 0, 1})
+	f.Add([]byte{0, 1, 1, 2, 2, 3, 2, 3, 3, 5, 3})
+	f.Add([]byte{7, 255, 3, 10})
+	f.Add([]byte{0, 1, 2, 3, 4, 5, 6, 0, 1, 2, 3, 4, 5, 6, 1, 0, 3, 2, 5, 4, 6})
+
+	f.Fuzz(func(t *testing.T, columns []byte) {
+		board := CreateBoard()
+		players := []string{"p1", "p2"}
+		turn := 0
+
+		for _, b := range columns {
+			column := int(b % 8)
+			player := players[turn%2]
+			before := MovesRemaining(board)
+
+			result := MakeMove(board, column, player)
+			if !result.Success {
+				switch {
+				case column >= COLS && result.Code != CodeInvalidColumn:
+					t.Fatalf("column %d: got code %q, want %q", column, result.Code, CodeInvalidColumn)
+				case column < COLS && (result.Code != CodeColumnFull || board[0][column] == nil):
+					t.Fatalf("column %d rejected with %q but has room", column, result.Code)
+				}
+				if MovesRemaining(board) != before {
+					t.Fatal("a rejected move changed the board")
+				}
+				continue
+			}
+			turn++
+
+			if board[result.Row][column] != player || (result.Row < ROWS-1 && board[result.Row+1][column] == nil) {
+				t.Fatalf("disc for column %d did not land on top of the column (row %d)", column, result.Row)
+			}
+			// No floating discs, balanced piece counts
+			if err := ValidateBoard(board); err != nil {
+				t.Fatalf("after %d moves: %v", turn, err)
+			}
+			if MovesRemaining(board) != before-1 {
+				t.Fatalf("MovesRemaining went from %d to %d", before, MovesRemaining(board))
+			}
+			if IsBoardFull(board) != (len(GetValidMoves(board)) == 0) {
+				t.Fatalf("IsBoardFull = %v with valid moves %v", IsBoardFull(board), GetValidMoves(board))
+			}
+
+			win := CheckWin(board, result.Row, column)
+			if win.Won != fourThrough(board, result.Row, column) {
+				t.Fatalf("CheckWin = %v at (%d, %d), brute force disagrees", win.Won, result.Row, column)
+			}
+			if !win.Won {
+				continue
+			}
+			if len(win.Cells) != WIN_LENGTH {
+				t.Fatalf("winning run has %d cells", len(win.Cells))
+			}
+			found := false
+			for i, cell := range win.Cells {
+				if board[cell[0]][cell[1]] != player {
+					t.Fatalf("winning cell %v isn't the winner's", cell)
+				}
+				if cell == [2]int{result.Row, column} {
+					found = true
+				}
+				if i > 0 {
+					dr, dc := cell[0]-win.Cells[i-1][0], cell[1]-win.Cells[i-1][1]
+					step := [2]int{win.Cells[1][0] - win.Cells[0][0], win.Cells[1][1] - win.Cells[0][1]}
+					if dr < -1 || dr > 1 || dc < -1 || dc > 1 || (dr == 0 && dc == 0) || [2]int{dr, dc} != step {
+						t.Fatalf("winning cells %v aren't a contiguous line", win.Cells)
+					}
+				}
+			}
+			if !found {
+				t.Fatalf("winning cells %v don't include the last disc", win.Cells)
+			}
+			return
+		}
+	})
+}
@@ -0,0 +1,138 @@
+package game
+
+import (
+	"fmt"
+
+	"github.com/gorilla/websocket"
+)
+
+// Requests a player can make of their opponent. Each kind is rate limited per
+// player so it can't be spammed every turn.
+const (
+	RequestDraw = "draw"
+	RequestUndo = "takeback"
+)
+
+// DefaultRequestCooldownMoves is how many moves must be played between two
+// requests of the same kind from the same player
+const DefaultRequestCooldownMoves = 3
+
+// requestCooldownMessage explains why playerID can't make a request of this
+// kind yet, or returns "" if their last one was at least
+// RequestCooldownMoves moves ago
+func (m *Manager) requestCooldownMessage(game *Game, playerID, kind string) string {
+	last, requested := game.lastRequests[kind+":"+playerID]
+	if !requested {
+		return ""
+	}
+	if wait := last + m.options.RequestCooldownMoves - len(game.Moves); wait > 0 {
+		return fmt.Sprintf("Too soon for another %s request, wait %d more moves", kind, wait)
+	}
+	return ""
+}
+
+func (g *Game) noteRequest(playerID, kind string) {
+	if g.lastRequests == nil {
+		g.lastRequests = make(map[string]int)
+	}
+	g.lastRequests[kind+":"+playerID] = len(g.Moves)
+}
+
+// playerByConn returns the human player in the game using conn, or nil
+func (g *Game) playerByConn(conn *websocket.Conn) *Player {
+	if g.Player1.Conn == conn && !g.Player1.IsBot {
+		return g.Player1
+	}
+	if g.Player2.Conn == conn && !g.Player2.IsBot {
+		return g.Player2
+	}
+	return nil
+}
+
+// Opponent returns the other player in the game
+func (g *Game) Opponent(player *Player) *Player {
+	if player == g.Player1 {
+		return g.Player2
+	}
+	return g.Player1
+}
+
+// OfferDraw records a draw offer from the player on conn. The offer stays
+// pending until the opponent answers with RespondDraw.
+func (m *Manager) OfferDraw(gameID string, conn *websocket.Conn) *GameMoveResult {
+	m.mu.Lock()
+	defer m.unlock()
+
+	game, exists := m.games[gameID]
+	if !exists {
+		return &GameMoveResult{Success: false, Message: "Game not found", Code: CodeGameNotFound}
+	}
+	if game.Status != "active" {
+		return &GameMoveResult{Success: false, Message: "Game is not active", Code: CodeGameNotActive}
+	}
+
+	player := game.playerByConn(conn)
+	if player == nil {
+		return &GameMoveResult{Success: false, Message: "Not a player in this game", Code: CodeNotYourGame}
+	}
+	if game.Opponent(player).IsBot {
+		return &GameMoveResult{Success: false, Message: "The bot does not accept draws"}
+	}
+	if game.DrawOfferBy == player.ID {
+		return &GameMoveResult{Success: false, Message: "Your draw offer is still waiting for an answer"}
+	}
+	if message := m.requestCooldownMessage(game, player.ID, RequestDraw); message != "" {
+		return &GameMoveResult{Success: false, Message: message}
+	}
+
+	game.DrawOfferBy = player.ID
+	game.noteRequest(player.ID, RequestDraw)
+	game.logEvent("drawOffered", "player="+player.ID)
+
+	return &GameMoveResult{Success: true, Game: game.snapshot()}
+}
+
+// withdrawDrawOffer drops playerID's pending draw offer, if they have one
+func (g *Game) withdrawDrawOffer(playerID string) {
+	if g.DrawOfferBy == playerID {
+		g.DrawOfferBy = ""
+		g.logEvent("drawWithdrawn", "player="+playerID)
+	}
+}
+
+// RespondDraw answers the pending draw offer. Accepting ends the game as a
+// draw, scored and saved like any other draw.
+func (m *Manager) RespondDraw(gameID string, conn *websocket.Conn, accept bool) *GameMoveResult {
+	result, finished := m.locked(func() *GameMoveResult { return m.respondDraw(gameID, conn, accept) })
+	if finished {
+		m.finalize(result.Game)
+	}
+	return result
+}
+
+func (m *Manager) respondDraw(gameID string, conn *websocket.Conn, accept bool) *GameMoveResult {
+	game, exists := m.games[gameID]
+	if !exists {
+		return &GameMoveResult{Success: false, Message: "Game not found", Code: CodeGameNotFound}
+	}
+	if game.Status != "active" {
+		return &GameMoveResult{Success: false, Message: "Game is not active", Code: CodeGameNotActive}
+	}
+
+	player := game.playerByConn(conn)
+	if player == nil {
+		return &GameMoveResult{Success: false, Message: "Not a player in this game", Code: CodeNotYourGame}
+	}
+	if game.DrawOfferBy == "" || game.DrawOfferBy == player.ID {
+		return &GameMoveResult{Success: false, Message: "No draw offer to respond to"}
+	}
+
+	game.DrawOfferBy = ""
+	if !accept {
+		game.logEvent("drawDeclined", "player="+player.ID)
+		return &GameMoveResult{Success: true, Game: game}
+	}
+
+	game.finishWithResult("draw", ResultDrawAgreed)
+	return &GameMoveResult{Success: true, Game: game}
+}
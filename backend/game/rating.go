@@ -0,0 +1,60 @@
+package game
+
+import (
+	"log/slog"
+	"math"
+)
+
+// DefaultRating is the Elo rating of a player who has no rated games yet
+const DefaultRating = 1200
+
+// ratingK is the most one game can move a rating
+const ratingK = 32
+
+// ratingChange is the Elo adjustment for a player rated rating after a game
+// against one rated opponent, where score is 1 for a win, 0.5 for a draw and
+// 0 for a loss
+func ratingChange(rating, opponent int, score float64) int {
+	expected := 1 / (1 + math.Pow(10, float64(opponent-rating)/400))
+	return int(math.Round(ratingK * (score - expected)))
+}
+
+// PlayerRating returns username's Elo rating, or DefaultRating if they have
+// no leaderboard row
+func (m *Manager) PlayerRating(username string) (int, error) {
+	return m.store.Rating(username)
+}
+
+// updateRatings moves both players' ratings after a finished game between
+// two humans. Games against the bot are unrated.
+func (m *Manager) updateRatings(game *Game) {
+	if game.Player1.IsBot || game.Player2.IsBot {
+		return
+	}
+
+	rating1, err := m.store.Rating(game.Player1.Username)
+	if err != nil {
+		slog.Error("Error updating ratings", "gameId", game.ID, "error", err)
+		return
+	}
+	rating2, err := m.store.Rating(game.Player2.Username)
+	if err != nil {
+		slog.Error("Error updating ratings", "gameId", game.ID, "error", err)
+		return
+	}
+
+	score1 := 0.5
+	switch game.Winner {
+	case game.Player1.ID:
+		score1 = 1
+	case game.Player2.ID:
+		score1 = 0
+	}
+
+	if err := m.store.AdjustRating(game.Player1.Username, ratingChange(rating1, rating2, score1)); err != nil {
+		slog.Error("Error updating ratings", "gameId", game.ID, "error", err)
+	}
+	if err := m.store.AdjustRating(game.Player2.Username, ratingChange(rating2, rating1, 1-score1)); err != nil {
+		slog.Error("Error updating ratings", "gameId", game.ID, "error", err)
+	}
+}
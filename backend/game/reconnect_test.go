@@ -0,0 +1,218 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestBothPlayersDisconnectingAbandonsTheGame(t *testing.T) {
+	store := NewMemoryStore()
+	analytics := &recordingAnalytics{}
+	m := NewManager(store, analytics, Options{ReconnectWindow: 50 * time.Millisecond})
+	conn1, conn2 := &websocket.Conn{}, &websocket.Conn{}
+	player1, player2 := humans(conn1, conn2)
+	g := m.CreateGame(player1, player2)
+
+	m.HandleDisconnect(conn1, nil)
+	if m.ReconnectWindowCount() != 1 {
+		t.Fatalf("%d reconnect windows open after the first disconnect, want 1", m.ReconnectWindowCount())
+	}
+	m.HandleDisconnect(conn2, nil)
+
+	if m.GetGame(g.ID) != nil {
+		t.Error("game still held after both players left")
+	}
+	if m.ReconnectWindowCount() != 0 {
+		t.Errorf("%d reconnect windows still open", m.ReconnectWindowCount())
+	}
+	final := g.Final()
+	if final == nil || final.Status != "abandoned" || final.Winner != "" {
+		t.Fatalf("final game = %+v, want abandoned with no winner", final)
+	}
+
+	// The first player's window running out must not forfeit them to the
+	// player who left after them
+	time.Sleep(150 * time.Millisecond)
+	saved, err := store.LoadGame(g.ID)
+	if err != nil || saved == nil {
+		t.Fatalf("LoadGame = %v, %v", saved, err)
+	}
+	if saved.Status != "abandoned" || saved.Winner != "" {
+		t.Errorf("saved game has status %q and winner %q, want abandoned with no winner", saved.Status, saved.Winner)
+	}
+	for _, username := range []string{"alice", "bob"} {
+		if entry := store.leaderboard[username]; entry != nil && (entry.Wins != 0 || entry.Losses != 0) {
+			t.Errorf("%s has %d wins and %d losses from an abandoned game", username, entry.Wins, entry.Losses)
+		}
+	}
+	analytics.mu.Lock()
+	defer analytics.mu.Unlock()
+	want := []string{"p1:" + ReconnectDisconnected, "p1:" + ReconnectAbandoned}
+	if len(analytics.reconnects) != len(want) || analytics.reconnects[0] != want[0] || analytics.reconnects[1] != want[1] {
+		t.Errorf("reconnects = %v, want %v", analytics.reconnects, want)
+	}
+}
+
+func TestMoveRotatesTheReconnectToken(t *testing.T) {
+	m := newTestManager(Options{})
+	player1, player2 := humans(&websocket.Conn{}, &websocket.Conn{})
+	g := m.CreateGame(player1, player2)
+	mover := g.Player1
+	if g.CurrentPlayer == g.Player2.ID {
+		mover = g.Player2
+	}
+	oldToken := mover.ReconnectToken
+
+	result := m.MakeMove(g.ID, 3, mover.Conn)
+	if !result.Success {
+		t.Fatalf("move failed: %s", result.Message)
+	}
+	newToken := result.Game.Player1.ReconnectToken
+	if mover.ID == g.Player2.ID {
+		newToken = result.Game.Player2.ReconnectToken
+	}
+	if newToken == "" || newToken == oldToken {
+		t.Fatalf("token after the move = %q, want a new one", newToken)
+	}
+
+	m.HandleDisconnect(mover.Conn, nil)
+	conn := &websocket.Conn{}
+	if rejoin := m.RejoinGame(conn, mover.Username, g.ID, oldToken); rejoin.Success {
+		t.Fatal("rejoined with the token from before the move")
+	}
+	if rejoin := m.RejoinGame(conn, mover.Username, g.ID, newToken); !rejoin.Success {
+		t.Fatalf("rejoin with the current token failed: %s", rejoin.Message)
+	}
+}
+
+func TestShortReconnectWindowForfeits(t *testing.T) {
+	store := NewMemoryStore()
+	m := NewManager(store, nil, Options{ReconnectWindow: 50 * time.Millisecond})
+	conn1, conn2 := dial(t), dial(t)
+	g := m.CreateGame(humans(conn1.server, conn2.server))
+
+	notified := make(chan *Game, 1)
+	m.HandleDisconnect(conn1.server, func(final *Game) { notified <- final })
+	msg := conn2.nextOfType(t, "playerDisconnected")
+	if msg["secondsRemaining"] != float64(1) {
+		t.Errorf("secondsRemaining = %v, want the 50ms window rounded up to 1", msg["secondsRemaining"])
+	}
+
+	select {
+	case final := <-notified:
+		if final.Status != "finished" || final.Winner != "p2" || final.ResultType != ResultForfeit {
+			t.Fatalf("game ended %s/%s with winner %q, want p2 winning by forfeit", final.Status, final.ResultType, final.Winner)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the reconnect window never expired")
+	}
+	if m.ReconnectWindowCount() != 0 {
+		t.Errorf("%d reconnect windows still open", m.ReconnectWindowCount())
+	}
+	if entry := store.leaderboard["bob"]; entry == nil || entry.Wins != 1 {
+		t.Errorf("bob's leaderboard row = %+v, want the forfeit win", entry)
+	}
+	if rejoin := m.RejoinGame(&websocket.Conn{}, "alice", g.ID, g.Player1.ReconnectToken); rejoin.Success {
+		t.Error("rejoined after the window expired")
+	}
+}
+
+func TestLongReconnectWindowAllowsRejoin(t *testing.T) {
+	m := NewManager(NewMemoryStore(), nil, Options{ReconnectWindow: time.Minute})
+	conn1, conn2 := dial(t), dial(t)
+	g := m.CreateGame(humans(conn1.server, conn2.server))
+
+	m.HandleDisconnect(conn1.server, func(*Game) { t.Error("the game ended while alice could still rejoin") })
+	msg := conn2.nextOfType(t, "playerDisconnected")
+	if msg["secondsRemaining"] != float64(60) {
+		t.Errorf("secondsRemaining = %v, want 60", msg["secondsRemaining"])
+	}
+
+	rejoin := m.RejoinGame(&websocket.Conn{}, "alice", g.ID, g.Player1.ReconnectToken)
+	if !rejoin.Success {
+		t.Fatalf("rejoin failed: %s", rejoin.Message)
+	}
+	if rejoin.Game.Status != "active" {
+		t.Errorf("game is %s after the rejoin, want active", rejoin.Game.Status)
+	}
+	if m.ReconnectWindowCount() != 0 {
+		t.Errorf("%d reconnect windows still open after the rejoin", m.ReconnectWindowCount())
+	}
+}
+
+func TestReconnectWindowDefaults(t *testing.T) {
+	if got := newTestManager(Options{}).reconnectWindow(); got != DefaultReconnectWindow {
+		t.Errorf("reconnect window = %v, want the default %v", got, DefaultReconnectWindow)
+	}
+}
+
+// countType reads everything sent to c for the given time and counts the
+// messages of msgType. c can't be read from afterwards.
+func (c *testConn) countType(msgType string, within time.Duration) int {
+	c.client.SetReadDeadline(time.Now().Add(within))
+	count := 0
+	for {
+		var msg map[string]interface{}
+		if err := c.client.ReadJSON(&msg); err != nil {
+			return count
+		}
+		if msg["type"] == msgType {
+			count++
+		}
+	}
+}
+
+func TestReconnectExpiredIsSentOnce(t *testing.T) {
+	m := NewManager(NewMemoryStore(), nil, Options{ReconnectWindow: 50 * time.Millisecond})
+	conn1, conn2 := dial(t), dial(t)
+	g := m.CreateGame(humans(conn1.server, conn2.server))
+
+	m.HandleDisconnect(conn1.server, nil)
+	msg := conn2.nextOfType(t, "reconnectExpired")
+	if msg["gameId"] != g.ID || msg["username"] != "alice" {
+		t.Errorf("reconnectExpired = %v, want alice's game", msg)
+	}
+
+	// A second disconnect of the finished game opens no new window
+	m.HandleDisconnect(conn1.server, nil)
+	if n := conn2.countType("reconnectExpired", 200*time.Millisecond); n != 0 {
+		t.Errorf("%d more reconnectExpired messages, want none", n)
+	}
+}
+
+func TestOtherEndingsDontSendReconnectExpired(t *testing.T) {
+	endings := map[string]func(m *Manager, g *Game, bob *websocket.Conn){
+		"opponent resigns": func(m *Manager, g *Game, bob *websocket.Conn) {
+			if !m.Resign(g.ID, bob).Success {
+				t.Error("resign failed")
+			}
+		},
+		"forfeit": func(m *Manager, g *Game, bob *websocket.Conn) {
+			if m.ForfeitGame(g.ID, "p2", nil) == nil {
+				t.Error("forfeit failed")
+			}
+		},
+		"rejoin": func(m *Manager, g *Game, bob *websocket.Conn) {
+			if !m.RejoinGame(&websocket.Conn{}, "alice", g.ID, g.Player1.ReconnectToken).Success {
+				t.Error("rejoin failed")
+			}
+		},
+	}
+	for name, end := range endings {
+		t.Run(name, func(t *testing.T) {
+			m := NewManager(NewMemoryStore(), nil, Options{ReconnectWindow: 50 * time.Millisecond})
+			conn1, conn2 := dial(t), dial(t)
+			g := m.CreateGame(humans(conn1.server, conn2.server))
+
+			// alice drops, then the game ends or resumes before her window
+			// runs out
+			m.HandleDisconnect(conn1.server, nil)
+			end(m, g, conn2.server)
+			if n := conn2.countType("reconnectExpired", 200*time.Millisecond); n != 0 {
+				t.Errorf("%d reconnectExpired messages, want none", n)
+			}
+		})
+	}
+}
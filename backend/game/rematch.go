@@ -0,0 +1,177 @@
+package game
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// DefaultRematchTimeout is how long a rematch request waits for the opponent
+const DefaultRematchTimeout = 30 * time.Second
+
+// RematchResult reports a rematch request. NewGame is set once both players
+// have asked for a rematch and the new game has started.
+type RematchResult struct {
+	Success bool
+	Message string
+	Code    string
+	Game    *Game
+	NewGame *Game
+}
+
+// RequestRematch asks for a rematch of the finished game on behalf of the
+// player on conn. The first request waits for the opponent, up to the
+// manager's RematchTimeout; the bot always agrees. Once both have asked, a
+// new game between the same players starts with the other player moving
+// first.
+func (m *Manager) RequestRematch(gameID string, conn *websocket.Conn) *RematchResult {
+	m.mu.Lock()
+	result, rematch := m.requestRematch(gameID, conn)
+	if result.Game != nil {
+		result.Game = result.Game.snapshot()
+	}
+	m.unlock()
+
+	if rematch != nil {
+		result.NewGame = m.addGame(rematch, rematchTags(result.Game))
+	}
+	return result
+}
+
+// requestRematch returns the new game, not yet registered, when the rematch
+// is agreed. mu must be held.
+func (m *Manager) requestRematch(gameID string, conn *websocket.Conn) (*RematchResult, *Game) {
+	game, exists := m.games[gameID]
+	if !exists {
+		return &RematchResult{Success: false, Message: "Game not found", Code: CodeGameNotFound}, nil
+	}
+	if game.Status != "finished" {
+		return &RematchResult{Success: false, Message: "Game is not finished"}, nil
+	}
+	if hasTag(game.Tags, TagTournament) {
+		return &RematchResult{Success: false, Message: "Tournament games can't be rematched"}, nil
+	}
+
+	player := game.playerByConn(conn)
+	if player == nil {
+		return &RematchResult{Success: false, Message: "Not a player in this game", Code: CodeNotYourGame}, nil
+	}
+	if game.RematchGameID != "" {
+		return &RematchResult{Success: false, Message: "The rematch has already started"}, nil
+	}
+	if game.playerLeft {
+		m.clearRematch(game)
+		return &RematchResult{Success: false, Message: "Your opponent has left"}, nil
+	}
+
+	opponent := game.Opponent(player)
+	switch game.RematchBy {
+	case player.ID:
+		return &RematchResult{Success: false, Message: "Your rematch request is still waiting for an answer"}, nil
+	case opponent.ID:
+		m.clearRematch(game)
+	default:
+		if !opponent.IsBot {
+			game.RematchBy = player.ID
+			game.rematchTimer = time.AfterFunc(m.options.RematchTimeout, func() {
+				m.expireRematch(gameID, player.ID)
+			})
+			game.logEvent("rematchRequested", "player="+player.ID)
+			return &RematchResult{Success: true, Game: game}, nil
+		}
+	}
+
+	// Same seats, but whoever moved second last time moves first
+	firstPlayer := game.CurrentPlayer
+	if len(game.Moves) > 0 {
+		firstPlayer = game.Moves[0].Player
+	}
+	player1, player2 := rematchPlayer(game.Player1), rematchPlayer(game.Player2)
+	secondPlayer := player1.ID
+	if firstPlayer == player1.ID {
+		secondPlayer = player2.ID
+	}
+
+	rematch := m.newGame(player1, player2, game.Dimensions, game.Dimensions.CreateBoard(), secondPlayer)
+	game.RematchGameID = rematch.ID
+	game.logEvent("rematch", "game="+rematch.ID)
+	return &RematchResult{Success: true, Game: game}, rematch
+}
+
+// rematchPlayer copies a player into a new game, leaving behind per-game
+// state such as the reconnect token
+func rematchPlayer(player *Player) *Player {
+	return &Player{
+		ID:            player.ID,
+		Username:      player.Username,
+		Conn:          player.Conn,
+		IsBot:         player.IsBot,
+		BotDifficulty: player.BotDifficulty,
+	}
+}
+
+// rematchTags carries over the tags describing how the players met; newGame
+// adds ranked or bot itself
+func rematchTags(game *Game) []string {
+	var tags []string
+	for _, tag := range []string{TagPrivate, TagPractice} {
+		if hasTag(game.Tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// expireRematch drops playerID's rematch request if it is still unanswered
+// and tells them it ran out
+func (m *Manager) expireRematch(gameID, playerID string) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	game, exists := m.games[gameID]
+	if !exists || game.RematchBy != playerID {
+		return
+	}
+	m.clearRematch(game)
+
+	player := game.Player1
+	if player.ID != playerID {
+		player = game.Player2
+	}
+	m.send(player.Conn, map[string]interface{}{
+		"type":   "rematchExpired",
+		"gameId": gameID,
+	})
+}
+
+// leaveFinishedGame notes that a player of the finished game disconnected,
+// which rules out a rematch. A pending request from the other player is
+// cancelled and they are told why. mu must be held.
+func (m *Manager) leaveFinishedGame(game *Game, conn *websocket.Conn) {
+	player := game.playerByConn(conn)
+	if player == nil {
+		return
+	}
+	game.playerLeft = true
+
+	opponent := game.Opponent(player)
+	if game.RematchBy != opponent.ID {
+		return
+	}
+	m.clearRematch(game)
+	m.send(opponent.Conn, map[string]interface{}{
+		"type":    "error",
+		"code":    "rematchUnavailable",
+		"message": player.Username + " left, so there will be no rematch",
+	})
+}
+
+// clearRematch drops the pending rematch request and its timer. mu must be
+// held.
+func (m *Manager) clearRematch(game *Game) {
+	if game.rematchTimer != nil {
+		game.rematchTimer.Stop()
+		game.rematchTimer = nil
+	}
+	game.RematchBy = ""
+}
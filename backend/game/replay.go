@@ -0,0 +1,90 @@
+package game
+
+import (
+	"fmt"
+)
+
+// ReplayStep is the board right after one move of a replayed game
+type ReplayStep struct {
+	Move  Move
+	Board [][]interface{}
+}
+
+// Replay is a saved game rebuilt move by move from its starting position.
+// Problems lists where the stored record doesn't add up, such as a move that
+// can't be played or a final board that contradicts the recorded winner.
+type Replay struct {
+	InitialBoard [][]interface{}
+	Steps        []ReplayStep
+	Problems     []string
+}
+
+// ReplayGame plays the saved moves on the game's initial board, keeping a
+// snapshot after each one. Replaying stops at the first move that can't be
+// played.
+func ReplayGame(saved *SavedGame) *Replay {
+	board := saved.InitialBoard()
+	replay := &Replay{
+		InitialBoard: copyBoard(board),
+		Steps:        make([]ReplayStep, 0, len(saved.Moves)),
+	}
+
+	for i, move := range saved.Moves {
+		if result := ReplayMove(board, move); !result.Success {
+			replay.Problems = append(replay.Problems,
+				fmt.Sprintf("move %d in column %d can't be played: %s", i+1, move.Column, result.Message))
+			return replay
+		}
+		replay.Steps = append(replay.Steps, ReplayStep{Move: move, Board: copyBoard(board)})
+	}
+
+	if problem := checkRecordedWinner(saved, board); problem != "" {
+		replay.Problems = append(replay.Problems, problem)
+	}
+	return replay
+}
+
+// checkRecordedWinner compares the final board with the recorded winner.
+// Games don't store how they ended, so only a completed line or a full board
+// settles who should have won; a forfeit, resignation or timeout leaves
+// neither. Lines for both players come from a pop out, where the tie rule
+// decides, so they are not checked either.
+func checkRecordedWinner(saved *SavedGame, board [][]interface{}) string {
+	owners := saved.Dimensions.lineOwners(board)
+	switch {
+	case len(owners) == 1:
+		for owner := range owners {
+			if owner != saved.Winner {
+				return fmt.Sprintf("final board has a winning line for %s but the recorded winner is %q",
+					saved.Username(fmt.Sprint(owner)), saved.Username(saved.Winner))
+			}
+		}
+	case len(owners) == 0 && IsBoardFull(board) && saved.Winner != "draw":
+		return fmt.Sprintf("final board is full with no winning line but the recorded winner is %q",
+			saved.Username(saved.Winner))
+	}
+	return ""
+}
+
+// lineOwners returns the players with a winning line on the board
+func (d Dimensions) lineOwners(board [][]interface{}) map[interface{}]bool {
+	owners := make(map[interface{}]bool)
+	for row := 0; row < d.Rows; row++ {
+		for col := 0; col < d.Cols; col++ {
+			cell := board[row][col]
+			if cell != nil && !owners[cell] && d.CheckWin(board, row, col).Won {
+				owners[cell] = true
+			}
+		}
+	}
+	return owners
+}
+
+func copyBoard(board [][]interface{}) [][]interface{} {
+	newBoard := make([][]interface{}, len(board))
+	for i, row := range board {
+		newBoard[i] = make([]interface{}, len(row))
+		copy(newBoard[i], row)
+	}
+	return newBoard
+}
@@ -0,0 +1,91 @@
+package game
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// savedWin plays and saves a game that p1 wins with four in column 0 and
+// returns it as loaded from the store
+func savedWin(t *testing.T) *SavedGame {
+	t.Helper()
+	store := NewMemoryStore()
+	m := NewManager(store, nil, Options{})
+	conn1, conn2 := &websocket.Conn{}, &websocket.Conn{}
+	g := m.CreateGame(humans(conn1, conn2))
+	for i := 0; i < 3; i++ {
+		m.MakeMove(g.ID, 0, conn1)
+		m.MakeMove(g.ID, 1, conn2)
+	}
+	m.SaveGame(m.MakeMove(g.ID, 0, conn1).Game)
+
+	saved, err := store.LoadGame(g.ID)
+	if err != nil || saved == nil {
+		t.Fatalf("LoadGame = %+v, %v", saved, err)
+	}
+	return saved
+}
+
+func TestReplayGame(t *testing.T) {
+	saved := savedWin(t)
+	replay := ReplayGame(saved)
+
+	if len(replay.Problems) != 0 {
+		t.Errorf("problems = %v, want none", replay.Problems)
+	}
+	if MovesRemaining(replay.InitialBoard) != ROWS*COLS {
+		t.Error("initial board isn't empty")
+	}
+	if len(replay.Steps) != len(saved.Moves) {
+		t.Fatalf("%d steps for %d moves", len(replay.Steps), len(saved.Moves))
+	}
+	for i, step := range replay.Steps {
+		if step.Move != saved.Moves[i] {
+			t.Errorf("step %d is move %+v, want %+v", i, step.Move, saved.Moves[i])
+		}
+		if got := ROWS*COLS - MovesRemaining(step.Board); got != i+1 {
+			t.Errorf("step %d has %d discs, want %d", i, got, i+1)
+		}
+		if step.Board[step.Move.Row][step.Move.Column] != step.Move.Player {
+			t.Errorf("step %d: %s's disc missing from row %d, column %d", i, step.Move.Player, step.Move.Row, step.Move.Column)
+		}
+	}
+
+	// Each step keeps its own board
+	replay.Steps[0].Board[ROWS-1][6] = "p2"
+	if replay.Steps[1].Board[ROWS-1][6] != nil {
+		t.Error("steps share a board")
+	}
+	last := replay.Steps[len(replay.Steps)-1].Board
+	if p1Won, p2Won := StandardDimensions.CheckAllWins(last, "p1", "p2"); !p1Won || p2Won {
+		t.Errorf("final board wins = %v, %v, want only p1", p1Won, p2Won)
+	}
+}
+
+func TestReplayGameFlagsInconsistentRecords(t *testing.T) {
+	t.Run("wrong winner", func(t *testing.T) {
+		saved := savedWin(t)
+		saved.Winner = "p2"
+		replay := ReplayGame(saved)
+		if len(replay.Problems) != 1 || !strings.Contains(replay.Problems[0], "recorded winner") {
+			t.Errorf("problems = %v, want the winner flagged", replay.Problems)
+		}
+		if len(replay.Steps) != len(saved.Moves) {
+			t.Errorf("%d steps, want every move replayed", len(replay.Steps))
+		}
+	})
+
+	t.Run("unplayable move", func(t *testing.T) {
+		saved := savedWin(t)
+		saved.Moves[2].Column = COLS
+		replay := ReplayGame(saved)
+		if len(replay.Problems) != 1 || !strings.Contains(replay.Problems[0], "move 3") {
+			t.Errorf("problems = %v, want move 3 flagged", replay.Problems)
+		}
+		if len(replay.Steps) != 2 {
+			t.Errorf("%d steps, want replay to stop before move 3", len(replay.Steps))
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MoveRule is an extra legality check for training variants. It is consulted
+// before a player's move is applied and returns a violation to reject it.
+// Rules only constrain human players; the bot is never restricted.
+type MoveRule func(game *Game, playerID string, column int) *RuleViolation
+
+// RuleViolation explains why a rule rejected a move. Code is stable so
+// clients can react to it; Message is for display.
+type RuleViolation struct {
+	Code    string
+	Message string
+}
+
+// moveRules maps the names accepted by ParseMoveRules (MOVE_RULES) to rules
+var moveRules = map[string]MoveRule{
+	"centerFirst": centerFirstRule,
+}
+
+// ParseMoveRules looks up comma-separated rule names, ignoring blanks
+func ParseMoveRules(names string) ([]MoveRule, error) {
+	var rules []MoveRule
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		rule, exists := moveRules[name]
+		if !exists {
+			return nil, fmt.Errorf("unknown move rule %q", name)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// centerFirstRule requires each player's first move to be in the center column
+func centerFirstRule(game *Game, playerID string, column int) *RuleViolation {
+	for _, move := range game.Moves {
+		if move.Player == playerID {
+			return nil
+		}
+	}
+	if column != game.Dimensions.Cols/2 {
+		return &RuleViolation{
+			Code:    "centerFirstRequired",
+			Message: "Your first move must be in the center column",
+		}
+	}
+	return nil
+}
+
+// checkMoveRules returns the first violation of the manager's rules, if any
+func (m *Manager) checkMoveRules(game *Game, playerID string, column int) *RuleViolation {
+	for _, rule := range m.options.MoveRules {
+		if violation := rule(game, playerID, column); violation != nil {
+			return violation
+		}
+	}
+	return nil
+}
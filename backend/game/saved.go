@@ -0,0 +1,62 @@
+package game
+
+// SavedGame is a game as kept by the Store, with enough detail to
+// replay it move by move. Winner and move players are player IDs.
+type SavedGame struct {
+	GameSummary
+	// Player1ID and Player2ID tie those IDs to the usernames. They are empty
+	// for games saved before the IDs were recorded.
+	Player1ID     string
+	Player2ID     string
+	StartingBoard [][]interface{}
+	Dimensions    Dimensions
+	Moves         []Move
+}
+
+// GetSavedGame loads a saved game, returning nil if there is no such game
+func (m *Manager) GetSavedGame(gameID string) (*SavedGame, error) {
+	return m.store.LoadGame(gameID)
+}
+
+// InitialBoard returns a fresh copy of the position the game started from
+func (s *SavedGame) InitialBoard() [][]interface{} {
+	board := s.Dimensions.CreateBoard()
+	for row := range s.StartingBoard {
+		copy(board[row], s.StartingBoard[row])
+	}
+	return board
+}
+
+// Username returns the username of the player with the given ID, or the ID
+// itself (e.g. "draw") if it isn't one of the game's players
+func (s *SavedGame) Username(playerID string) string {
+	switch {
+	case playerID == "":
+		return ""
+	case playerID == s.Player1ID:
+		return s.Player1
+	case playerID == s.Player2ID || playerID == BotID:
+		return s.Player2
+	}
+	return playerID
+}
+
+// Loser returns the ID and username of the player who lost, or empty strings
+// if the game has no winner
+func (s *SavedGame) Loser() (id, username string) {
+	if s.Winner == "" || s.Winner == "draw" {
+		return "", ""
+	}
+	for _, move := range s.Moves {
+		if move.Player == s.Winner {
+			continue
+		}
+		// Player1 moves first except in handicap games, which are always
+		// against the bot
+		if move.Player == BotID || (s.Winner != BotID && move.Player != s.Moves[0].Player) {
+			return move.Player, s.Player2
+		}
+		return move.Player, s.Player1
+	}
+	return "", ""
+}
@@ -0,0 +1,65 @@
+package game
+
+import (
+	"time"
+)
+
+// Player results in PlayerGame
+const (
+	PlayerResultWin       = "win"
+	PlayerResultLoss      = "loss"
+	PlayerResultDraw      = "draw"
+	PlayerResultAbandoned = "abandoned"
+)
+
+// PlayerStats is a player's leaderboard row, ranked by wins, and their most
+// recent saved games
+type PlayerStats struct {
+	LeaderboardEntry
+	RecentGames []PlayerGame `json:"recent_games"`
+}
+
+// PlayerGame is a saved game from one player's point of view. Result is one
+// of the PlayerResult values, or "" for a decided game saved before player
+// IDs were recorded, where the winner can't be told apart.
+type PlayerGame struct {
+	ID              string     `json:"id"`
+	Opponent        string     `json:"opponent"`
+	Result          string     `json:"result"`
+	EndedAt         *time.Time `json:"ended_at"`
+	DurationSeconds *int       `json:"duration_seconds"`
+}
+
+// GetPlayerStats returns username's stats with up to recentGames of their
+// latest games, or nil if they have never finished a game
+func (m *Manager) GetPlayerStats(username string, recentGames int) (*PlayerStats, error) {
+	return m.store.PlayerStats(username, recentGames)
+}
+
+// playerGame describes record from username's side of the board
+func playerGame(username string, record GameRecord) PlayerGame {
+	playerID, opponent, opponentID := record.Player1ID, record.Player2, record.Player2ID
+	if record.Player1 != username {
+		playerID, opponent, opponentID = record.Player2ID, record.Player1, record.Player1ID
+	}
+
+	result := ""
+	switch {
+	case record.Status == "abandoned":
+		result = PlayerResultAbandoned
+	case record.Winner == "draw":
+		result = PlayerResultDraw
+	case playerID != "" && record.Winner == playerID:
+		result = PlayerResultWin
+	case opponentID != "" && record.Winner == opponentID, record.Winner == BotID:
+		result = PlayerResultLoss
+	}
+
+	return PlayerGame{
+		ID:              record.ID,
+		Opponent:        opponent,
+		Result:          result,
+		EndedAt:         record.EndedAt,
+		DurationSeconds: record.DurationSeconds,
+	}
+}
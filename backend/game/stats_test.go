@@ -0,0 +1,27 @@
+package game
+
+import "testing"
+
+func TestPlayerGameResult(t *testing.T) {
+	tests := []struct {
+		name   string
+		record GameRecord
+		want   string
+	}{
+		{"win", GameRecord{Player1ID: "p1", Player2ID: "p2", Winner: "p1", Status: "finished"}, PlayerResultWin},
+		{"loss", GameRecord{Player1ID: "p1", Player2ID: "p2", Winner: "p2", Status: "finished"}, PlayerResultLoss},
+		{"draw", GameRecord{Player1ID: "p1", Player2ID: "p2", Winner: "draw", Status: "finished"}, PlayerResultDraw},
+		{"abandoned", GameRecord{Player1ID: "p1", Player2ID: "p2", Status: "abandoned"}, PlayerResultAbandoned},
+		{"lost to the bot", GameRecord{Player1ID: "p1", Player2ID: BotID, Winner: BotID, Status: "finished"}, PlayerResultLoss},
+		{"saved before player IDs", GameRecord{Winner: "p1", Status: "finished"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.record.Player1, tt.record.Player2 = "alice", "bob"
+			got := playerGame("alice", tt.record)
+			if got.Result != tt.want || got.Opponent != "bob" {
+				t.Errorf("alice's game = %+v, want %q against bob", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package game
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Store persists finished games and the leaderboard. PostgresStore is used
+// in production; MemoryStore suits tests and lightweight deployments.
+type Store interface {
+	// SaveGame inserts a game record. It must be idempotent so a retry after
+	// an unacknowledged write is harmless.
+	SaveGame(record GameRecord) error
+	// RecordResult adds one game with the given outcome to a player's row
+	RecordResult(username string, wins, losses, draws int) error
+	// Rating returns a player's Elo rating, or DefaultRating if they have no
+	// leaderboard row
+	Rating(username string) (int, error)
+	// AdjustRating adds delta to a player's rating
+	AdjustRating(username string, delta int) error
+	// CanonicalUsername returns the spelling of a leaderboard username that
+	// matches username case-insensitively, or username if there is none
+	CanonicalUsername(username string) (string, error)
+	// Leaderboard returns one page of the ranking described by query
+	Leaderboard(query LeaderboardQuery) (*LeaderboardPage, error)
+	// LeaderboardAround returns the rows ranked up to window places above and
+	// below username, or nothing if the user has no leaderboard row
+	LeaderboardAround(username string, window int, sortBy LeaderboardSort) ([]LeaderboardEntry, error)
+	// ListGames returns saved games newest first, only those carrying tag if
+	// it is non-empty
+	ListGames(tag string, limit int) ([]GameSummary, error)
+	// FinishedGames returns every saved game with status "finished"
+	FinishedGames() ([]*SavedGame, error)
+	// LoadGame returns a saved game, or nil if there is no such game
+	LoadGame(gameID string) (*SavedGame, error)
+	// PlayerStats returns a player's leaderboard row and up to recentGames of
+	// their games, newest first, or nil if they have no leaderboard row
+	PlayerStats(username string, recentGames int) (*PlayerStats, error)
+
+	// SaveActiveGame stores the state of a game in play, unless the stored
+	// state already has more moves
+	SaveActiveGame(record ActiveGameRecord) error
+	// DeleteActiveGame removes a game that is no longer in play
+	DeleteActiveGame(gameID string) error
+	// ActiveGames returns every stored game in play
+	ActiveGames() ([]ActiveGameRecord, error)
+}
+
+// GameRecord is a finished game as handed to the Store. It doubles as the
+// JSON line appended to the unsaved games file when saving fails.
+type GameRecord struct {
+	ID              string          `json:"id"`
+	Player1         string          `json:"player1_username"`
+	Player2         string          `json:"player2_username"`
+	Player1ID       string          `json:"player1_id,omitempty"`
+	Player2ID       string          `json:"player2_id,omitempty"`
+	Winner          string          `json:"winner"`
+	Status          string          `json:"status"`
+	StartedAt       time.Time       `json:"started_at"`
+	EndedAt         *time.Time      `json:"ended_at"`
+	DurationSeconds *int            `json:"duration_seconds"`
+	Moves           json.RawMessage `json:"moves"`
+	StartingBoard   json.RawMessage `json:"starting_board,omitempty"`
+	// Dimensions is nil for games on the standard board
+	Dimensions *Dimensions `json:"dimensions,omitempty"`
+	Tags       []string    `json:"tags"`
+}
+
+// savedGameFromJSON builds a SavedGame from its summary, player IDs, stored
+// moves and optional starting board and dimensions
+func savedGameFromJSON(summary GameSummary, player1ID, player2ID string, movesJSON, startingBoardJSON, dimensionsJSON []byte) (*SavedGame, error) {
+	saved := &SavedGame{
+		GameSummary: summary,
+		Player1ID:   player1ID,
+		Player2ID:   player2ID,
+		Dimensions:  StandardDimensions,
+	}
+	if err := json.Unmarshal(movesJSON, &saved.Moves); err != nil {
+		return nil, err
+	}
+	if len(startingBoardJSON) > 0 {
+		if err := json.Unmarshal(startingBoardJSON, &saved.StartingBoard); err != nil {
+			return nil, err
+		}
+	}
+	if len(dimensionsJSON) > 0 {
+		if err := json.Unmarshal(dimensionsJSON, &saved.Dimensions); err != nil {
+			return nil, err
+		}
+	}
+	return saved, nil
+}
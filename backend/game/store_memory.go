@@ -0,0 +1,324 @@
+package game
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MemoryStore keeps games and the leaderboard in process memory. Nothing
+// survives a restart, so it is meant for tests and lightweight deployments.
+type MemoryStore struct {
+	mu          sync.Mutex
+	games       []GameRecord
+	gameIndex   map[string]int
+	leaderboard map[string]*LeaderboardEntry
+	activeGames map[string]ActiveGameRecord
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		gameIndex:   make(map[string]int),
+		leaderboard: make(map[string]*LeaderboardEntry),
+		activeGames: make(map[string]ActiveGameRecord),
+	}
+}
+
+func (s *MemoryStore) SaveGame(record GameRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.gameIndex[record.ID]; exists {
+		return nil
+	}
+	s.gameIndex[record.ID] = len(s.games)
+	s.games = append(s.games, record)
+	return nil
+}
+
+func (s *MemoryStore) RecordResult(username string, wins, losses, draws int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, exists := s.leaderboard[username]
+	if !exists {
+		entry = &LeaderboardEntry{Username: username, Rating: DefaultRating}
+		s.leaderboard[username] = entry
+	}
+	entry.Wins += wins
+	entry.Losses += losses
+	entry.Draws += draws
+	entry.TotalGames++
+	return nil
+}
+
+func (s *MemoryStore) Rating(username string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, exists := s.leaderboard[username]; exists {
+		return entry.Rating, nil
+	}
+	return DefaultRating, nil
+}
+
+func (s *MemoryStore) CanonicalUsername(username string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.leaderboard[username]; exists {
+		return username, nil
+	}
+	for name := range s.leaderboard {
+		if strings.EqualFold(name, username) {
+			return name, nil
+		}
+	}
+	return username, nil
+}
+
+func (s *MemoryStore) AdjustRating(username string, delta int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, exists := s.leaderboard[username]; exists {
+		entry.Rating += delta
+	}
+	return nil
+}
+
+func (s *MemoryStore) Leaderboard(query LeaderboardQuery) (*LeaderboardPage, error) {
+	ranked := s.ranked(query.Sort, query.MinGames)
+	page := &LeaderboardPage{Entries: []LeaderboardEntry{}, Total: len(ranked), Limit: query.Limit, Offset: query.Offset}
+	if query.Offset < len(ranked) {
+		end := query.Offset + query.Limit
+		if end > len(ranked) {
+			end = len(ranked)
+		}
+		page.Entries = ranked[query.Offset:end]
+	}
+	return page, nil
+}
+
+func (s *MemoryStore) LeaderboardAround(username string, window int, sortBy LeaderboardSort) ([]LeaderboardEntry, error) {
+	ranked := s.ranked(sortBy, 0)
+	for i, entry := range ranked {
+		if entry.Username != username {
+			continue
+		}
+		from, to := i-window, i+window+1
+		if from < 0 {
+			from = 0
+		}
+		if to > len(ranked) {
+			to = len(ranked)
+		}
+		return ranked[from:to], nil
+	}
+	return nil, nil
+}
+
+// ranked mirrors rankedLeaderboardSQL: every row with at least minGames games,
+// with its win rate and rank
+func (s *MemoryStore) ranked(sortBy LeaderboardSort, minGames int) []LeaderboardEntry {
+	s.mu.Lock()
+	entries := make([]LeaderboardEntry, 0, len(s.leaderboard))
+	for _, entry := range s.leaderboard {
+		if entry.TotalGames < minGames {
+			continue
+		}
+		rated := *entry
+		if rated.TotalGames > 0 {
+			rated.WinRate = float64(rated.Wins) / float64(rated.TotalGames)
+		}
+		entries = append(entries, rated)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if sortBy == LeaderboardSortWinRate {
+			aQualified, bQualified := a.TotalGames >= MinGamesForWinRate, b.TotalGames >= MinGamesForWinRate
+			if aQualified != bQualified {
+				return aQualified
+			}
+			if a.WinRate != b.WinRate {
+				return a.WinRate > b.WinRate
+			}
+		}
+		if a.Wins != b.Wins {
+			return a.Wins > b.Wins
+		}
+		if a.TotalGames != b.TotalGames {
+			return a.TotalGames > b.TotalGames
+		}
+		return a.Username < b.Username
+	})
+	for i := range entries {
+		entries[i].Rank = i + 1
+	}
+	return entries
+}
+
+func (s *MemoryStore) ListGames(tag string, limit int) ([]GameSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var records []GameRecord
+	for _, record := range s.games {
+		if tag == "" || hasTag(record.Tags, tag) {
+			records = append(records, record)
+		}
+	}
+	sortNewestFirst(records)
+
+	summaries := []GameSummary{}
+	for _, record := range records {
+		if len(summaries) == limit {
+			break
+		}
+		summaries = append(summaries, GameSummary{
+			ID:              record.ID,
+			Player1:         record.Player1,
+			Player2:         record.Player2,
+			Winner:          record.Winner,
+			Status:          record.Status,
+			StartedAt:       record.StartedAt,
+			EndedAt:         record.EndedAt,
+			DurationSeconds: record.DurationSeconds,
+			Tags:            record.Tags,
+		})
+	}
+	return summaries, nil
+}
+
+func (s *MemoryStore) FinishedGames() ([]*SavedGame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var games []*SavedGame
+	for _, record := range s.games {
+		if record.Status != "finished" {
+			continue
+		}
+		saved, err := record.savedGame()
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, saved)
+	}
+	return games, nil
+}
+
+func (s *MemoryStore) LoadGame(gameID string) (*SavedGame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, exists := s.gameIndex[gameID]
+	if !exists {
+		return nil, nil
+	}
+	return s.games[i].savedGame()
+}
+
+func (s *MemoryStore) PlayerStats(username string, recentGames int) (*PlayerStats, error) {
+	var stats *PlayerStats
+	for _, entry := range s.ranked(LeaderboardSortWins, 0) {
+		if entry.Username == username {
+			stats = &PlayerStats{LeaderboardEntry: entry, RecentGames: []PlayerGame{}}
+			break
+		}
+	}
+	if stats == nil {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var records []GameRecord
+	for _, record := range s.games {
+		if record.Player1 == username || record.Player2 == username {
+			records = append(records, record)
+		}
+	}
+	sortNewestFirst(records)
+	for _, record := range records {
+		if len(stats.RecentGames) == recentGames {
+			break
+		}
+		stats.RecentGames = append(stats.RecentGames, playerGame(username, record))
+	}
+	return stats, nil
+}
+
+func (s *MemoryStore) SaveActiveGame(record ActiveGameRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored, exists := s.activeGames[record.ID]; exists && stored.Moves > record.Moves {
+		return nil
+	}
+	s.activeGames[record.ID] = record
+	return nil
+}
+
+func (s *MemoryStore) DeleteActiveGame(gameID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.activeGames, gameID)
+	return nil
+}
+
+func (s *MemoryStore) ActiveGames() ([]ActiveGameRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make([]ActiveGameRecord, 0, len(s.activeGames))
+	for _, record := range s.activeGames {
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// sortNewestFirst orders records by end time, newest first, with games that
+// never ended last
+func sortNewestFirst(records []GameRecord) {
+	sort.SliceStable(records, func(i, j int) bool {
+		a, b := records[i].EndedAt, records[j].EndedAt
+		if a == nil || b == nil {
+			return b == nil && a != nil
+		}
+		return a.After(*b)
+	})
+}
+
+func (r GameRecord) savedGame() (*SavedGame, error) {
+	var dimensionsJSON []byte
+	if r.Dimensions != nil {
+		dimensionsJSON, _ = json.Marshal(r.Dimensions)
+	}
+	summary := GameSummary{
+		ID:              r.ID,
+		Player1:         r.Player1,
+		Player2:         r.Player2,
+		Winner:          r.Winner,
+		Status:          r.Status,
+		StartedAt:       r.StartedAt,
+		EndedAt:         r.EndedAt,
+		DurationSeconds: r.DurationSeconds,
+		Tags:            r.Tags,
+	}
+	return savedGameFromJSON(summary, r.Player1ID, r.Player2ID, r.Moves, r.StartingBoard, dimensionsJSON)
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
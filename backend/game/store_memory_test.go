@@ -0,0 +1,147 @@
+package game
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// endedRecord returns a saved game between alice and bob that ended ago
+func endedRecord(id, winner string, ago time.Duration, tags ...string) GameRecord {
+	ended := time.Now().Add(-ago)
+	return GameRecord{
+		ID:        id,
+		Player1:   "alice",
+		Player2:   "bob",
+		Player1ID: "p1",
+		Player2ID: "p2",
+		Winner:    winner,
+		Status:    "finished",
+		StartedAt: ended.Add(-time.Minute),
+		EndedAt:   &ended,
+		Moves:     json.RawMessage(`[]`),
+		Tags:      tags,
+	}
+}
+
+func TestMemoryStoreGames(t *testing.T) {
+	s := NewMemoryStore()
+	s.SaveGame(endedRecord("old", "p1", time.Hour))
+	s.SaveGame(endedRecord("new", "p2", time.Minute, TagTournament))
+	abandoned := endedRecord("gone", "", 2*time.Hour)
+	abandoned.Status = "abandoned"
+	s.SaveGame(abandoned)
+
+	// Saving a game twice keeps the first copy
+	s.SaveGame(endedRecord("old", "draw", 0))
+	if saved, err := s.LoadGame("old"); err != nil || saved == nil || saved.Winner != "p1" {
+		t.Fatalf("LoadGame(old) = %+v, %v, want the first save", saved, err)
+	}
+	if saved, err := s.LoadGame("missing"); saved != nil || err != nil {
+		t.Errorf("LoadGame(missing) = %+v, %v, want nil", saved, err)
+	}
+
+	games, _ := s.ListGames("", 10)
+	if len(games) != 3 || games[0].ID != "new" || games[1].ID != "old" || games[2].ID != "gone" {
+		t.Errorf("ListGames = %+v, want newest first", games)
+	}
+	if games, _ := s.ListGames("", 1); len(games) != 1 {
+		t.Errorf("ListGames with limit 1 returned %d games", len(games))
+	}
+	if games, _ := s.ListGames(TagTournament, 10); len(games) != 1 || games[0].ID != "new" {
+		t.Errorf("ListGames(%q) = %+v, want only the tagged game", TagTournament, games)
+	}
+	if finished, _ := s.FinishedGames(); len(finished) != 2 {
+		t.Errorf("FinishedGames returned %d games, want the 2 finished ones", len(finished))
+	}
+}
+
+func TestMemoryStoreLeaderboard(t *testing.T) {
+	s := NewMemoryStore()
+	s.RecordResult("alice", 1, 0, 0)
+	s.RecordResult("alice", 1, 0, 0)
+	s.RecordResult("bob", 0, 1, 0)
+	s.RecordResult("Carol", 1, 0, 0)
+	s.RecordResult("Carol", 0, 0, 1)
+
+	page, _ := s.Leaderboard(LeaderboardQuery{Sort: LeaderboardSortWins, Limit: 2})
+	if page.Total != 3 || len(page.Entries) != 2 {
+		t.Fatalf("page = %+v, want 2 of 3 entries", page)
+	}
+	// alice and Carol tie on wins; alice has more games
+	if page.Entries[0].Username != "alice" || page.Entries[0].Rank != 1 || page.Entries[1].Username != "Carol" {
+		t.Errorf("top two = %+v", page.Entries)
+	}
+	if page, _ := s.Leaderboard(LeaderboardQuery{Limit: 10, Offset: 2}); len(page.Entries) != 1 || page.Entries[0].Username != "bob" {
+		t.Errorf("offset page = %+v, want bob", page.Entries)
+	}
+	if page, _ := s.Leaderboard(LeaderboardQuery{Limit: 10, MinGames: 2}); page.Total != 2 {
+		t.Errorf("%d players with 2 or more games, want 2", page.Total)
+	}
+
+	if around, _ := s.LeaderboardAround("bob", 1, LeaderboardSortWins); len(around) != 2 || around[1].Username != "bob" {
+		t.Errorf("LeaderboardAround(bob) = %+v", around)
+	}
+	if around, _ := s.LeaderboardAround("nobody", 1, LeaderboardSortWins); around != nil {
+		t.Errorf("LeaderboardAround(nobody) = %+v, want nil", around)
+	}
+
+	if name, _ := s.CanonicalUsername("carol"); name != "Carol" {
+		t.Errorf("CanonicalUsername(carol) = %q, want Carol", name)
+	}
+	if rating, _ := s.Rating("nobody"); rating != DefaultRating {
+		t.Errorf("unrated player has rating %d, want %d", rating, DefaultRating)
+	}
+	s.AdjustRating("bob", -16)
+	if rating, _ := s.Rating("bob"); rating != DefaultRating-16 {
+		t.Errorf("bob's rating = %d, want %d", rating, DefaultRating-16)
+	}
+}
+
+func TestMemoryStoreActiveGames(t *testing.T) {
+	s := NewMemoryStore()
+	s.SaveActiveGame(ActiveGameRecord{ID: "g", Moves: 3, State: json.RawMessage(`"three"`)})
+	// A write from before the latest move doesn't replace it
+	s.SaveActiveGame(ActiveGameRecord{ID: "g", Moves: 2, State: json.RawMessage(`"two"`)})
+
+	records, _ := s.ActiveGames()
+	if len(records) != 1 || records[0].Moves != 3 {
+		t.Fatalf("ActiveGames = %+v, want the 3-move record", records)
+	}
+	s.DeleteActiveGame("g")
+	if records, _ := s.ActiveGames(); len(records) != 0 {
+		t.Errorf("ActiveGames after delete = %+v", records)
+	}
+}
+
+func TestManagerSavesFinishedGamesToTheStore(t *testing.T) {
+	store := NewMemoryStore()
+	m := NewManager(store, nil, Options{})
+	conn1, conn2 := &websocket.Conn{}, &websocket.Conn{}
+	g := m.CreateGame(humans(conn1, conn2))
+
+	// alice (p1) moves first and stacks column 0
+	for i := 0; i < 3; i++ {
+		m.MakeMove(g.ID, 0, conn1)
+		m.MakeMove(g.ID, 1, conn2)
+	}
+	result := m.MakeMove(g.ID, 0, conn1)
+	if result.Game.Status != "finished" || result.Game.Winner != "p1" {
+		t.Fatalf("game is %s with winner %q, want p1 to have won", result.Game.Status, result.Game.Winner)
+	}
+	m.SaveGame(result.Game)
+
+	saved, err := store.LoadGame(g.ID)
+	if err != nil || saved == nil {
+		t.Fatalf("LoadGame = %+v, %v", saved, err)
+	}
+	if saved.Winner != "p1" || len(saved.Moves) != 7 {
+		t.Errorf("saved game has winner %q and %d moves, want p1 and 7", saved.Winner, len(saved.Moves))
+	}
+	page, _ := m.GetLeaderboard(LeaderboardQuery{Limit: 10})
+	if len(page.Entries) != 2 || page.Entries[0].Username != "alice" || page.Entries[0].Wins != 1 || page.Entries[1].Losses != 1 {
+		t.Errorf("leaderboard = %+v, want alice 1-0 above bob 0-1", page.Entries)
+	}
+}
@@ -0,0 +1,330 @@
+package game
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// PostgresStore keeps games and the leaderboard in the tables created by InitDB
+type PostgresStore struct {
+	db *sql.DB
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) SaveGame(record GameRecord) error {
+	var startingBoard, dimensions []byte
+	if len(record.StartingBoard) > 0 {
+		startingBoard = record.StartingBoard
+	}
+	if record.Dimensions != nil {
+		var err error
+		if dimensions, err = json.Marshal(record.Dimensions); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.db.Exec(
+		`INSERT INTO games (id, player1_username, player2_username, player1_id, player2_id, winner, status, started_at, ended_at, duration_seconds, moves, starting_board, dimensions, tags)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		 ON CONFLICT (id) DO NOTHING`,
+		record.ID, record.Player1, record.Player2, record.Player1ID, record.Player2ID, record.Winner, record.Status,
+		record.StartedAt, record.EndedAt, record.DurationSeconds, []byte(record.Moves), startingBoard, dimensions, pq.Array(record.Tags),
+	)
+	return err
+}
+
+func (s *PostgresStore) RecordResult(username string, wins, losses, draws int) error {
+	_, err := s.db.Exec(
+		`INSERT INTO leaderboard (username, wins, losses, draws, total_games)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (username) 
+		 DO UPDATE SET 
+		   wins = leaderboard.wins + $2,
+		   losses = leaderboard.losses + $3,
+		   draws = leaderboard.draws + $4,
+		   total_games = leaderboard.total_games + $5`,
+		username, wins, losses, draws, 1,
+	)
+	return err
+}
+
+func (s *PostgresStore) Rating(username string) (int, error) {
+	var rating int
+	err := s.db.QueryRow(`SELECT rating FROM leaderboard WHERE username = $1`, username).Scan(&rating)
+	if err == sql.ErrNoRows {
+		return DefaultRating, nil
+	}
+	return rating, err
+}
+
+func (s *PostgresStore) CanonicalUsername(username string) (string, error) {
+	var canonical string
+	err := s.db.QueryRow(`
+		SELECT username FROM leaderboard
+		WHERE LOWER(username) = LOWER($1)
+		ORDER BY username = $1 DESC, total_games DESC
+		LIMIT 1
+	`, username).Scan(&canonical)
+	if err == sql.ErrNoRows {
+		return username, nil
+	}
+	return canonical, err
+}
+
+func (s *PostgresStore) AdjustRating(username string, delta int) error {
+	_, err := s.db.Exec(`UPDATE leaderboard SET rating = rating + $2 WHERE username = $1`, username, delta)
+	return err
+}
+
+// rankedLeaderboardSQL selects every leaderboard row with at least minGames
+// games, with its computed win rate and 1-based rank under the given ordering
+func rankedLeaderboardSQL(sortBy LeaderboardSort, minGames int) string {
+	return `
+		SELECT username, wins, losses, draws, total_games, win_rate, rating,
+		       ROW_NUMBER() OVER (ORDER BY ` + sortBy.orderBy() + `) AS rank
+		FROM (
+			SELECT username, wins, losses, draws, total_games, rating,
+			       CASE WHEN total_games > 0 THEN wins::float / total_games ELSE 0 END AS win_rate
+			FROM leaderboard
+			WHERE total_games >= ` + strconv.Itoa(minGames) + `
+		) rated`
+}
+
+func (s *PostgresStore) Leaderboard(query LeaderboardQuery) (*LeaderboardPage, error) {
+	page := &LeaderboardPage{Limit: query.Limit, Offset: query.Offset}
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM leaderboard WHERE total_games >= $1`, query.MinGames).Scan(&page.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT username, wins, losses, draws, total_games, win_rate, rank, rating
+		FROM (`+rankedLeaderboardSQL(query.Sort, query.MinGames)+`) ranked
+		ORDER BY rank
+		LIMIT $1 OFFSET $2
+	`, query.Limit, query.Offset)
+	if err != nil {
+		return nil, err
+	}
+	if page.Entries, err = scanLeaderboard(rows); err != nil {
+		return nil, err
+	}
+	if page.Entries == nil {
+		page.Entries = []LeaderboardEntry{}
+	}
+	return page, nil
+}
+
+func (s *PostgresStore) LeaderboardAround(username string, window int, sortBy LeaderboardSort) ([]LeaderboardEntry, error) {
+	rows, err := s.db.Query(`
+		WITH ranked AS (`+rankedLeaderboardSQL(sortBy, 0)+`),
+		     me AS (SELECT rank FROM ranked WHERE username = $1)
+		SELECT username, wins, losses, draws, total_games, win_rate, ranked.rank, rating
+		FROM ranked, me
+		WHERE ranked.rank BETWEEN me.rank - $2 AND me.rank + $2
+		ORDER BY ranked.rank
+	`, username, window)
+	if err != nil {
+		return nil, err
+	}
+	return scanLeaderboard(rows)
+}
+
+func scanLeaderboard(rows *sql.Rows) ([]LeaderboardEntry, error) {
+	defer rows.Close()
+
+	var entries []LeaderboardEntry
+	for rows.Next() {
+		var entry LeaderboardEntry
+		err := rows.Scan(&entry.Username, &entry.Wins, &entry.Losses, &entry.Draws, &entry.TotalGames, &entry.WinRate, &entry.Rank, &entry.Rating)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func (s *PostgresStore) ListGames(tag string, limit int) ([]GameSummary, error) {
+	rows, err := s.db.Query(`
+		SELECT id, player1_username, player2_username, winner, status, started_at, ended_at, duration_seconds, tags
+		FROM games
+		WHERE $1 = '' OR $1 = ANY(tags)
+		ORDER BY ended_at DESC NULLS LAST
+		LIMIT $2
+	`, tag, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries := []GameSummary{}
+	for rows.Next() {
+		var summary GameSummary
+		var winner sql.NullString
+		err := rows.Scan(&summary.ID, &summary.Player1, &summary.Player2, &winner, &summary.Status,
+			&summary.StartedAt, &summary.EndedAt, &summary.DurationSeconds, pq.Array(&summary.Tags))
+		if err != nil {
+			return nil, err
+		}
+		summary.Winner = winner.String
+		summaries = append(summaries, summary)
+	}
+
+	return summaries, rows.Err()
+}
+
+func (s *PostgresStore) PlayerStats(username string, recentGames int) (*PlayerStats, error) {
+	stats := &PlayerStats{RecentGames: []PlayerGame{}}
+	entry := &stats.LeaderboardEntry
+	err := s.db.QueryRow(`
+		SELECT username, wins, losses, draws, total_games, win_rate, rank, rating
+		FROM (`+rankedLeaderboardSQL(LeaderboardSortWins, 0)+`) ranked
+		WHERE username = $1
+	`, username).Scan(&entry.Username, &entry.Wins, &entry.Losses, &entry.Draws, &entry.TotalGames, &entry.WinRate, &entry.Rank, &entry.Rating)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, player1_username, player2_username, player1_id, player2_id, winner, status, ended_at, duration_seconds
+		FROM games
+		WHERE player1_username = $1 OR player2_username = $1
+		ORDER BY ended_at DESC NULLS LAST
+		LIMIT $2
+	`, username, recentGames)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var record GameRecord
+		var player1ID, player2ID, winner sql.NullString
+		err := rows.Scan(&record.ID, &record.Player1, &record.Player2, &player1ID, &player2ID, &winner, &record.Status,
+			&record.EndedAt, &record.DurationSeconds)
+		if err != nil {
+			return nil, err
+		}
+		record.Player1ID, record.Player2ID, record.Winner = player1ID.String, player2ID.String, winner.String
+		stats.RecentGames = append(stats.RecentGames, playerGame(username, record))
+	}
+
+	return stats, rows.Err()
+}
+
+func (s *PostgresStore) SaveActiveGame(record ActiveGameRecord) error {
+	_, err := s.db.Exec(
+		`INSERT INTO active_games (id, moves, state, updated_at)
+		 VALUES ($1, $2, $3, NOW())
+		 ON CONFLICT (id) DO UPDATE SET moves = $2, state = $3, updated_at = NOW()
+		 WHERE active_games.moves <= $2`,
+		record.ID, record.Moves, []byte(record.State),
+	)
+	return err
+}
+
+func (s *PostgresStore) DeleteActiveGame(gameID string) error {
+	_, err := s.db.Exec(`DELETE FROM active_games WHERE id = $1`, gameID)
+	return err
+}
+
+func (s *PostgresStore) ActiveGames() ([]ActiveGameRecord, error) {
+	rows, err := s.db.Query(`SELECT id, moves, state FROM active_games`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []ActiveGameRecord
+	for rows.Next() {
+		var record ActiveGameRecord
+		var state []byte
+		if err := rows.Scan(&record.ID, &record.Moves, &state); err != nil {
+			return nil, err
+		}
+		record.State = state
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
+
+// FinishedGames skips (and logs) rows whose stored moves can't be decoded
+func (s *PostgresStore) FinishedGames() ([]*SavedGame, error) {
+	rows, err := s.db.Query(`SELECT ` + savedGameColumns + ` FROM games WHERE status = 'finished'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var games []*SavedGame
+	for rows.Next() {
+		var row savedGameRow
+		if err := rows.Scan(row.fields()...); err != nil {
+			return nil, err
+		}
+		saved, err := row.savedGame()
+		if err != nil {
+			slog.Warn("Skipping stored game", "gameId", row.summary.ID, "error", err)
+			continue
+		}
+		games = append(games, saved)
+	}
+
+	return games, rows.Err()
+}
+
+func (s *PostgresStore) LoadGame(gameID string) (*SavedGame, error) {
+	// games.id is a UUID column, which Postgres refuses to compare with
+	// anything else; no such game can exist
+	if _, err := uuid.Parse(gameID); err != nil {
+		return nil, nil
+	}
+
+	var row savedGameRow
+	err := s.db.QueryRow(`SELECT `+savedGameColumns+` FROM games WHERE id = $1`, gameID).Scan(row.fields()...)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return row.savedGame()
+}
+
+// savedGameColumns are the columns of a games row read into a savedGameRow
+const savedGameColumns = `id, player1_username, player2_username, player1_id, player2_id, winner, status,
+	started_at, ended_at, duration_seconds, tags, moves, starting_board, dimensions`
+
+// savedGameRow scans savedGameColumns
+type savedGameRow struct {
+	summary                                      GameSummary
+	player1ID, player2ID, winner                 sql.NullString
+	movesJSON, startingBoardJSON, dimensionsJSON []byte
+}
+
+func (r *savedGameRow) fields() []interface{} {
+	return []interface{}{
+		&r.summary.ID, &r.summary.Player1, &r.summary.Player2, &r.player1ID, &r.player2ID, &r.winner, &r.summary.Status,
+		&r.summary.StartedAt, &r.summary.EndedAt, &r.summary.DurationSeconds, pq.Array(&r.summary.Tags),
+		&r.movesJSON, &r.startingBoardJSON, &r.dimensionsJSON,
+	}
+}
+
+func (r *savedGameRow) savedGame() (*SavedGame, error) {
+	r.summary.Winner = r.winner.String
+	return savedGameFromJSON(r.summary, r.player1ID.String, r.player2ID.String, r.movesJSON, r.startingBoardJSON, r.dimensionsJSON)
+}
@@ -0,0 +1,93 @@
+package game
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Defaults for the idle game sweeper
+const (
+	DefaultSweepInterval   = time.Minute
+	DefaultIdleGameTimeout = 30 * time.Minute
+)
+
+// StartSweeper checks every interval for games nobody is in any more, so
+// they don't stay in memory forever. A game qualifies once no human player
+// is connected and nothing has happened in it for idleTimeout. An active one
+// is abandoned and saved like any other abandoned game; a finished one has
+// been saved already and is just dropped. Games with an open reconnect window
+// are left for the window to settle. An interval of 0 or less disables the
+// sweeper. Call the returned function to stop it.
+func (m *Manager) StartSweeper(interval, idleTimeout time.Duration) (stop func()) {
+	if interval <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.sweepIdleGames(idleTimeout)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// sweepIdleGames drops the games idle for longer than idleTimeout with nobody
+// connected, then finalizes the active ones it abandoned. It holds mu while
+// it decides, so a move can't land in a game it is removing.
+func (m *Manager) sweepIdleGames(idleTimeout time.Duration) {
+	cutoff := time.Now().Add(-idleTimeout)
+
+	m.mu.Lock()
+	var abandoned []*Game
+	dropped := 0
+	for gameID, game := range m.games {
+		if _, waiting := m.reconnectWindows[gameID]; waiting {
+			continue
+		}
+		if game.Player1.connected() || game.Player2.connected() || game.idleSince().After(cutoff) {
+			continue
+		}
+
+		switch game.Status {
+		case "active":
+			game.logEvent("idle", "no players connected since "+game.idleSince().Format(time.RFC3339))
+			if abandonedGame := m.abandonGame(gameID); abandonedGame != nil {
+				abandoned = append(abandoned, abandonedGame.snapshot())
+			}
+		case "finished":
+			m.clearRematch(game)
+			delete(m.games, gameID)
+			dropped++
+		}
+	}
+	m.unlock()
+
+	for _, game := range abandoned {
+		slog.Info("Abandoning idle game", "gameId", game.ID)
+		m.finalize(game)
+	}
+	if len(abandoned) > 0 || dropped > 0 {
+		slog.Info("Swept idle games", "abandoned", len(abandoned), "finished", dropped)
+	}
+}
+
+// idleSince is when anything last happened in the game
+func (g *Game) idleSince() time.Time {
+	if g.EndedAt != nil && g.EndedAt.After(g.LastMoveAt) {
+		return *g.EndedAt
+	}
+	return g.LastMoveAt
+}
+
+// connected reports whether the player is a human with an open connection
+func (p *Player) connected() bool {
+	return !p.IsBot && p.Conn != nil && !p.disconnected
+}
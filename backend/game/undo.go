@@ -0,0 +1,188 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// OfferUndo asks the opponent to let the player on conn take back the move
+// they just played, including one that finished the game while it is still
+// unsaved (see UndoLastMove). The offer stays pending until the opponent
+// answers with RespondUndo, and lapses if the opponent moves instead.
+func (m *Manager) OfferUndo(gameID string, conn *websocket.Conn) *GameMoveResult {
+	m.mu.Lock()
+	defer m.unlock()
+
+	game, exists := m.games[gameID]
+	if !exists {
+		return &GameMoveResult{Success: false, Message: "Game not found", Code: CodeGameNotFound}
+	}
+	if !takebackOpen(game) {
+		return &GameMoveResult{Success: false, Message: "Game is not active", Code: CodeGameNotActive}
+	}
+
+	player := game.playerByConn(conn)
+	if player == nil {
+		return &GameMoveResult{Success: false, Message: "Not a player in this game", Code: CodeNotYourGame}
+	}
+	if message := undoableMessage(game, player.ID); message != "" {
+		return &GameMoveResult{Success: false, Message: message}
+	}
+	if game.UndoOfferBy == player.ID {
+		return &GameMoveResult{Success: false, Message: "Your takeback request is still waiting for an answer"}
+	}
+	if message := m.requestCooldownMessage(game, player.ID, RequestUndo); message != "" {
+		return &GameMoveResult{Success: false, Message: message}
+	}
+
+	game.UndoOfferBy = player.ID
+	game.noteRequest(player.ID, RequestUndo)
+	game.logEvent("undoOffered", "player="+player.ID)
+
+	return &GameMoveResult{Success: true, Game: game.snapshot()}
+}
+
+// takebackOpen reports whether the last move of game may still be taken
+// back: it is active, or was just finished by that move and nothing has acted
+// on the result yet. That rules out games already scored, left by a player
+// or rematched, and tournament games, whose bracket moves on as soon as they
+// end. mu must be held.
+func takebackOpen(game *Game) bool {
+	if game.Status == "active" {
+		return true
+	}
+	if game.Status != "finished" || game.settled || game.playerLeft || game.RematchGameID != "" || hasTag(game.Tags, TagTournament) {
+		return false
+	}
+	switch game.ResultType {
+	case ResultWin, ResultDraw, ResultOutOfDiscs, ResultDeadPosition:
+		return true
+	}
+	return false
+}
+
+// undoableMessage explains why playerID can't take back the last move, or
+// returns "" if they can
+func undoableMessage(game *Game, playerID string) string {
+	if game.CurrentPlayer == BotID {
+		return "Can't take back a move during the bot's turn"
+	}
+	if len(game.Moves) == 0 {
+		return "No move to take back"
+	}
+	if game.Moves[len(game.Moves)-1].Player != playerID {
+		return "You can only take back your own last move"
+	}
+	return ""
+}
+
+// RespondUndo answers the pending takeback request. Accepting takes back the
+// requesting player's last move and hands the turn back to them.
+func (m *Manager) RespondUndo(gameID string, conn *websocket.Conn, accept bool) *GameMoveResult {
+	result, _ := m.locked(func() *GameMoveResult { return m.respondUndo(gameID, conn, accept) })
+	return result
+}
+
+func (m *Manager) respondUndo(gameID string, conn *websocket.Conn, accept bool) *GameMoveResult {
+	game, exists := m.games[gameID]
+	if !exists {
+		return &GameMoveResult{Success: false, Message: "Game not found", Code: CodeGameNotFound}
+	}
+	if !takebackOpen(game) {
+		return &GameMoveResult{Success: false, Message: "Game is not active", Code: CodeGameNotActive}
+	}
+
+	player := game.playerByConn(conn)
+	if player == nil {
+		return &GameMoveResult{Success: false, Message: "Not a player in this game", Code: CodeNotYourGame}
+	}
+	if game.UndoOfferBy == "" || game.UndoOfferBy == player.ID {
+		return &GameMoveResult{Success: false, Message: "No takeback request to respond to"}
+	}
+
+	offerBy := game.UndoOfferBy
+	game.UndoOfferBy = ""
+	if !accept {
+		game.logEvent("undoDeclined", "player="+player.ID)
+		return &GameMoveResult{Success: true, Game: game}
+	}
+
+	if message := undoableMessage(game, offerBy); message != "" {
+		return &GameMoveResult{Success: false, Message: message}
+	}
+	return m.undoLastMove(game)
+}
+
+// UndoLastMove takes back the last move of a game: the disc is removed (or,
+// for a pop out, put back at the bottom of its column) and the player who
+// made the move is to move again. A game that move won or drew is reopened,
+// as long as it hasn't been saved yet; once SaveGame has scored it, or a
+// player has left, the result stands.
+func (m *Manager) UndoLastMove(gameID string) *GameMoveResult {
+	result, _ := m.locked(func() *GameMoveResult {
+		game, exists := m.games[gameID]
+		if !exists {
+			return &GameMoveResult{Success: false, Message: "Game not found", Code: CodeGameNotFound}
+		}
+		if !takebackOpen(game) {
+			return &GameMoveResult{Success: false, Message: "Game is not active", Code: CodeGameNotActive}
+		}
+		if len(game.Moves) == 0 {
+			return &GameMoveResult{Success: false, Message: "No move to take back"}
+		}
+		return m.undoLastMove(game)
+	})
+	return result
+}
+
+// undoLastMove reverts the last move of a game takebackOpen allows. mu must
+// be held.
+func (m *Manager) undoLastMove(game *Game) *GameMoveResult {
+	if game.Status != "active" {
+		m.reopen(game)
+	}
+
+	last := game.Moves[len(game.Moves)-1]
+	bottom := len(game.Board) - 1
+	if last.Pop {
+		for row := 0; row < bottom; row++ {
+			game.Board[row][last.Column] = game.Board[row+1][last.Column]
+		}
+		game.Board[bottom][last.Column] = last.Player
+	} else {
+		game.Board[last.Row][last.Column] = nil
+	}
+
+	if game.DiscsRemaining != nil {
+		if last.Pop {
+			game.DiscsRemaining[last.Player]--
+		} else {
+			game.DiscsRemaining[last.Player]++
+		}
+	}
+	game.Moves = game.Moves[:len(game.Moves)-1]
+	game.CurrentPlayer = last.Player
+	game.PendingMove = nil
+	game.WinningCells = nil
+	game.LastMoveAt = time.Now()
+	game.logEvent("undo", fmt.Sprintf("player=%s column=%d", last.Player, last.Column))
+	m.startTurnClock(game)
+
+	return &GameMoveResult{Success: true, Game: game}
+}
+
+// reopen puts a game finished by its last move back in play, with a fresh
+// context as the old one was cancelled when it ended. mu must be held.
+func (m *Manager) reopen(game *Game) {
+	game.Status = "active"
+	game.Winner = ""
+	game.ResultType = ""
+	game.EndedAt = nil
+	game.ctx, game.cancel = context.WithCancel(context.Background())
+	game.final = &finalGame{}
+	m.clearRematch(game)
+	game.logEvent("reopened", "")
+}
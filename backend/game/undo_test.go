@@ -0,0 +1,84 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// playToWin has alice (p1) stack column 0 against bob in column 1 until her
+// fourth disc wins, returning the result of the winning move
+func playToWin(t *testing.T, m *Manager, gameID string, conn1, conn2 *websocket.Conn) *GameMoveResult {
+	t.Helper()
+	for i := 0; i < 3; i++ {
+		m.MakeMove(gameID, 0, conn1)
+		m.MakeMove(gameID, 1, conn2)
+	}
+	result := m.MakeMove(gameID, 0, conn1)
+	if result.Game.Status != "finished" || result.Game.Winner != "p1" {
+		t.Fatalf("game is %s with winner %q, want p1 to have won", result.Game.Status, result.Game.Winner)
+	}
+	return result
+}
+
+func TestTakebackReopensAnUnsavedFinishedGame(t *testing.T) {
+	store := NewMemoryStore()
+	m := NewManager(store, nil, Options{MoveClock: time.Hour, MoveClockAction: MoveClockForfeit})
+	conn1, conn2 := &websocket.Conn{}, &websocket.Conn{}
+	g := m.CreateGame(humans(conn1, conn2))
+	won := playToWin(t, m, g.ID, conn1, conn2)
+
+	if result := m.OfferUndo(g.ID, conn1); !result.Success {
+		t.Fatalf("OfferUndo on the finished game: %s", result.Message)
+	}
+	result := m.RespondUndo(g.ID, conn2, true)
+	if !result.Success {
+		t.Fatalf("RespondUndo: %s", result.Message)
+	}
+
+	reopened := result.Game
+	if reopened.Status != "active" || reopened.Winner != "" || reopened.ResultType != "" || reopened.EndedAt != nil || reopened.WinningCells != nil {
+		t.Errorf("reopened game is %s, winner %q, result %q, ended %v, winning cells %v",
+			reopened.Status, reopened.Winner, reopened.ResultType, reopened.EndedAt, reopened.WinningCells)
+	}
+	if reopened.CurrentPlayer != "p1" || len(reopened.Moves) != 6 || reopened.Board[2][0] != nil {
+		t.Errorf("alice's winning disc not taken back: %s to move after %d moves", reopened.CurrentPlayer, len(reopened.Moves))
+	}
+	if won.Game.Context().Err() == nil || reopened.Context().Err() != nil {
+		t.Error("want the finished game's context cancelled and the reopened one's live")
+	}
+	if deadline, running := turnClock(m, g.ID); deadline == nil || !running {
+		t.Error("move clock not restarted")
+	}
+	if page, _ := store.Leaderboard(LeaderboardQuery{Limit: 10}); page.Total != 0 {
+		t.Errorf("leaderboard has %d entries for a game that was taken back", page.Total)
+	}
+
+	if result := m.MakeMove(g.ID, 2, conn1); !result.Success || result.Game.Status != "active" {
+		t.Errorf("play after the takeback: %+v", result)
+	}
+}
+
+func TestTakebackRefusedOnceTheGameIsSaved(t *testing.T) {
+	store := NewMemoryStore()
+	m := NewManager(store, nil, Options{})
+	conn1, conn2 := &websocket.Conn{}, &websocket.Conn{}
+	g := m.CreateGame(humans(conn1, conn2))
+	won := playToWin(t, m, g.ID, conn1, conn2)
+
+	m.SaveGame(won.Game)
+	if result := m.UndoLastMove(g.ID); result.Success || result.Code != CodeGameNotActive {
+		t.Errorf("UndoLastMove on a saved game = %+v, want %s", result, CodeGameNotActive)
+	}
+	if result := m.OfferUndo(g.ID, conn1); result.Success {
+		t.Error("takeback offered on a saved game")
+	}
+
+	// Saving again must not score the game twice
+	m.SaveGame(won.Game)
+	page, _ := store.Leaderboard(LeaderboardQuery{Limit: 10})
+	if len(page.Entries) != 2 || page.Entries[0].Wins != 1 || page.Entries[1].Losses != 1 {
+		t.Errorf("leaderboard = %+v, want alice 1-0 and bob 0-1", page.Entries)
+	}
+}
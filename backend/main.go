@@ -2,16 +2,33 @@ package main
 
 import (
 	"connect-four/analytics"
+	"connect-four/auth"
 	"connect-four/bot"
+	"connect-four/config"
 	"connect-four/game"
 	"connect-four/matchmaking"
+	"connect-four/metrics"
+	"connect-four/moderation"
+	"connect-four/socket"
+	"connect-four/tournament"
+	"context"
+	"crypto/rand"
+	"database/sql"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
 	"net/http"
 	"os"
+	"os/signal"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 	_ "github.com/lib/pq"
@@ -24,12 +41,38 @@ var upgrader = websocket.Upgrader{
 }
 
 type Server struct {
+	config           *config.Config
+	db               *sql.DB
 	gameManager      *game.Manager
 	matchmaking      *matchmaking.Service
 	botPlayer        *bot.Player
 	analyticsService *analytics.Service
+	connections      int64 // open WebSocket connections, updated atomically
+	pendingBotMoves  int64 // bot replies waiting out BOT_MOVE_DELAY_MS, updated atomically
+	maxConnections   int64
+	logRejectedJoins bool
+	blocklist        *moderation.Blocklist
+	signer           *auth.Signer
+	users            *auth.Users
+	tournaments      *tournament.Service
+	draining         int32 // 1 while draining for a deploy, updated atomically
 }
 
+// Reasons a join is rejected, used as the metrics label
+const (
+	joinRejectEmptyUsername = "empty_username"
+	joinRejectUsername      = "invalid_username"
+	joinRejectStartingBoard = "invalid_starting_board"
+	joinRejectBanned        = "banned"
+	joinRejectDraining      = "draining"
+	joinRejectBotDifficulty = "invalid_bot_difficulty"
+	joinRejectDimensions    = "invalid_dimensions"
+	joinRejectFirstMove     = "invalid_first_move"
+	joinRejectAlreadyQueued = "already_queued"
+	joinRejectInGame        = "in_game"
+	joinRejectToken         = "unauthenticated"
+)
+
 // Adapter to make game.Manager implement matchmaking.GameManager interface
 type gameManagerAdapter struct {
 	manager *game.Manager
@@ -42,40 +85,159 @@ func (a *gameManagerAdapter) CreateGame(player1, player2 interface{}) interface{
 }
 
 func main() {
+	cfg := config.Load()
+	setupLogging(cfg.LogLevel)
+
 	// Initialize database
 	db, err := game.InitDB()
 	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		fatal("Failed to initialize database", "error", err)
 	}
 	defer db.Close()
 
 	// Initialize analytics service
-	analyticsService, err := analytics.NewService()
+	analyticsService, err := analytics.NewService(db)
 	if err != nil {
-		log.Printf("Warning: Analytics service initialization failed: %v", err)
-		log.Println("Continuing without analytics...")
+		slog.Warn("Analytics service initialization failed, continuing without analytics", "error", err)
 		analyticsService = nil
 	}
+	// Flush buffered events once the server has drained
+	defer analyticsService.Close()
+
+	moveRules, err := game.ParseMoveRules(cfg.MoveRules)
+	if err != nil {
+		fatal("Invalid MOVE_RULES", "error", err)
+	}
+	firstMove, ok := game.ParseFirstMove(cfg.FirstMove)
+	if !ok {
+		fatal("Invalid FIRST_MOVE", "value", cfg.FirstMove, "allowed", []game.FirstMove{game.FirstMovePlayer1, game.FirstMoveChallenger, game.FirstMoveCoinFlip})
+	}
+	if cfg.MoveClockAction != game.MoveClockForfeit && cfg.MoveClockAction != game.MoveClockRandomMove {
+		fatal("Invalid MOVE_CLOCK_ACTION", "value", cfg.MoveClockAction, "allowed", []string{game.MoveClockForfeit, game.MoveClockRandomMove})
+	}
 
 	// Initialize services
-	gameManager := game.NewManager(db, analyticsService)
+	gameManager := game.NewManager(game.NewPostgresStore(db), analyticsService, game.Options{
+		RequestCooldownMoves: cfg.RequestCooldownMoves,
+		MoveRules:            moveRules,
+		FirstMove:            firstMove,
+		DiscLimit:            cfg.DiscLimit,
+		PopOut:               cfg.PopOut,
+		PopOutTieRule:        cfg.PopOutTieRule,
+		ConfirmMoveTags:      cfg.ConfirmMoveTagList(),
+		RematchTimeout:       cfg.RematchTimeout(),
+		MoveClock:            cfg.MoveClock(),
+		MoveClockAction:      cfg.MoveClockAction,
+		ReconnectWindow:      cfg.ReconnectWindow(),
+	})
 	// Create adapter for matchmaking interface
 	gameManagerAdapter := &gameManagerAdapter{manager: gameManager}
-	matchmakingService := matchmaking.NewService(gameManagerAdapter, 10*time.Second)
-	botPlayer := bot.NewPlayer()
+	matchmakingService := matchmaking.NewService(gameManagerAdapter, cfg.MatchmakingTimeout(), cfg.PrivateRoomTTL())
+
+	blocklist, err := moderation.NewBlocklist(db, cfg.BannedUsernames)
+	if err != nil {
+		fatal("Failed to load username blocklist", "error", err)
+	}
+
+	authSecret := []byte(cfg.AuthSecret)
+	if len(authSecret) == 0 {
+		authSecret = make([]byte, 32)
+		if _, err := rand.Read(authSecret); err != nil {
+			fatal("Failed to generate a session token secret", "error", err)
+		}
+		slog.Warn("AUTH_SECRET is not set; session tokens won't survive a restart")
+	}
+	users, err := auth.NewUsers(db)
+	if err != nil {
+		fatal("Failed to load users", "error", err)
+	}
 
 	server := &Server{
+		config:           cfg,
+		db:               db,
 		gameManager:      gameManager,
 		matchmaking:      matchmakingService,
-		botPlayer:        botPlayer,
 		analyticsService: analyticsService,
+		maxConnections:   int64(cfg.MaxConnections),
+		logRejectedJoins: cfg.LogRejectedJoins,
+		blocklist:        blocklist,
+		signer:           auth.NewSigner(authSecret, cfg.AuthTokenTTL()),
+		users:            users,
+	}
+	// The server sees each bot decision first so it can stream it to debugging
+	// players before passing it on to analytics
+	server.botPlayer = bot.NewPlayer(server)
+	gameManager.OnTurnTimeout(func(result *game.GameMoveResult) {
+		server.handleMoveResult(nil, result)
+	})
+	matchmakingService.OnQueueChange(server.sendQueuePositions)
+	metrics.RegisterGauges(gameManager.ActiveGameCount, matchmakingService.WaitingCount, gameManager.ReconnectWindowCount)
+
+	server.tournaments, err = tournament.NewService(db, gameManager, server.notifyPlayers)
+	if err != nil {
+		fatal("Failed to load tournaments", "error", err)
+	}
+
+	// Games in play when the server last stopped wait for their players to
+	// rejoin
+	restored, err := gameManager.RestoreActiveGames(server.notifyPlayers)
+	if err != nil {
+		slog.Error("Failed to restore active games", "error", err)
 	}
+	for _, g := range restored {
+		if g.CurrentPlayer == game.BotID {
+			server.scheduleBotMove(g)
+		}
+	}
+	if len(restored) > 0 {
+		slog.Info("Restored active games", "count", len(restored))
+	}
+	stopSweeper := gameManager.StartSweeper(cfg.GameSweepInterval(), cfg.IdleGameTimeout())
+	defer stopSweeper()
+
+	httpServer := &http.Server{Addr: ":" + cfg.Port, Handler: server.router()}
+	go server.drainOnSignal(httpServer, cfg.DrainTimeout())
 
-	// Setup routes
+	slog.Info("Server starting", "port", cfg.Port)
+	if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		fatal("Server stopped", "error", err)
+	}
+}
+
+// router returns the HTTP routes of the API, WebSocket and metrics endpoints
+func (s *Server) router() *mux.Router {
 	r := mux.NewRouter()
-	r.HandleFunc("/api/leaderboard", server.getLeaderboard).Methods("GET")
-	r.HandleFunc("/api/health", server.healthCheck).Methods("GET")
-	r.HandleFunc("/ws", server.handleWebSocket)
+	r.HandleFunc("/api/auth", s.issueToken).Methods("POST")
+	r.HandleFunc("/api/leaderboard", s.getLeaderboard).Methods("GET")
+	r.HandleFunc("/api/leaderboard/around/{username}", s.getLeaderboardAround).Methods("GET")
+	r.HandleFunc("/api/players/{username}/stats", s.getPlayerStats).Methods("GET")
+	r.HandleFunc("/api/games", s.listGames).Methods("GET")
+	r.HandleFunc("/api/games/{id}", s.getGame).Methods("GET")
+	r.HandleFunc("/api/games/{id}/replay", s.getGameReplay).Methods("GET")
+	r.HandleFunc("/api/game/{id}/analysis", s.getGameAnalysis).Methods("GET")
+	r.HandleFunc("/api/live", s.listLiveGames).Methods("GET")
+	r.HandleFunc("/api/tournaments/{id}", s.getTournament).Methods("GET")
+	r.HandleFunc("/api/tournaments/{id}/results", s.getTournamentResults).Methods("GET")
+	r.HandleFunc("/api/config", s.getConfig).Methods("GET")
+	r.HandleFunc("/api/analytics/heatmap", s.getHeatmap).Methods("GET")
+	r.HandleFunc("/api/analytics/summary", s.getAnalyticsSummary).Methods("GET")
+	r.HandleFunc("/api/health", s.healthCheck).Methods("GET")
+	r.HandleFunc("/api/health/live", s.livenessCheck).Methods("GET")
+	r.HandleFunc("/api/health/ready", s.readinessCheck).Methods("GET")
+	r.HandleFunc("/ws", s.handleWebSocket)
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
+
+	// Admin routes (disabled unless ADMIN_TOKEN is set)
+	admin := r.PathPrefix("/api/admin").Subrouter()
+	admin.Use(adminMiddleware(s.config.AdminToken))
+	admin.HandleFunc("/status", s.getStatus).Methods("GET")
+	admin.HandleFunc("/games/{id}/events", s.getGameEvents).Methods("GET")
+	admin.HandleFunc("/bans", s.listBans).Methods("GET")
+	admin.HandleFunc("/bans", s.addBan).Methods("POST")
+	admin.HandleFunc("/bans/{pattern}", s.removeBan).Methods("DELETE")
+	admin.HandleFunc("/tournaments", s.createTournament).Methods("POST")
+	admin.HandleFunc("/drain", s.startDraining).Methods("POST")
+	admin.HandleFunc("/drain", s.stopDraining).Methods("DELETE")
 
 	// Handle favicon and root
 	r.HandleFunc("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
@@ -89,20 +251,58 @@ func main() {
 	// CORS middleware
 	r.Use(corsMiddleware)
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "3001"
+	return r
+}
+
+// setupLogging makes every log line, including those written through the
+// standard log package, a JSON object on stdout at or above level (LOG_LEVEL:
+// debug, info, warn or error)
+func setupLogging(level string) {
+	var minLevel slog.Level
+	if err := minLevel.UnmarshalText([]byte(level)); err != nil {
+		defer slog.Warn("Invalid LOG_LEVEL, using info", "value", level)
+		minLevel = slog.LevelInfo
+	}
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: minLevel})))
+}
+
+// fatal logs msg at error level and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// drainOnSignal handles SIGINT/SIGTERM by draining: no new connections or
+// joins, while active games get up to timeout to finish before shutdown.
+func (s *Server) drainOnSignal(httpServer *http.Server, timeout time.Duration) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	<-signals
+
+	slog.Info("Shutdown requested, draining", "timeout", timeout.String())
+	atomic.StoreInt32(&s.draining, 1)
+
+	deadline := time.Now().Add(timeout)
+	for s.gameManager.ActiveGameCount() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Second)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		slog.Error("HTTP server shutdown error", "error", err)
 	}
+}
 
-	log.Printf("Server starting on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, r))
+func (s *Server) isDraining() bool {
+	return atomic.LoadInt32(&s.draining) == 1
 }
 
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -113,14 +313,121 @@ func corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// adminMiddleware only lets through requests carrying the shared admin token
+// as a bearer token. With no token configured every admin route is rejected.
+func adminMiddleware(token string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" || r.Header.Get("Authorization") != "Bearer "+token {
+				writeError(w, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (s *Server) getConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.config)
+}
+
+// healthTimeout bounds the dependency checks of one health check
+const healthTimeout = 2 * time.Second
+
+// healthCheck reports whether the server can serve games: 200 if the
+// database and Kafka answer in time, otherwise 503. Both carry each
+// dependency's status. Analytics is optional, so Kafka being "disabled"
+// (analytics failed to start) doesn't make the server unhealthy.
 func (s *Server) healthCheck(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthTimeout)
+	defer cancel()
+
+	checks := map[string]string{"database": "ok", "kafka": "ok"}
+	healthy := true
+	if err := s.db.PingContext(ctx); err != nil {
+		slog.Warn("Health check failed", "dependency", "database", "error", err)
+		checks["database"] = "unavailable"
+		healthy = false
+	}
+	if s.analyticsService == nil {
+		checks["kafka"] = "disabled"
+	} else if err := s.analyticsService.Ping(ctx); err != nil {
+		slog.Warn("Health check failed", "dependency", "kafka", "error", err)
+		checks["kafka"] = "unavailable"
+		healthy = false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	status := "ok"
+	if !healthy {
+		status = "unavailable"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": status, "checks": checks})
+}
+
+// livenessCheck only shows that the process is up and serving HTTP
+func (s *Server) livenessCheck(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
+// readinessCheck tells load balancers whether to route new players here
+func (s *Server) readinessCheck(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if s.isDraining() {
+		writeError(w, http.StatusServiceUnavailable, "draining", "Server is draining")
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "ready", "draining": false})
+}
+
+func (s *Server) startDraining(w http.ResponseWriter, r *http.Request) {
+	atomic.StoreInt32(&s.draining, 1)
+	slog.Info("Draining enabled")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) stopDraining(w http.ResponseWriter, r *http.Request) {
+	atomic.StoreInt32(&s.draining, 0)
+	slog.Info("Draining disabled")
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (s *Server) getLeaderboard(w http.ResponseWriter, r *http.Request) {
-	leaderboard, err := s.gameManager.GetLeaderboard()
+	query := game.LeaderboardQuery{
+		Sort:  game.ParseLeaderboardSort(r.URL.Query().Get("sort")),
+		Limit: game.DefaultLeaderboardLimit,
+	}
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > game.MaxLeaderboardLimit {
+			writeError(w, http.StatusBadRequest, "invalid_limit", fmt.Sprintf("limit must be between 1 and %d", game.MaxLeaderboardLimit))
+			return
+		}
+		query.Limit = parsed
+	}
+	if value := r.URL.Query().Get("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			writeError(w, http.StatusBadRequest, "invalid_offset", "offset must be 0 or more")
+			return
+		}
+		query.Offset = parsed
+	}
+	if value := r.URL.Query().Get("minGames"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			writeError(w, http.StatusBadRequest, "invalid_min_games", "minGames must be 0 or more")
+			return
+		}
+		query.MinGames = parsed
+	}
+
+	leaderboard, err := s.gameManager.GetLeaderboard(query)
 	if err != nil {
-		http.Error(w, "Failed to fetch leaderboard", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, "leaderboard_unavailable", "Failed to fetch leaderboard")
 		return
 	}
 
@@ -128,229 +435,1769 @@ func (s *Server) getLeaderboard(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(leaderboard)
 }
 
-func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+func (s *Server) getLeaderboardAround(w http.ResponseWriter, r *http.Request) {
+	window := 5
+	if value := r.URL.Query().Get("window"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 || parsed > 50 {
+			writeError(w, http.StatusBadRequest, "invalid_window", "window must be between 0 and 50")
+			return
+		}
+		window = parsed
+	}
+
+	sortBy := game.ParseLeaderboardSort(r.URL.Query().Get("sort"))
+	entries, err := s.gameManager.GetLeaderboardAround(mux.Vars(r)["username"], window, sortBy)
 	if err != nil {
-		log.Printf("WebSocket upgrade error: %v", err)
+		writeError(w, http.StatusInternalServerError, "leaderboard_unavailable", "Failed to fetch leaderboard")
+		return
+	}
+	if len(entries) == 0 {
+		writeError(w, http.StatusNotFound, "player_not_ranked", "Player not ranked")
 		return
 	}
-	defer conn.Close()
 
-	log.Println("New WebSocket connection")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
 
-	// Handle messages
-	for {
-		var msg map[string]interface{}
-		err := conn.ReadJSON(&msg)
-		if err != nil {
-			log.Printf("WebSocket read error: %v", err)
-			s.matchmaking.RemovePlayer(conn)
-			s.gameManager.HandleDisconnect(conn, s.notifyPlayers)
-			break
+// getPlayerStats serves one player's record and their latest games
+func (s *Server) getPlayerStats(w http.ResponseWriter, r *http.Request) {
+	limit := 10
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 || parsed > 50 {
+			writeError(w, http.StatusBadRequest, "invalid_limit", "limit must be between 0 and 50")
+			return
 		}
+		limit = parsed
+	}
 
-		msgType, ok := msg["type"].(string)
-		if !ok {
-			s.sendError(conn, "Invalid message format")
-			continue
-		}
+	stats, err := s.gameManager.GetPlayerStats(mux.Vars(r)["username"], limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "stats_unavailable", "Failed to fetch player stats")
+		return
+	}
+	if stats == nil {
+		writeError(w, http.StatusNotFound, "player_not_found", "Player has not played any games")
+		return
+	}
 
-		switch msgType {
-		case "join":
-			username, _ := msg["username"].(string)
-			s.handleJoin(conn, username)
-		case "rejoin":
-			username, _ := msg["username"].(string)
-			gameID, _ := msg["gameId"].(string)
-			s.handleRejoin(conn, username, gameID)
-		case "makeMove":
-			gameID, _ := msg["gameId"].(string)
-			column, _ := msg["column"].(float64)
-			s.handleMakeMove(conn, gameID, int(column))
-		default:
-			s.sendError(conn, "Unknown message type")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+func (s *Server) listGames(w http.ResponseWriter, r *http.Request) {
+	limit := 20
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > 100 {
+			writeError(w, http.StatusBadRequest, "invalid_limit", "limit must be between 1 and 100")
+			return
 		}
+		limit = parsed
 	}
+
+	games, err := s.gameManager.ListGames(r.URL.Query().Get("tag"), limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "games_unavailable", "Failed to fetch games")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(games)
 }
 
-func (s *Server) handleJoin(conn *websocket.Conn, username string) {
-	if username == "" {
-		s.sendError(conn, "Username is required")
+// getGame serves a game's record with its moves in order. A game still being
+// played is served as its live gameState instead.
+func (s *Server) getGame(w http.ResponseWriter, r *http.Request) {
+	gameID := mux.Vars(r)["id"]
+	if g := s.gameManager.GetGame(gameID); g != nil && g.Status == "active" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"live": true,
+			"game": gameStateMessage(g)["game"],
+		})
 		return
 	}
 
-	matchPlayer := &matchmaking.Player{
-		ID:        fmt.Sprintf("%d", time.Now().UnixNano()),
-		Username:  username,
-		Conn:      conn,
-		Connected: true,
+	saved, err := s.gameManager.GetSavedGame(gameID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "game_unavailable", "Failed to load game")
+		return
+	}
+	if saved == nil {
+		writeError(w, http.StatusNotFound, "game_not_found", "Game not found")
+		return
 	}
 
-	matchResult := s.matchmaking.AddPlayer(matchPlayer)
+	moves := make([]map[string]interface{}, len(saved.Moves))
+	for i, move := range saved.Moves {
+		moves[i] = savedMoveJSON(saved, move)
+	}
 
-	if matchResult.Matched {
-		// Convert matchmaking.Player to game.Player
-		player1 := convertToGamePlayer(matchResult.Player1)
-		player2 := convertToGamePlayer(matchResult.Player2)
-		// Start game with matched player
-		game := s.gameManager.CreateGame(player1, player2)
-		s.notifyPlayers(game)
-	} else {
-		// Waiting for opponent
-		s.sendMessage(conn, map[string]interface{}{
-			"type":    "waiting",
-			"message": "Waiting for opponent...",
-		})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"live": false,
+		"game": map[string]interface{}{
+			"id":               saved.ID,
+			"player1":          saved.Player1,
+			"player2":          saved.Player2,
+			"winner":           saved.Username(saved.Winner),
+			"status":           saved.Status,
+			"started_at":       saved.StartedAt,
+			"ended_at":         saved.EndedAt,
+			"duration_seconds": saved.DurationSeconds,
+			"tags":             saved.Tags,
+			"dimensions":       saved.Dimensions,
+			"startingBoard":    saved.StartingBoard,
+			"moves":            moves,
+		},
+	})
+}
 
-		// Schedule bot match if no opponent joins
-		s.matchmaking.ScheduleBotMatch(matchPlayer, func(p *matchmaking.Player) {
-			botPlayer := convertToGamePlayer(&matchmaking.Player{
-				ID:        "bot",
-				Username:  "Bot",
-				Conn:      nil,
-				Connected: true,
-				IsBot:     true,
-			})
-			player1 := convertToGamePlayer(p)
-			game := s.gameManager.CreateGame(player1, botPlayer)
-			s.notifyPlayers(game)
-
-			// Bot makes first move if it's bot's turn
-			if game.CurrentPlayer == "bot" {
-				time.AfterFunc(500*time.Millisecond, func() {
-					s.botPlayer.MakeMove(game, s.gameManager, s.notifyPlayers)
-				})
-			}
-		})
+// getGameReplay serves every board position of a saved game, one per move,
+// along with any problems found replaying it
+func (s *Server) getGameReplay(w http.ResponseWriter, r *http.Request) {
+	saved, err := s.gameManager.GetSavedGame(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "game_unavailable", "Failed to load game")
+		return
+	}
+	if saved == nil {
+		writeError(w, http.StatusNotFound, "game_not_found", "Game not found")
+		return
 	}
+
+	replay := game.ReplayGame(saved)
+	steps := make([]map[string]interface{}, len(replay.Steps))
+	for i, step := range replay.Steps {
+		steps[i] = map[string]interface{}{
+			"move":  savedMoveJSON(saved, step.Move),
+			"board": savedBoardJSON(saved, step.Board),
+		}
+	}
+	problems := replay.Problems
+	if problems == nil {
+		problems = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"gameId":       saved.ID,
+		"player1":      saved.Player1,
+		"player2":      saved.Player2,
+		"winner":       saved.Username(saved.Winner),
+		"dimensions":   saved.Dimensions,
+		"initialBoard": savedBoardJSON(saved, replay.InitialBoard),
+		"steps":        steps,
+		"consistent":   len(problems) == 0,
+		"problems":     problems,
+	})
 }
 
-func convertToGamePlayer(mp *matchmaking.Player) *game.Player {
-	return &game.Player{
-		ID:       mp.ID,
-		Username: mp.Username,
-		Conn:     mp.Conn,
-		IsBot:    mp.IsBot,
+// savedMoveJSON describes a saved move with the player's username
+func savedMoveJSON(saved *game.SavedGame, move game.Move) map[string]interface{} {
+	return map[string]interface{}{
+		"player":    saved.Username(move.Player),
+		"column":    move.Column,
+		"row":       move.Row,
+		"timestamp": move.Timestamp,
+		"offsetMs":  move.OffsetMs,
+		"pop":       move.Pop,
 	}
 }
 
-func (s *Server) handleRejoin(conn *websocket.Conn, username, gameID string) {
-	result := s.gameManager.RejoinGame(conn, username, gameID)
-	if result.Success {
-		s.notifyPlayers(result.Game)
-		// Notify opponent
-		if result.Game.Player1.Conn != nil {
-			s.sendMessage(result.Game.Player1.Conn, map[string]interface{}{
-				"type":     "playerReconnected",
-				"username": username,
-			})
-		}
-		if result.Game.Player2.Conn != nil {
-			s.sendMessage(result.Game.Player2.Conn, map[string]interface{}{
-				"type":     "playerReconnected",
-				"username": username,
-			})
+// savedBoardJSON converts a board of a saved game to usernames, as
+// gameStateMessage does for live games
+func savedBoardJSON(saved *game.SavedGame, board [][]interface{}) [][]interface{} {
+	converted := make([][]interface{}, len(board))
+	for i, row := range board {
+		converted[i] = make([]interface{}, len(row))
+		for j, cell := range row {
+			if id, ok := cell.(string); ok {
+				converted[i][j] = saved.Username(id)
+			}
 		}
-	} else {
-		s.sendError(conn, result.Message)
 	}
+	return converted
 }
 
-func (s *Server) handleMakeMove(conn *websocket.Conn, gameID string, column int) {
-	result := s.gameManager.MakeMove(gameID, column, conn)
+// getGameAnalysis lists the losing player's blunders in a saved game
+func (s *Server) getGameAnalysis(w http.ResponseWriter, r *http.Request) {
+	saved, err := s.gameManager.GetSavedGame(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "game_unavailable", "Failed to load game")
+		return
+	}
+	if saved == nil {
+		writeError(w, http.StatusNotFound, "game_not_found", "Game not found")
+		return
+	}
 
-	if !result.Success {
-		s.sendError(conn, result.Message)
+	analysis, err := bot.AnalyzeGame(saved)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "not_analyzable", fmt.Sprintf("Cannot analyze game: %v", err))
 		return
 	}
 
-	game := result.Game
-	s.notifyPlayers(game)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(analysis)
+}
+
+func (s *Server) listLiveGames(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.gameManager.LiveGames())
+}
 
-	// Check if game ended
-	if game.Status == "finished" {
-		s.gameManager.SaveGame(game)
-		if s.analyticsService != nil {
-			s.analyticsService.TrackGameEnd(game)
-		}
-	} else if game.CurrentPlayer == "bot" && game.Player2.IsBot {
-		// Bot makes move
-		time.AfterFunc(500*time.Millisecond, func() {
-			s.botPlayer.MakeMove(game, s.gameManager, s.notifyPlayers)
-		})
+func (s *Server) getHeatmap(w http.ResponseWriter, r *http.Request) {
+	heatmap, err := s.gameManager.GetHeatmap()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "heatmap_unavailable", "Failed to build heatmap")
+		return
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(heatmap)
 }
 
-func (s *Server) notifyPlayers(game *game.Game) {
-	// Convert board to use usernames instead of IDs for frontend
-	boardForFrontend := make([][]interface{}, len(game.Board))
-	for i, row := range game.Board {
-		boardForFrontend[i] = make([]interface{}, len(row))
-		for j, cell := range row {
-			if cell == nil {
-				boardForFrontend[i][j] = nil
-			} else if cell == game.Player1.ID {
-				boardForFrontend[i][j] = game.Player1.Username
-			} else if cell == game.Player2.ID || cell == "bot" {
-				boardForFrontend[i][j] = game.Player2.Username
-			} else {
-				boardForFrontend[i][j] = cell
+// getAnalyticsSummary serves aggregate statistics over the stored analytics
+// events, for games that ended on or after ?since= (a date or RFC 3339 time)
+func (s *Server) getAnalyticsSummary(w http.ResponseWriter, r *http.Request) {
+	if s.analyticsService == nil {
+		writeError(w, http.StatusServiceUnavailable, "analytics_unavailable", "Analytics is not running")
+		return
+	}
+
+	var since time.Time
+	if raw := r.URL.Query().Get("since"); raw != "" {
+		var err error
+		if since, err = time.Parse("2006-01-02", raw); err != nil {
+			if since, err = time.Parse(time.RFC3339, raw); err != nil {
+				writeError(w, http.StatusBadRequest, "invalid_since", "since must be a date (2006-01-02) or an RFC 3339 time")
+				return
 			}
 		}
 	}
 
-	// Convert currentPlayer to username for frontend
-	currentPlayerForFrontend := game.CurrentPlayer
-	if game.CurrentPlayer == game.Player1.ID {
-		currentPlayerForFrontend = game.Player1.Username
-	} else if game.CurrentPlayer == game.Player2.ID || game.CurrentPlayer == "bot" {
-		currentPlayerForFrontend = game.Player2.Username
+	summary, err := s.analyticsService.GetAggregates(since)
+	if err != nil {
+		slog.Error("Error aggregating analytics", "error", err)
+		writeError(w, http.StatusInternalServerError, "analytics_unavailable", "Failed to aggregate analytics")
+		return
 	}
 
-	// Convert winner to username for frontend
-	winnerForFrontend := game.Winner
-	if game.Winner == game.Player1.ID {
-		winnerForFrontend = game.Player1.Username
-	} else if game.Winner == game.Player2.ID || game.Winner == "bot" {
-		winnerForFrontend = game.Player2.Username
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// getStatus reports what the server is holding in memory, for spotting
+// stuck games and checking that finished ones are cleaned up
+func (s *Server) getStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"games":           s.gameManager.Status(),
+		"matchmaking":     s.matchmaking.Status(),
+		"pendingBotMoves": atomic.LoadInt64(&s.pendingBotMoves),
+		"connections":     atomic.LoadInt64(&s.connections),
+		"draining":        s.isDraining(),
+	})
+}
+
+func (s *Server) getGameEvents(w http.ResponseWriter, r *http.Request) {
+	events, ok := s.gameManager.GetGameEvents(mux.Vars(r)["id"])
+	if !ok {
+		writeError(w, http.StatusNotFound, "game_not_found", "Game not found")
+		return
 	}
 
-	gameState := map[string]interface{}{
-		"type": "gameState",
-		"game": map[string]interface{}{
-			"id":            game.ID,
-			"board":         boardForFrontend,
-			"currentPlayer": currentPlayerForFrontend,
-			"player1": map[string]interface{}{
-				"username": game.Player1.Username,
-				"isBot":    game.Player1.IsBot,
-			},
-			"player2": map[string]interface{}{
-				"username": game.Player2.Username,
-				"isBot":    game.Player2.IsBot,
-			},
-			"status": game.Status,
-			"winner": winnerForFrontend,
-		},
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
+
+func (s *Server) createTournament(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name    string   `json:"name"`
+		Players []string `json:"players"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_body", "Invalid request body")
+		return
 	}
 
-	if game.Player1.Conn != nil {
-		s.sendMessage(game.Player1.Conn, gameState)
+	t, err := s.tournaments.Create(body.Name, body.Players)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_tournament", fmt.Sprintf("Cannot create tournament: %v", err))
+		return
 	}
-	if game.Player2.Conn != nil {
-		s.sendMessage(game.Player2.Conn, gameState)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(t)
+}
+
+// getTournament returns the full bracket
+func (s *Server) getTournament(w http.ResponseWriter, r *http.Request) {
+	t := s.tournaments.Get(mux.Vars(r)["id"])
+	if t == nil {
+		writeError(w, http.StatusNotFound, "tournament_not_found", "Tournament not found")
+		return
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t)
 }
 
-func (s *Server) sendMessage(conn *websocket.Conn, msg map[string]interface{}) {
-	if conn != nil {
-		conn.WriteJSON(msg)
+func (s *Server) getTournamentResults(w http.ResponseWriter, r *http.Request) {
+	t := s.tournaments.Get(mux.Vars(r)["id"])
+	if t == nil {
+		writeError(w, http.StatusNotFound, "tournament_not_found", "Tournament not found")
+		return
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   t.Status,
+		"champion": t.Champion,
+		"matches":  t.Results(),
+	})
 }
 
-func (s *Server) sendError(conn *websocket.Conn, message string) {
+func (s *Server) listBans(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.blocklist.Patterns())
+}
+
+// issueToken signs a player in: it checks the username as a join would and,
+// for a password-protected username, the password, then returns a session
+// token to send with join, createRoom, joinRoom, joinTournament and rejoin.
+// A password given for an unprotected username claims it.
+func (s *Server) issueToken(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "Body must be a JSON object with a username")
+		return
+	}
+
+	username, err := moderation.ValidateUsername(body.Username)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_username", fmt.Sprintf("Invalid username: %v", err))
+		return
+	}
+	if s.blocklist.IsBanned(username) {
+		writeError(w, http.StatusForbidden, "banned", "This username is not allowed")
+		return
+	}
+
+	username, err = s.users.Authenticate(s.gameManager.CanonicalUsername(username), body.Password)
+	if err == auth.ErrWrongPassword {
+		writeError(w, http.StatusUnauthorized, "invalid_credentials", "Wrong password for this username")
+		return
+	}
+	if err != nil {
+		slog.Error("Error authenticating", "username", username, "error", err)
+		writeError(w, http.StatusInternalServerError, "auth_unavailable", "Failed to sign in")
+		return
+	}
+
+	token, expiresAt, err := s.signer.Issue(username)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "auth_unavailable", "Failed to sign in")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"token":     token,
+		"username":  username,
+		"expiresAt": expiresAt.Format(time.RFC3339),
+	})
+}
+
+func (s *Server) addBan(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Pattern string `json:"pattern"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Pattern == "" {
+		writeError(w, http.StatusBadRequest, "invalid_pattern", "pattern is required")
+		return
+	}
+	if err := s.blocklist.Add(body.Pattern); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_pattern", "Failed to add ban")
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) removeBan(w http.ResponseWriter, r *http.Request) {
+	if err := s.blocklist.Remove(mux.Vars(r)["pattern"]); err != nil {
+		writeError(w, http.StatusInternalServerError, "ban_removal_failed", "Failed to remove ban")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if s.isDraining() {
+		writeError(w, http.StatusServiceUnavailable, "draining", "Server draining, try again shortly")
+		return
+	}
+
+	// Reserve a connection slot before upgrading so a flood is turned away cheaply
+	if atomic.AddInt64(&s.connections, 1) > s.maxConnections {
+		atomic.AddInt64(&s.connections, -1)
+		metrics.RejectedConnections.Inc()
+		writeError(w, http.StatusServiceUnavailable, "too_many_connections", "Too many connections")
+		return
+	}
+	metrics.WebSocketConnections.Inc()
+	defer func() {
+		atomic.AddInt64(&s.connections, -1)
+		metrics.WebSocketConnections.Dec()
+	}()
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		slog.Warn("WebSocket upgrade error", "error", err)
+		return
+	}
+	defer conn.Close()
+	socket.Open(conn)
+	defer socket.Close(conn)
+
+	// Every line about this connection carries its ID
+	logger := slog.With("connId", uuid.New().String())
+	logger.Info("WebSocket connected", "remote", conn.RemoteAddr().String())
+
+	// A client that vanishes without closing the socket stops answering
+	// pings; the read deadline then fails ReadMessage like any other drop
+	stopHeartbeat := s.startHeartbeat(conn)
+	defer stopHeartbeat()
+
+	limits := s.newMessageLimits()
+
+	// Handle messages
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			logger.Info("WebSocket closed", "reason", err.Error())
+			s.disconnect(conn)
+			break
+		}
+		// A frame that isn't JSON is answered with an error rather than
+		// closing the connection; it still counts towards the rate limit
+		var msg map[string]interface{}
+		decodeErr := json.Unmarshal(data, &msg)
+
+		msgType, _ := msg["type"].(string)
+		if limit := limits.exceeded(msgType); limit != "" {
+			logger.Debug("Message rate limited", "messageType", msgType, "limit", limit)
+			metrics.RateLimitedMessages.WithLabelValues(limit).Inc()
+			s.sendMessage(conn, map[string]interface{}{
+				"type":        "rateLimited",
+				"limit":       limit,
+				"messageType": msgType,
+			})
+			continue
+		}
+
+		if decodeErr != nil {
+			logger.Debug("Malformed message", "error", decodeErr)
+			s.sendErrorCode(conn, codeInvalidMessage, "Invalid message format")
+			continue
+		}
+		if !s.handleMessage(conn, msg, logger) {
+			logger.Warn("WebSocket closed after a handler failure")
+			s.disconnect(conn)
+			break
+		}
+	}
+}
+
+// messageLimits holds one connection's rate limiters, one per kind of
+// message
+type messageLimits struct {
+	moves, chat, other *moderation.RateLimiter
+}
+
+func (s *Server) newMessageLimits() *messageLimits {
+	return &messageLimits{
+		moves: moderation.NewRateLimiter(s.config.MoveRateLimit),
+		chat:  moderation.NewRateLimiter(s.config.ChatRateLimit),
+		other: moderation.NewRateLimiter(s.config.MessageRateLimit),
+	}
+}
+
+// exceeded takes a token for a message of msgType and returns the name of
+// the limit it went over, or "" if it may be handled
+func (l *messageLimits) exceeded(msgType string) string {
+	switch msgType {
+	case "makeMove", "intendMove", "confirmMove", "popOut":
+		if !l.moves.Allow() {
+			return "moves"
+		}
+	case "chat":
+		if !l.chat.Allow() {
+			return "chat"
+		}
+	default:
+		if !l.other.Allow() {
+			return "messages"
+		}
+	}
+	return ""
+}
+
+// startHeartbeat pings conn every HeartbeatInterval and expects a pong
+// within HeartbeatTimeout. The returned func stops the pings. An interval
+// of 0 turns the heartbeat off.
+func (s *Server) startHeartbeat(conn *websocket.Conn) func() {
+	if s.config.HeartbeatInterval() <= 0 {
+		return func() {}
+	}
+
+	timeout := s.config.HeartbeatTimeout()
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(timeout))
+	})
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(s.config.HeartbeatInterval())
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				// WriteControl is safe alongside the handler's own writes
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(timeout)); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// disconnect releases everything conn held: its queue place, tournament
+// registrations, and any game it was playing or watching
+func (s *Server) disconnect(conn *websocket.Conn) {
+	s.matchmaking.RemovePlayer(conn)
+	s.tournaments.RemoveConn(conn)
+	s.gameManager.HandleDisconnect(conn, s.notifyPlayers)
+}
+
+// handleMessage dispatches one client message, logging through the
+// connection's logger. A handler that panics is logged and reported as not
+// handled so the connection is dropped cleanly instead of taking the process
+// down.
+func (s *Server) handleMessage(conn *websocket.Conn, msg map[string]interface{}, logger *slog.Logger) (handled bool) {
+	gameID, _ := msg["gameId"].(string)
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("Panic handling message", "messageType", msg["type"], "gameId", gameID, "remote", conn.RemoteAddr().String(), "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
+			metrics.HandlerPanics.Inc()
+			handled = false
+		}
+	}()
+
+	msgType, ok := msg["type"].(string)
+	logger.Debug("Message received", "messageType", msg["type"], "gameId", gameID)
+	if !ok {
+		s.sendErrorCode(conn, codeInvalidMessage, "Invalid message format")
+		return true
+	}
+
+	switch msgType {
+	case "join":
+		username, ok := s.authenticatedUsername(conn, msg)
+		if !ok {
+			return true
+		}
+		reconnectToken, _ := msg["reconnectToken"].(string)
+		idempotencyKey, _ := msg["idempotencyKey"].(string)
+		vsBot, _ := msg["vsBot"].(bool)
+		botDifficulty, _ := msg["botDifficulty"].(string)
+		firstMove, _ := msg["firstMove"].(string)
+		if s.replayJoin(conn, username, idempotencyKey) {
+			return true
+		}
+		s.handleJoin(conn, username, reconnectToken, idempotencyKey, vsBot, botDifficulty, firstMove, msg["dimensions"], msg["startingBoard"])
+	case "cancelJoin":
+		s.handleCancelJoin(conn)
+	case "createRoom":
+		username, ok := s.authenticatedUsername(conn, msg)
+		if !ok {
+			return true
+		}
+		firstMove, _ := msg["firstMove"].(string)
+		s.handleCreateRoom(conn, username, firstMove, msg["dimensions"])
+	case "joinRoom":
+		username, ok := s.authenticatedUsername(conn, msg)
+		if !ok {
+			return true
+		}
+		code, _ := msg["code"].(string)
+		s.handleJoinRoom(conn, username, code)
+	case "joinTournament":
+		tournamentID, _ := msg["tournamentId"].(string)
+		username, ok := s.authenticatedUsername(conn, msg)
+		if !ok {
+			return true
+		}
+		s.handleJoinTournament(conn, tournamentID, username)
+	case "rejoin":
+		username, ok := s.authenticatedUsername(conn, msg)
+		if !ok {
+			return true
+		}
+		gameID, ok := s.requiredGameID(conn, msg)
+		if !ok {
+			return true
+		}
+		reconnectToken, _ := msg["reconnectToken"].(string)
+		s.handleRejoin(conn, username, gameID, reconnectToken)
+	case "resync":
+		gameID, ok := s.requiredGameID(conn, msg)
+		if !ok {
+			return true
+		}
+		s.handleResync(conn, gameID)
+	case "spectate":
+		gameID, ok := s.requiredGameID(conn, msg)
+		if !ok {
+			return true
+		}
+		s.handleSpectate(conn, gameID)
+	case "spectateRandom":
+		s.handleSpectate(conn, "")
+	case "subscribeFeed":
+		s.gameManager.SubscribeFeed(conn)
+		s.sendMessage(conn, map[string]interface{}{"type": "feedSubscribed"})
+	case "resign":
+		gameID, ok := s.requiredGameID(conn, msg)
+		if !ok {
+			return true
+		}
+		s.handleResign(conn, gameID)
+	case "chat":
+		gameID, ok := s.requiredGameID(conn, msg)
+		if !ok {
+			return true
+		}
+		text, _ := msg["text"].(string)
+		s.handleChat(conn, gameID, text)
+	case "rematch":
+		gameID, ok := s.requiredGameID(conn, msg)
+		if !ok {
+			return true
+		}
+		s.handleRematch(conn, gameID)
+	case "offerDraw":
+		gameID, ok := s.requiredGameID(conn, msg)
+		if !ok {
+			return true
+		}
+		s.handleOfferDraw(conn, gameID)
+	case "respondDraw":
+		gameID, ok := s.requiredGameID(conn, msg)
+		if !ok {
+			return true
+		}
+		accept, _ := msg["accept"].(bool)
+		s.handleRespondDraw(conn, gameID, accept)
+	case "offerUndo":
+		gameID, ok := s.requiredGameID(conn, msg)
+		if !ok {
+			return true
+		}
+		s.handleOfferUndo(conn, gameID)
+	case "respondUndo":
+		gameID, ok := s.requiredGameID(conn, msg)
+		if !ok {
+			return true
+		}
+		accept, _ := msg["accept"].(bool)
+		s.handleRespondUndo(conn, gameID, accept)
+	case "debugBot":
+		gameID, ok := s.requiredGameID(conn, msg)
+		if !ok {
+			return true
+		}
+		s.handleDebugBot(conn, gameID)
+	case "makeMove":
+		gameID, ok := s.requiredGameID(conn, msg)
+		if !ok {
+			return true
+		}
+		column, ok := s.requiredColumn(conn, msg)
+		if !ok {
+			return true
+		}
+		s.handleMakeMove(conn, gameID, column)
+	case "intendMove":
+		gameID, ok := s.requiredGameID(conn, msg)
+		if !ok {
+			return true
+		}
+		column, ok := s.requiredColumn(conn, msg)
+		if !ok {
+			return true
+		}
+		s.handleIntendMove(conn, gameID, column)
+	case "confirmMove":
+		gameID, ok := s.requiredGameID(conn, msg)
+		if !ok {
+			return true
+		}
+		s.handleMoveResult(conn, s.gameManager.ConfirmMove(gameID, conn))
+	case "popOut":
+		gameID, ok := s.requiredGameID(conn, msg)
+		if !ok {
+			return true
+		}
+		column, ok := s.requiredColumn(conn, msg)
+		if !ok {
+			return true
+		}
+		s.handleMoveResult(conn, s.gameManager.PopOut(gameID, column, conn))
+	default:
+		s.sendError(conn, "Unknown message type")
+	}
+	return true
+}
+
+// codeInvalidMessage marks an error for a message with a missing or
+// malformed field
+const codeInvalidMessage = "invalidMessage"
+
+// requiredGameID returns the message's gameId, or sends an error and reports
+// false if it is missing, empty or not a string
+func (s *Server) requiredGameID(conn *websocket.Conn, msg map[string]interface{}) (string, bool) {
+	gameID, ok := msg["gameId"].(string)
+	if !ok || gameID == "" {
+		s.sendErrorCode(conn, codeInvalidMessage, "gameId must be a non-empty string")
+		return "", false
+	}
+	return gameID, true
+}
+
+// requiredColumn returns the message's column, or sends an error and reports
+// false unless it is a whole number. Whether the column is on the board is
+// left to the move itself.
+func (s *Server) requiredColumn(conn *websocket.Conn, msg map[string]interface{}) (int, bool) {
+	column, ok := msg["column"].(float64)
+	if !ok || column != math.Trunc(column) || math.Abs(column) > math.MaxInt32 {
+		s.sendErrorCode(conn, codeInvalidMessage, "column must be a whole number")
+		return 0, false
+	}
+	return int(column), true
+}
+
+func (s *Server) handleJoin(conn *websocket.Conn, username, reconnectToken, idempotencyKey string, vsBot bool, rawBotDifficulty, rawFirstMove string, rawDimensions, rawStartingBoard interface{}) {
+	username, ok := s.admitJoin(conn, username)
+	if !ok {
+		return
+	}
+
+	// Bot strength, only used if the player ends up against the bot
+	botDifficulty, ok := bot.ParseDifficulty(rawBotDifficulty)
+	if !ok {
+		s.rejectJoin(conn, username, joinRejectBotDifficulty, "botDifficulty must be easy, medium or hard")
+		return
+	}
+
+	// Who starts, only used if the player ends up against the bot
+	firstMove, ok := parseFirstMoveChoice(rawFirstMove)
+	if !ok {
+		s.rejectJoin(conn, username, joinRejectFirstMove, firstMoveChoiceMessage)
+		return
+	}
+
+	dimensions, err := parseDimensions(rawDimensions)
+	if err != nil {
+		s.rejectJoin(conn, username, joinRejectDimensions, fmt.Sprintf("Invalid dimensions: %v", err))
+		return
+	}
+	if rawStartingBoard != nil && !dimensions.IsStandard() {
+		s.rejectJoin(conn, username, joinRejectDimensions, "A starting board can only be used on the standard board")
+		return
+	}
+	// Matches with other players always start from an empty board, so a
+	// handicap is only accepted for a bot game started straight away
+	if rawStartingBoard != nil && !vsBot {
+		s.rejectJoin(conn, username, joinRejectStartingBoard, "A starting board can only be used with vsBot")
+		return
+	}
+
+	// A queued player whose socket blipped gets their old place back
+	var matchPlayer *matchmaking.Player
+	if !vsBot {
+		matchPlayer = s.matchmaking.ResumePlayer(reconnectToken, username, conn)
+	}
+	resumed := matchPlayer != nil
+	if !resumed {
+		rating, err := s.gameManager.PlayerRating(username)
+		if err != nil {
+			slog.Error("Error looking up rating", "username", username, "error", err)
+			rating = game.DefaultRating
+		}
+		matchPlayer = &matchmaking.Player{
+			ID:             fmt.Sprintf("%d", time.Now().UnixNano()),
+			Username:       username,
+			Conn:           conn,
+			Connected:      true,
+			ReconnectToken: reconnectToken,
+			Dimensions:     dimensions,
+			Elo:            rating,
+			FirstMove:      firstMove,
+		}
+	}
+
+	// Optional handicap position for a practice game
+	var startingBoard [][]interface{}
+	if rawStartingBoard != nil {
+		board, err := parseStartingBoard(rawStartingBoard, matchPlayer.ID)
+		if err == nil {
+			err = game.ValidateHandicapBoard(board)
+		}
+		if err != nil {
+			s.rejectJoin(conn, username, joinRejectStartingBoard, fmt.Sprintf("Invalid starting board: %v", err))
+			return
+		}
+		startingBoard = board
+	}
+
+	// Practice games skip the queue and the matchmaking timeout entirely
+	if vsBot {
+		s.matchmaking.RememberJoin(idempotencyKey, matchPlayer)
+		s.startBotGame(matchPlayer, startingBoard, botDifficulty, true)
+		return
+	}
+
+	if resumed {
+		s.sendWaiting(conn, matchPlayer.ID)
+		s.scheduleBotMatch(matchPlayer, botDifficulty)
+		return
+	}
+
+	matchResult := s.matchmaking.AddPlayer(matchPlayer)
+	if matchResult.AlreadyQueued {
+		s.rejectJoin(conn, username, joinRejectAlreadyQueued, "Already in queue")
+		return
+	}
+	s.matchmaking.RememberJoin(idempotencyKey, matchPlayer)
+
+	if matchResult.Matched {
+		// Convert matchmaking.Player to game.Player
+		player1 := convertToGamePlayer(matchResult.Player1)
+		player2 := convertToGamePlayer(matchResult.Player2)
+		// Start game with matched player
+		g := s.gameManager.CreateGameWithDimensions(player1, player2, matchResult.Player1.Dimensions)
+		s.notifyPlayers(g)
+	} else {
+		// Waiting for opponent
+		s.sendWaiting(conn, matchPlayer.ID)
+
+		// Schedule bot match if no opponent joins
+		s.scheduleBotMatch(matchPlayer, botDifficulty)
+	}
+}
+
+// handleCancelJoin takes the player out of the matchmaking queue before they
+// are matched. A player who was matched first gets their gameState as usual
+// and an error here instead of the confirmation.
+func (s *Server) handleCancelJoin(conn *websocket.Conn) {
+	if !s.matchmaking.CancelMatch(conn) {
+		s.sendError(conn, "Not waiting for a match")
+		return
+	}
+	s.sendMessage(conn, map[string]interface{}{"type": "joinCancelled"})
+}
+
+// sendWaiting tells a queued player they are waiting, with their place in
+// the queue and an estimate of how long it will take
+func (s *Server) sendWaiting(conn *websocket.Conn, playerID string) {
+	position, wait := s.matchmaking.QueuePosition(playerID)
+	s.sendMessage(conn, map[string]interface{}{
+		"type":                 "waiting",
+		"message":              "Waiting for opponent...",
+		"position":             position,
+		"estimatedWaitSeconds": waitSeconds(wait),
+	})
+}
+
+// sendQueuePositions tells every connected waiting player their new place
+// after someone has left the queue
+func (s *Server) sendQueuePositions(entries []matchmaking.QueueEntry) {
+	for _, entry := range entries {
+		s.sendMessage(entry.Conn, map[string]interface{}{
+			"type":                 "queuePosition",
+			"position":             entry.Position,
+			"estimatedWaitSeconds": waitSeconds(entry.EstimatedWait),
+		})
+	}
+}
+
+// waitSeconds rounds a wait estimate up to whole seconds
+func waitSeconds(wait time.Duration) int {
+	return int((wait + time.Second - 1) / time.Second)
+}
+
+// replayJoin answers a retried join whose idempotency key the same user
+// already used with the original outcome instead of queueing the player a
+// second time. It returns false if the key is new to username.
+func (s *Server) replayJoin(conn *websocket.Conn, username, idempotencyKey string) bool {
+	player := s.matchmaking.RecallJoin(username, idempotencyKey)
+	if player == nil {
+		return false
+	}
+
+	if s.matchmaking.ReattachWaiting(player.ID, conn) {
+		s.sendWaiting(conn, player.ID)
+		return true
+	}
+
+	g := s.gameManager.GameForPlayer(player.ID)
+	if g == nil {
+		return false
+	}
+	gamePlayer := g.Player1
+	if g.Player2.ID == player.ID {
+		gamePlayer = g.Player2
+	}
+	if gamePlayer.Conn != conn {
+		s.sendError(conn, "Already in a game, use rejoin to reconnect")
+		return true
+	}
+	s.sendMessage(conn, withSeat(gameStateMessage(g), gamePlayer))
+	return true
+}
+
+// authenticatedUsername returns the username the message's session token was
+// issued for, rejecting the message if the token is missing or invalid. The
+// username field clients send is not trusted.
+func (s *Server) authenticatedUsername(conn *websocket.Conn, msg map[string]interface{}) (string, bool) {
+	claimed, _ := msg["username"].(string)
+	token, ok := msg["token"].(string)
+	if _, present := msg["token"]; present && !ok {
+		s.rejectJoin(conn, claimed, joinRejectToken, "token must be a string")
+		return "", false
+	}
+	username, err := s.signer.Verify(token)
+	if err != nil {
+		message := "Session expired or invalid, sign in again"
+		if err == auth.ErrMissingToken {
+			message = "Sign in first: a session token is required"
+		}
+		s.rejectJoin(conn, claimed, joinRejectToken, message)
+		return "", false
+	}
+	return username, true
+}
+
+// admitJoin runs the checks shared by every way of entering a game and
+// rejects the join if one fails. It returns the username to play under:
+// trimmed, and spelled as on the leaderboard if the player is already there
+// in another case.
+func (s *Server) admitJoin(conn *websocket.Conn, username string) (string, bool) {
+	if s.isDraining() {
+		s.rejectJoin(conn, username, joinRejectDraining, "Server draining, try again shortly")
+		return "", false
+	}
+	username, err := moderation.ValidateUsername(username)
+	if username == "" {
+		s.rejectJoin(conn, username, joinRejectEmptyUsername, "Username is required")
+		return "", false
+	}
+	if err != nil {
+		s.rejectJoin(conn, username, joinRejectUsername, fmt.Sprintf("Invalid username: %v", err))
+		return "", false
+	}
+	if s.blocklist.IsBanned(username) {
+		s.rejectJoin(conn, username, joinRejectBanned, "This username is not allowed")
+		return "", false
+	}
+	// One game at a time, so nobody can play two matches at once
+	if s.gameManager.PlayingGameID(username) != "" {
+		s.rejectJoin(conn, username, joinRejectInGame, "You are already playing a game; finish it, resign or rejoin it first")
+		return "", false
+	}
+	return s.gameManager.CanonicalUsername(username), true
+}
+
+// handleCreateRoom opens a private room and sends its join code to the host,
+// who waits there until someone uses the code or the room expires
+func (s *Server) handleCreateRoom(conn *websocket.Conn, username, rawFirstMove string, rawDimensions interface{}) {
+	username, ok := s.admitJoin(conn, username)
+	if !ok {
+		return
+	}
+
+	firstMove, ok := parseFirstMoveChoice(rawFirstMove)
+	if !ok {
+		s.rejectJoin(conn, username, joinRejectFirstMove, firstMoveChoiceMessage)
+		return
+	}
+
+	dimensions, err := parseDimensions(rawDimensions)
+	if err != nil {
+		s.rejectJoin(conn, username, joinRejectDimensions, fmt.Sprintf("Invalid dimensions: %v", err))
+		return
+	}
+
+	host := &matchmaking.Player{
+		ID:         fmt.Sprintf("%d", time.Now().UnixNano()),
+		Username:   username,
+		Conn:       conn,
+		Connected:  true,
+		Dimensions: dimensions,
+		FirstMove:  firstMove,
+	}
+	room := s.matchmaking.CreateRoom(host, func(expired *matchmaking.Room) {
+		s.sendMessage(expired.Host.Conn, map[string]interface{}{
+			"type": "roomExpired",
+			"code": expired.Code,
+		})
+	})
+
+	s.sendMessage(conn, map[string]interface{}{
+		"type":             "roomWaiting",
+		"code":             room.Code,
+		"expiresAt":        room.ExpiresAt.Format(time.RFC3339),
+		"expiresInSeconds": int(time.Until(room.ExpiresAt).Round(time.Second) / time.Second),
+	})
+}
+
+// handleJoinRoom starts a private game with the host of the room
+func (s *Server) handleJoinRoom(conn *websocket.Conn, username, code string) {
+	username, ok := s.admitJoin(conn, username)
+	if !ok {
+		return
+	}
+
+	matchResult := s.matchmaking.JoinRoom(strings.ToUpper(code), &matchmaking.Player{
+		ID:        fmt.Sprintf("%d", time.Now().UnixNano()),
+		Username:  username,
+		Conn:      conn,
+		Connected: true,
+	})
+	if !matchResult.Matched {
+		s.sendError(conn, "Room not found or expired")
+		return
+	}
+
+	g := s.gameManager.CreatePrivateGame(convertToGamePlayer(matchResult.Player1), convertToGamePlayer(matchResult.Player2), matchResult.Player1.Dimensions, matchResult.Player1.FirstMove)
+	s.notifyPlayers(g)
+}
+
+// rejectJoin reports a refused join to the client and counts it by reason
+func (s *Server) rejectJoin(conn *websocket.Conn, username, reason, message string) {
+	metrics.JoinRejections.WithLabelValues(reason).Inc()
+	if s.logRejectedJoins {
+		slog.Info("Rejected join", "username", username, "reason", reason, "message", message)
+	}
+	s.sendError(conn, message)
+}
+
+// scheduleBotMatch starts a bot game for the player if nobody else joins
+// before the matchmaking timeout, optionally from a handicap position
+func (s *Server) scheduleBotMatch(matchPlayer *matchmaking.Player, difficulty bot.Difficulty) {
+	s.matchmaking.ScheduleBotMatch(matchPlayer, func(p *matchmaking.Player) {
+		s.startBotGame(p, nil, difficulty, false)
+	})
+}
+
+// startBotGame pairs the player with the bot at the given difficulty,
+// optionally from a handicap position. Practice games are tagged so stats
+// can leave them out.
+func (s *Server) startBotGame(p *matchmaking.Player, startingBoard [][]interface{}, difficulty bot.Difficulty, practice bool) {
+	botPlayer := convertToGamePlayer(&matchmaking.Player{
+		ID:        game.BotID,
+		Username:  s.botPlayer.Name(),
+		Conn:      nil,
+		Connected: true,
+		IsBot:     true,
+	})
+	botPlayer.BotDifficulty = string(difficulty)
+	player1 := convertToGamePlayer(p)
+	var tags []string
+	if practice {
+		tags = append(tags, game.TagPractice)
+	}
+	var g *game.Game
+	if startingBoard != nil {
+		handicapGame, err := s.gameManager.CreateGameWithBoard(player1, botPlayer, startingBoard, tags...)
+		if err != nil {
+			s.sendError(p.Conn, err.Error())
+			return
+		}
+		g = handicapGame
+	} else {
+		g = s.gameManager.CreateGameWithFirstMove(player1, botPlayer, p.Dimensions, p.FirstMove, tags...)
+	}
+	s.notifyPlayers(g)
+
+	// Bot makes first move if it's bot's turn
+	if g.CurrentPlayer == game.BotID {
+		s.scheduleBotMove(g)
+	}
+}
+
+const firstMoveChoiceMessage = "firstMove must be me, opponent or random"
+
+// parseFirstMoveChoice reads the optional firstMove of a join or createRoom
+// message, from the point of view of the player sending it, who sits in
+// player1's seat. "" leaves the choice to the server.
+func parseFirstMoveChoice(value string) (game.FirstMove, bool) {
+	switch value {
+	case "":
+		return "", true
+	case "me":
+		return game.FirstMovePlayer1, true
+	case "opponent":
+		return game.FirstMoveChallenger, true
+	case "random":
+		return game.FirstMoveCoinFlip, true
+	}
+	return "", false
+}
+
+// parseDimensions reads the optional dimensions of a join or createRoom
+// message, {rows, cols, winLength}. Missing fields keep their standard
+// value.
+func parseDimensions(raw interface{}) (game.Dimensions, error) {
+	dimensions := game.StandardDimensions
+	if raw == nil {
+		return dimensions, nil
+	}
+	fields, ok := raw.(map[string]interface{})
+	if !ok {
+		return dimensions, fmt.Errorf("expected an object with rows, cols and winLength")
+	}
+
+	for name, target := range map[string]*int{
+		"rows":      &dimensions.Rows,
+		"cols":      &dimensions.Cols,
+		"winLength": &dimensions.WinLength,
+	} {
+		value, present := fields[name]
+		if !present {
+			continue
+		}
+		number, ok := value.(float64)
+		if !ok || number != float64(int(number)) {
+			return dimensions, fmt.Errorf("%s must be a whole number", name)
+		}
+		*target = int(number)
+	}
+
+	return dimensions, dimensions.Validate()
+}
+
+// parseStartingBoard converts a client-supplied grid of 0 (empty), 1 (the
+// joining player) and 2 (the bot) into a board keyed by player ID
+func parseStartingBoard(raw interface{}, playerID string) ([][]interface{}, error) {
+	rows, ok := raw.([]interface{})
+	if !ok || len(rows) != game.ROWS {
+		return nil, fmt.Errorf("expected %d rows", game.ROWS)
+	}
+
+	board := game.CreateBoard()
+	for i, rawRow := range rows {
+		cells, ok := rawRow.([]interface{})
+		if !ok || len(cells) != game.COLS {
+			return nil, fmt.Errorf("expected %d columns in row %d", game.COLS, i)
+		}
+		for j, cell := range cells {
+			if cell == nil {
+				continue
+			}
+			value, ok := cell.(float64)
+			if !ok {
+				return nil, fmt.Errorf("invalid cell at row %d, column %d", i, j)
+			}
+			switch value {
+			case 0:
+			case 1:
+				board[i][j] = playerID
+			case 2:
+				board[i][j] = game.BotID
+			default:
+				return nil, fmt.Errorf("invalid cell at row %d, column %d", i, j)
+			}
+		}
+	}
+	return board, nil
+}
+
+func convertToGamePlayer(mp *matchmaking.Player) *game.Player {
+	return &game.Player{
+		ID:       mp.ID,
+		Username: mp.Username,
+		Conn:     mp.Conn,
+		IsBot:    mp.IsBot,
+	}
+}
+
+func (s *Server) handleRejoin(conn *websocket.Conn, username, gameID, reconnectToken string) {
+	result := s.gameManager.RejoinGame(conn, username, gameID, reconnectToken)
+	if result.Success {
+		// Confirm the rejoin to the reconnecting player before the board arrives
+		s.sendMessage(conn, map[string]interface{}{
+			"type":                 "rejoined",
+			"gameId":               result.Game.ID,
+			"currentPlayer":        usernameForID(result.Game, result.Game.CurrentPlayer),
+			"yourTurn":             usernameForID(result.Game, result.Game.CurrentPlayer) == username,
+			"turnDeadline":         turnDeadline(result.Game),
+			"turnSecondsRemaining": turnSecondsRemaining(result.Game),
+		})
+		s.notifyPlayers(result.Game)
+		// Notify opponent
+		if result.Game.Player1.Conn != nil {
+			s.sendMessage(result.Game.Player1.Conn, map[string]interface{}{
+				"type":     "playerReconnected",
+				"username": username,
+			})
+		}
+		if result.Game.Player2.Conn != nil {
+			s.sendMessage(result.Game.Player2.Conn, map[string]interface{}{
+				"type":     "playerReconnected",
+				"username": username,
+			})
+		}
+	} else {
+		s.sendErrorCode(conn, result.Code, result.Message)
+	}
+}
+
+func (s *Server) handleMakeMove(conn *websocket.Conn, gameID string, column int) {
+	s.handleMoveResult(conn, s.gameManager.MakeMove(gameID, column, conn))
+}
+
+// handleIntendMove echoes where an announced move would land so the player
+// can check it before sending confirmMove
+func (s *Server) handleIntendMove(conn *websocket.Conn, gameID string, column int) {
+	result := s.gameManager.IntendMove(gameID, column, conn)
+	if !result.Success {
+		s.handleMoveResult(conn, result)
+		return
+	}
+	pending := result.Game.PendingMove
+	s.sendMessage(conn, map[string]interface{}{
+		"type":   "moveIntended",
+		"gameId": gameID,
+		"column": pending.Column,
+		"row":    pending.Row,
+	})
+}
+
+// handleMoveResult reports a rejected move, or acknowledges an accepted one
+// to the mover, broadcasts it and either wraps up the game or lets the bot
+// reply
+func (s *Server) handleMoveResult(conn *websocket.Conn, result *game.GameMoveResult) {
+	if !result.Success {
+		s.sendErrorCode(conn, result.Code, result.Message)
+		return
+	}
+
+	g := result.Game
+	if result.Move != nil {
+		s.sendMessage(conn, map[string]interface{}{
+			"type":   "moveAck",
+			"gameId": g.ID,
+			"column": result.Move.Column,
+			"row":    result.Move.Row,
+		})
+	}
+	s.notifyMove(g)
+
+	// Check if game ended
+	if g.Status == "finished" {
+		s.gameManager.SaveGame(g)
+		if s.analyticsService != nil {
+			s.analyticsService.TrackGameEnd(g)
+		}
+	} else if g.CurrentPlayer == game.BotID && g.Player2.IsBot {
+		s.scheduleBotMove(g)
+	}
+}
+
+// scheduleBotMove lets the bot reply after the configured delay. The move is
+// dropped if the game ends first, e.g. by forfeit.
+func (s *Server) scheduleBotMove(g *game.Game) {
+	atomic.AddInt64(&s.pendingBotMoves, 1)
+	timer := time.AfterFunc(s.config.BotMoveDelay(), func() {
+		atomic.AddInt64(&s.pendingBotMoves, -1)
+		if g.Context().Err() != nil {
+			return
+		}
+		// Move on the game as it stands now, not as it was when scheduled
+		if current := s.gameManager.GetGame(g.ID); current != nil {
+			s.botPlayer.MakeMove(current, s.gameManager, s.notifyMove)
+		}
+	})
+	context.AfterFunc(g.Context(), func() {
+		if timer.Stop() {
+			atomic.AddInt64(&s.pendingBotMoves, -1)
+		}
+	})
+}
+
+// handleOfferDraw forwards a draw offer to the opponent
+func (s *Server) handleOfferDraw(conn *websocket.Conn, gameID string) {
+	result := s.gameManager.OfferDraw(gameID, conn)
+	if !result.Success {
+		s.sendErrorCode(conn, result.Code, result.Message)
+		return
+	}
+
+	g := result.Game
+	s.sendMessage(g.Opponent(playerForConn(g, conn)).Conn, map[string]interface{}{
+		"type":   "drawOffered",
+		"gameId": g.ID,
+		"from":   usernameForID(g, g.DrawOfferBy),
+	})
+}
+
+// handleResign ends the game in the opponent's favour and sends everyone the
+// final gameState
+func (s *Server) handleResign(conn *websocket.Conn, gameID string) {
+	result := s.gameManager.Resign(gameID, conn)
+	if !result.Success {
+		s.sendErrorCode(conn, result.Code, result.Message)
+		return
+	}
+	s.notifyPlayers(result.Game)
+}
+
+// handleChat relays a player's chat message to their opponent and the
+// game's spectators
+func (s *Server) handleChat(conn *websocket.Conn, gameID, text string) {
+	text = game.SanitizeChat(text)
+	if text == "" {
+		s.sendError(conn, "Chat message is empty")
+		return
+	}
+
+	username, recipients, err := s.gameManager.ChatRecipients(gameID, conn)
+	if err != nil {
+		s.sendError(conn, fmt.Sprintf("Cannot chat: %v", err))
+		return
+	}
+
+	msg := map[string]interface{}{
+		"type":     "chat",
+		"gameId":   gameID,
+		"username": username,
+		"text":     text,
+	}
+	for _, recipient := range recipients {
+		s.sendMessage(recipient, msg)
+	}
+}
+
+// handleRematch starts the rematch once both players have asked for it,
+// otherwise passes the request on to the opponent
+func (s *Server) handleRematch(conn *websocket.Conn, gameID string) {
+	result := s.gameManager.RequestRematch(gameID, conn)
+	if !result.Success {
+		s.sendErrorCode(conn, result.Code, result.Message)
+		return
+	}
+
+	if g := result.NewGame; g != nil {
+		s.notifyPlayers(g)
+		if g.CurrentPlayer == game.BotID {
+			s.scheduleBotMove(g)
+		}
+		return
+	}
+
+	g := result.Game
+	player := playerForConn(g, conn)
+	s.sendMessage(g.Opponent(player).Conn, map[string]interface{}{
+		"type":             "rematchRequested",
+		"gameId":           g.ID,
+		"from":             player.Username,
+		"expiresInSeconds": s.config.RematchTimeoutSeconds,
+	})
+}
+
+// handleJoinTournament registers the connection of a tournament player.
+// Their next match starts as soon as the opponent has joined too.
+func (s *Server) handleJoinTournament(conn *websocket.Conn, tournamentID, username string) {
+	if err := s.tournaments.Join(tournamentID, username, conn); err != nil {
+		s.sendError(conn, fmt.Sprintf("Cannot join tournament: %v", err))
+		return
+	}
+	s.sendMessage(conn, map[string]interface{}{
+		"type":         "tournamentJoined",
+		"tournamentId": tournamentID,
+	})
+}
+
+// handleRespondDraw ends the game on acceptance, otherwise tells the offering
+// player their offer was declined
+func (s *Server) handleRespondDraw(conn *websocket.Conn, gameID string, accept bool) {
+	result := s.gameManager.RespondDraw(gameID, conn, accept)
+	if !result.Success {
+		s.sendErrorCode(conn, result.Code, result.Message)
+		return
+	}
+
+	g := result.Game
+	if accept {
+		s.notifyPlayers(g)
+		return
+	}
+	s.sendMessage(g.Opponent(playerForConn(g, conn)).Conn, map[string]interface{}{
+		"type":   "drawDeclined",
+		"gameId": g.ID,
+	})
+}
+
+// handleOfferUndo passes a takeback request on to the opponent
+func (s *Server) handleOfferUndo(conn *websocket.Conn, gameID string) {
+	result := s.gameManager.OfferUndo(gameID, conn)
+	if !result.Success {
+		s.sendErrorCode(conn, result.Code, result.Message)
+		return
+	}
+
+	g := result.Game
+	s.sendMessage(g.Opponent(playerForConn(g, conn)).Conn, map[string]interface{}{
+		"type":   "undoOffered",
+		"gameId": g.ID,
+		"from":   usernameForID(g, g.UndoOfferBy),
+	})
+}
+
+// handleRespondUndo sends everyone the reverted gameState on acceptance,
+// otherwise tells the requesting player they were turned down
+func (s *Server) handleRespondUndo(conn *websocket.Conn, gameID string, accept bool) {
+	result := s.gameManager.RespondUndo(gameID, conn, accept)
+	if !result.Success {
+		s.sendErrorCode(conn, result.Code, result.Message)
+		return
+	}
+
+	g := result.Game
+	if accept {
+		s.notifyPlayers(g)
+		return
+	}
+	s.sendMessage(g.Opponent(playerForConn(g, conn)).Conn, map[string]interface{}{
+		"type":   "undoDeclined",
+		"gameId": g.ID,
+	})
+}
+
+// handleDebugBot turns on botThinking messages for the player's bot game.
+// Only available when the server runs with DEBUG_BOT=true.
+func (s *Server) handleDebugBot(conn *websocket.Conn, gameID string) {
+	if !s.config.DebugBot {
+		s.sendError(conn, "Bot debugging is disabled")
+		return
+	}
+	result := s.gameManager.EnableBotDebug(gameID, conn)
+	if !result.Success {
+		s.sendErrorCode(conn, result.Code, result.Message)
+	}
+}
+
+// TrackBotDecision implements bot.DecisionTracker. It sends the bot's
+// candidate evaluations to a debugging owner before the move is played, then
+// forwards the decision to analytics.
+func (s *Server) TrackBotDecision(g *game.Game, explanation *bot.MoveExplanation) {
+	if s.config.DebugBot && g.Player1.DebugBot {
+		s.sendMessage(g.Player1.Conn, map[string]interface{}{
+			"type":       "botThinking",
+			"gameId":     g.ID,
+			"column":     explanation.Column,
+			"reason":     explanation.Reason,
+			"candidates": explanation.Candidates,
+		})
+	}
+	s.analyticsService.TrackBotDecision(g, explanation)
+}
+
+// handleSpectate attaches conn to a game as a spectator; an empty gameID
+// picks a random live game
+func (s *Server) handleSpectate(conn *websocket.Conn, gameID string) {
+	g, ok := s.gameManager.AddSpectator(gameID, conn)
+	if !ok {
+		if gameID != "" {
+			s.sendError(conn, "Game not found, not in progress, or you are playing in it")
+			return
+		}
+		s.sendError(conn, "No live game to spectate")
+		return
+	}
+
+	s.sendMessage(conn, map[string]interface{}{
+		"type":   "spectating",
+		"gameId": g.ID,
+	})
+	s.sendMessage(conn, gameStateMessage(g))
+}
+
+// handleResync re-sends the authoritative gameState to a player or spectator
+// whose board checksum no longer matches
+func (s *Server) handleResync(conn *websocket.Conn, gameID string) {
+	g := s.gameManager.GetGame(gameID)
+	if g == nil {
+		s.sendErrorCode(conn, game.CodeGameNotFound, "Game not found")
+		return
+	}
+	if g.Player1.Conn != conn && g.Player2.Conn != conn {
+		for _, spectator := range g.Spectators {
+			if spectator == conn {
+				s.sendMessage(conn, gameStateMessage(g))
+				return
+			}
+		}
+		s.sendErrorCode(conn, game.CodeNotYourGame, "Not a player in this game")
+		return
+	}
+
+	s.sendMessage(conn, withSeat(gameStateMessage(g), playerForConn(g, conn)))
+}
+
+func (s *Server) notifyPlayers(g *game.Game) {
+	gameState := gameStateMessage(g)
+
+	if g.Player1.Conn != nil {
+		s.sendMessage(g.Player1.Conn, withSeat(gameState, g.Player1))
+	}
+	if g.Player2.Conn != nil {
+		s.sendMessage(g.Player2.Conn, withSeat(gameState, g.Player2))
+	}
+	for _, spectator := range g.Spectators {
+		s.sendMessage(spectator, gameState)
+	}
+}
+
+// notifyMove broadcasts the move just played. Spectators, and players when
+// PLAYER_DELTA_UPDATES is set, only get the changed cell as moveApplied; a
+// move that ends the game is sent as a full gameState to everyone.
+func (s *Server) notifyMove(g *game.Game) {
+	// A pop out shifts a whole column, which a single-cell delta can't express
+	if g.Status != "active" || len(g.Moves) == 0 || g.Moves[len(g.Moves)-1].Pop {
+		s.notifyPlayers(g)
+		return
+	}
+
+	delta := moveAppliedMessage(g)
+	gameState := gameStateMessage(g)
+	for _, player := range []*game.Player{g.Player1, g.Player2} {
+		if player.Conn == nil {
+			continue
+		}
+		if s.config.PlayerDeltaUpdates {
+			msg := make(map[string]interface{}, len(delta)+1)
+			for k, v := range delta {
+				msg[k] = v
+			}
+			msg["reconnectToken"] = player.ReconnectToken
+			s.sendMessage(player.Conn, msg)
+		} else {
+			s.sendMessage(player.Conn, withSeat(gameState, player))
+		}
+	}
+	for _, spectator := range g.Spectators {
+		s.sendMessage(spectator, delta)
+	}
+}
+
+// moveAppliedMessage describes the last move of g as a delta against the
+// previous gameState. The checksum lets clients detect drift and resync.
+func moveAppliedMessage(g *game.Game) map[string]interface{} {
+	move := g.Moves[len(g.Moves)-1]
+	return map[string]interface{}{
+		"type":           "moveApplied",
+		"gameId":         g.ID,
+		"moveNumber":     len(g.Moves),
+		"row":            move.Row,
+		"column":         move.Column,
+		"player":         usernameForID(g, move.Player),
+		"currentPlayer":  usernameForID(g, g.CurrentPlayer),
+		"validMoves":     game.GetValidMoves(g.Board),
+		"checksum":       game.BoardChecksum(g.Board, g.Player1.ID, g.Player2.ID),
+		"movesRemaining": game.MovesRemaining(g.Board),
+	}
+}
+
+// gameStateMessage builds the gameState payload sent to clients
+func gameStateMessage(g *game.Game) map[string]interface{} {
+	// Convert board to use usernames instead of IDs for frontend
+	boardForFrontend := make([][]interface{}, len(g.Board))
+	for i, row := range g.Board {
+		boardForFrontend[i] = make([]interface{}, len(row))
+		for j, cell := range row {
+			if cell == nil {
+				boardForFrontend[i][j] = nil
+			} else if cell == g.Player1.ID {
+				boardForFrontend[i][j] = g.Player1.Username
+			} else if cell == g.Player2.ID || cell == game.BotID {
+				boardForFrontend[i][j] = g.Player2.Username
+			} else {
+				boardForFrontend[i][j] = cell
+			}
+		}
+	}
+
+	// Convert currentPlayer to username for frontend
+	currentPlayerForFrontend := usernameForID(g, g.CurrentPlayer)
+
+	// Convert winner to username for frontend
+	winnerForFrontend := usernameForID(g, g.Winner)
+
+	return map[string]interface{}{
+		"type": "gameState",
+		"game": map[string]interface{}{
+			"id":             g.ID,
+			"board":          boardForFrontend,
+			"currentPlayer":  currentPlayerForFrontend,
+			"player1":        playerState(g, g.Player1),
+			"player2":        playerState(g, g.Player2),
+			"status":         g.Status,
+			"winner":         winnerForFrontend,
+			"resultType":     g.ResultType,
+			"drawOfferBy":    usernameForID(g, g.DrawOfferBy),
+			"undoOfferBy":    usernameForID(g, g.UndoOfferBy),
+			"checksum":       game.BoardChecksum(g.Board, g.Player1.ID, g.Player2.ID),
+			"movesRemaining": game.MovesRemaining(g.Board),
+			"winningCells":   winningCells(g),
+			"dimensions":     g.Dimensions,
+			"turnDeadline":   turnDeadline(g),
+		},
+	}
+}
+
+// turnDeadline is when the player to move runs out of time as RFC 3339, or
+// nil without a move clock
+func turnDeadline(g *game.Game) interface{} {
+	if g.TurnDeadline == nil {
+		return nil
+	}
+	return g.TurnDeadline.Format(time.RFC3339)
+}
+
+// turnSecondsRemaining is how long the player to move has left, rounded up,
+// or nil without a move clock
+func turnSecondsRemaining(g *game.Game) interface{} {
+	if g.TurnDeadline == nil {
+		return nil
+	}
+	return int(math.Ceil(time.Until(*g.TurnDeadline).Seconds()))
+}
+
+// winningCells lists the [row, col] of the winning discs, or an empty list
+// if the game has none to highlight
+func winningCells(g *game.Game) [][2]int {
+	if g.WinningCells == nil {
+		return [][2]int{}
+	}
+	return g.WinningCells
+}
+
+// playerState describes one player in the gameState payload. discsRemaining
+// is null unless a disc limit is in force.
+func playerState(g *game.Game, player *game.Player) map[string]interface{} {
+	state := map[string]interface{}{
+		"username":       player.Username,
+		"isBot":          player.IsBot,
+		"seat":           player.Seat,
+		"color":          player.Color,
+		"discsRemaining": nil,
+	}
+	if g.DiscsRemaining != nil {
+		state["discsRemaining"] = g.DiscsRemaining[player.ID]
+	}
+	return state
+}
+
+// withSeat tells a player which seat and color are theirs, along with their
+// current reconnect token
+func withSeat(gameState map[string]interface{}, player *game.Player) map[string]interface{} {
+	msg := make(map[string]interface{}, len(gameState)+3)
+	for k, v := range gameState {
+		msg[k] = v
+	}
+	msg["yourSeat"] = player.Seat
+	msg["yourColor"] = player.Color
+	msg["reconnectToken"] = player.ReconnectToken
+	return msg
+}
+
+// playerForConn returns the player in g using conn, assuming one of them does
+func playerForConn(g *game.Game, conn *websocket.Conn) *game.Player {
+	if g.Player2.Conn == conn {
+		return g.Player2
+	}
+	return g.Player1
+}
+
+// usernameForID maps a player ID (or game.BotID) to a display name
+func usernameForID(g *game.Game, playerID string) string {
+	if playerID == g.Player1.ID {
+		return g.Player1.Username
+	} else if playerID == g.Player2.ID || playerID == game.BotID {
+		return g.Player2.Username
+	}
+	return playerID
+}
+
+// errorResponse is the JSON body of every HTTP error response
+type errorResponse struct {
+	Error errorDetail `json:"error"`
+}
+
+type errorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Status  int    `json:"status"`
+}
+
+// writeError sends an HTTP error as {"error": {"code", "message", "status"}}.
+// code is a stable snake_case identifier clients can match on.
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Error: errorDetail{Code: code, Message: message, Status: status}})
+}
+
+// sendMessage writes msg to conn through the connection's serialized writer.
+// A nil conn, such as the bot's, is ignored.
+func (s *Server) sendMessage(conn *websocket.Conn, msg map[string]interface{}) {
+	if conn != nil {
+		socket.WriteJSON(conn, msg)
+	}
+}
+
+func (s *Server) sendError(conn *websocket.Conn, message string) {
+	s.sendMessage(conn, map[string]interface{}{
+		"type":    "error",
+		"message": message,
+	})
+}
+
+// sendErrorCode sends an error carrying a machine-readable code, such as
+// game.CodeColumnFull, or a plain error if code is ""
+func (s *Server) sendErrorCode(conn *websocket.Conn, code, message string) {
+	if code == "" {
+		s.sendError(conn, message)
+		return
+	}
 	s.sendMessage(conn, map[string]interface{}{
 		"type":    "error",
+		"code":    code,
 		"message": message,
 	})
 }
@@ -0,0 +1,581 @@
+package main
+
+import (
+	"connect-four/auth"
+	"connect-four/bot"
+	"connect-four/config"
+	"connect-four/game"
+	"connect-four/matchmaking"
+	"connect-four/moderation"
+	"connect-four/tournament"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newTestServer returns a Server whose games and leaderboard live in the
+// returned MemoryStore. It has no database, analytics or tournaments.
+func newTestServer(t *testing.T) (*Server, *game.MemoryStore) {
+	t.Helper()
+	cfg := config.Load()
+	cfg.AdminToken = "admin-token"
+	store := game.NewMemoryStore()
+	manager := game.NewManager(store, nil, game.Options{})
+	s := &Server{
+		config:         cfg,
+		gameManager:    manager,
+		matchmaking:    matchmaking.NewService(&gameManagerAdapter{manager: manager}, cfg.MatchmakingTimeout(), cfg.PrivateRoomTTL()),
+		maxConnections: int64(cfg.MaxConnections),
+		blocklist:      &moderation.Blocklist{},
+		signer:         auth.NewSigner([]byte("test-secret"), cfg.AuthTokenTTL()),
+		tournaments:    &tournament.Service{},
+	}
+	s.botPlayer = bot.NewPlayer(s)
+	return s, store
+}
+
+// serve sends a request for path through the server's router
+func serve(s *Server, method, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.router().ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	return rec
+}
+
+// dialServer starts s on a test HTTP server and opens a WebSocket to it
+func dialServer(t *testing.T, s *Server) *websocket.Conn {
+	t.Helper()
+	srv := httptest.NewServer(s.router())
+	t.Cleanup(srv.Close)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readType reads messages from conn until one of the given type arrives
+func readType(t *testing.T, conn *websocket.Conn, msgType string) map[string]interface{} {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var msg map[string]interface{}
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("waiting for %s: %v", msgType, err)
+		}
+		if msg["type"] == msgType {
+			return msg
+		}
+	}
+}
+
+// decodeBody decodes a JSON response body into v
+func decodeBody(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.NewDecoder(rec.Body).Decode(v); err != nil {
+		t.Fatalf("decoding %q: %v", rec.Body.String(), err)
+	}
+}
+
+// assertError checks that rec is an error response in the standard envelope
+// with the given status and code
+func assertError(t *testing.T, rec *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("status = %d, want %d (body %s)", rec.Code, status, rec.Body.String())
+	}
+	if contentType := rec.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", contentType)
+	}
+
+	var body map[string]map[string]interface{}
+	decodeBody(t, rec, &body)
+	detail, ok := body["error"]
+	if !ok || len(body) != 1 {
+		t.Fatalf("body = %v, want only an error object", body)
+	}
+	if len(detail) != 3 {
+		t.Errorf("error = %v, want exactly code, message and status", detail)
+	}
+	if detail["code"] != code {
+		t.Errorf("error code = %v, want %q", detail["code"], code)
+	}
+	if message, _ := detail["message"].(string); message == "" {
+		t.Errorf("error message = %v, want a message", detail["message"])
+	}
+	if detail["status"] != float64(status) {
+		t.Errorf("error status = %v, want %d", detail["status"], status)
+	}
+}
+
+func TestErrorResponsesShareTheEnvelope(t *testing.T) {
+	s, _ := newTestServer(t)
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		code   string
+	}{
+		{"leaderboard limit", "GET", "/api/leaderboard?limit=0", http.StatusBadRequest, "invalid_limit"},
+		{"leaderboard offset", "GET", "/api/leaderboard?offset=-1", http.StatusBadRequest, "invalid_offset"},
+		{"leaderboard minGames", "GET", "/api/leaderboard?minGames=x", http.StatusBadRequest, "invalid_min_games"},
+		{"unranked player", "GET", "/api/leaderboard/around/nobody", http.StatusNotFound, "player_not_ranked"},
+		{"unknown player stats", "GET", "/api/players/nobody/stats", http.StatusNotFound, "player_not_found"},
+		{"games limit", "GET", "/api/games?limit=101", http.StatusBadRequest, "invalid_limit"},
+		{"unknown game", "GET", "/api/games/missing", http.StatusNotFound, "game_not_found"},
+		{"unknown replay", "GET", "/api/games/missing/replay", http.StatusNotFound, "game_not_found"},
+		{"admin without a token", "GET", "/api/admin/status", http.StatusUnauthorized, "unauthorized"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertError(t, serve(s, tt.method, tt.path), tt.status, tt.code)
+		})
+	}
+}
+
+func TestReadinessWhileDraining(t *testing.T) {
+	s, _ := newTestServer(t)
+	if rec := serve(s, "GET", "/api/health/ready"); rec.Code != http.StatusOK {
+		t.Fatalf("ready status = %d, want 200", rec.Code)
+	}
+	s.draining = 1
+	assertError(t, serve(s, "GET", "/api/health/ready"), http.StatusServiceUnavailable, "draining")
+}
+
+func TestPanickingHandlerDropsOnlyItsConnection(t *testing.T) {
+	s, _ := newTestServer(t)
+	token, _, err := s.signer.Issue("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Verifying the join's token now dereferences a nil Signer
+	s.signer = nil
+	g := s.gameManager.CreateGame(&game.Player{ID: "p1", Username: "bob"}, &game.Player{ID: "p2", Username: "carol"})
+
+	conn := dialServer(t, s)
+	conn.WriteJSON(map[string]interface{}{"type": "spectate", "gameId": g.ID})
+	readType(t, conn, "spectating")
+
+	conn.WriteJSON(map[string]interface{}{"type": "join", "username": "alice", "token": token})
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				t.Fatal("connection still open after the handler panicked")
+			}
+			break
+		}
+	}
+
+	// Disconnect handling ran for the dropped connection
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt64(&s.connections) != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := atomic.LoadInt64(&s.connections); n != 0 {
+		t.Errorf("%d connections still counted", n)
+	}
+	if spectators := s.gameManager.GetGame(g.ID).Spectators; len(spectators) != 0 {
+		t.Errorf("game still has %d spectators", len(spectators))
+	}
+
+	// The server carries on for everyone else
+	other := dialServer(t, s)
+	other.WriteJSON(map[string]interface{}{"type": "spectate", "gameId": g.ID})
+	readType(t, other, "spectating")
+}
+
+// playFinishedGame plays a saved game in which alice (p1) beats bob (p2)
+// with four in column 0
+func playFinishedGame(t *testing.T, s *Server) *game.Game {
+	t.Helper()
+	conn1, conn2 := &websocket.Conn{}, &websocket.Conn{}
+	g := s.gameManager.CreateGame(
+		&game.Player{ID: "p1", Username: "alice", Conn: conn1},
+		&game.Player{ID: "p2", Username: "bob", Conn: conn2},
+	)
+	for i := 0; i < 3; i++ {
+		s.gameManager.MakeMove(g.ID, 0, conn1)
+		s.gameManager.MakeMove(g.ID, 1, conn2)
+	}
+	result := s.gameManager.MakeMove(g.ID, 0, conn1)
+	if result.Game.Status != "finished" {
+		t.Fatalf("game is %s, want finished", result.Game.Status)
+	}
+	s.gameManager.SaveGame(result.Game)
+	return result.Game
+}
+
+func TestGetGame(t *testing.T) {
+	s, _ := newTestServer(t)
+	finished := playFinishedGame(t, s)
+
+	rec := serve(s, "GET", "/api/games/"+finished.ID)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		Live bool `json:"live"`
+		Game struct {
+			ID      string `json:"id"`
+			Player1 string `json:"player1"`
+			Player2 string `json:"player2"`
+			Winner  string `json:"winner"`
+			Status  string `json:"status"`
+			Moves   []struct {
+				Player    string    `json:"player"`
+				Column    int       `json:"column"`
+				Row       int       `json:"row"`
+				Timestamp time.Time `json:"timestamp"`
+			} `json:"moves"`
+		} `json:"game"`
+	}
+	decodeBody(t, rec, &body)
+	if body.Live || body.Game.ID != finished.ID || body.Game.Player1 != "alice" || body.Game.Player2 != "bob" {
+		t.Errorf("game = %+v, want the saved game between alice and bob", body)
+	}
+	if body.Game.Winner != "alice" || body.Game.Status != "finished" {
+		t.Errorf("winner %q, status %q, want alice and finished", body.Game.Winner, body.Game.Status)
+	}
+	if len(body.Game.Moves) != 7 {
+		t.Fatalf("%d moves, want 7", len(body.Game.Moves))
+	}
+	for i, move := range body.Game.Moves {
+		wantPlayer, wantColumn, wantRow := "alice", 0, game.ROWS-1-i/2
+		if i%2 == 1 {
+			wantPlayer, wantColumn = "bob", 1
+		}
+		if move.Player != wantPlayer || move.Column != wantColumn || move.Row != wantRow || move.Timestamp.IsZero() {
+			t.Errorf("move %d = %+v, want %s in column %d, row %d", i, move, wantPlayer, wantColumn, wantRow)
+		}
+	}
+
+	assertError(t, serve(s, "GET", "/api/games/unknown"), http.StatusNotFound, "game_not_found")
+}
+
+func TestGetGameServesLiveGames(t *testing.T) {
+	s, _ := newTestServer(t)
+	g := s.gameManager.CreateGame(&game.Player{ID: "p1", Username: "alice"}, &game.Player{ID: "p2", Username: "bob"})
+
+	rec := serve(s, "GET", "/api/games/"+g.ID)
+	var body struct {
+		Live bool `json:"live"`
+		Game struct {
+			ID     string `json:"id"`
+			Status string `json:"status"`
+		} `json:"game"`
+	}
+	decodeBody(t, rec, &body)
+	if rec.Code != http.StatusOK || !body.Live || body.Game.ID != g.ID || body.Game.Status != "active" {
+		t.Errorf("status %d, body %+v, want the live game", rec.Code, body)
+	}
+}
+
+func TestLeaderboardPagination(t *testing.T) {
+	s, store := newTestServer(t)
+	// player0 has the most wins; playerN has 5-N wins over 5 games
+	for i := 0; i < 5; i++ {
+		username := fmt.Sprintf("player%d", i)
+		for played := 0; played < 5; played++ {
+			if played < 5-i {
+				store.RecordResult(username, 1, 0, 0)
+			} else {
+				store.RecordResult(username, 0, 1, 0)
+			}
+		}
+	}
+	store.RecordResult("newcomer", 0, 1, 0)
+
+	tests := []struct {
+		query         string
+		total, offset int
+		limit         int
+		usernames     []string
+	}{
+		{"", 6, 0, game.DefaultLeaderboardLimit, []string{"player0", "player1", "player2", "player3", "player4", "newcomer"}},
+		{"?limit=2", 6, 0, 2, []string{"player0", "player1"}},
+		{"?limit=2&offset=2", 6, 2, 2, []string{"player2", "player3"}},
+		{"?offset=10", 6, 10, game.DefaultLeaderboardLimit, []string{}},
+		{"?minGames=2", 5, 0, game.DefaultLeaderboardLimit, []string{"player0", "player1", "player2", "player3", "player4"}},
+	}
+	for _, tt := range tests {
+		t.Run("query="+tt.query, func(t *testing.T) {
+			rec := serve(s, "GET", "/api/leaderboard"+tt.query)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+			}
+			var page game.LeaderboardPage
+			decodeBody(t, rec, &page)
+			if page.Total != tt.total || page.Offset != tt.offset || page.Limit != tt.limit {
+				t.Errorf("total %d, offset %d, limit %d, want %d, %d, %d", page.Total, page.Offset, page.Limit, tt.total, tt.offset, tt.limit)
+			}
+			if len(page.Entries) != len(tt.usernames) {
+				t.Fatalf("entries = %+v, want %v", page.Entries, tt.usernames)
+			}
+			for i, entry := range page.Entries {
+				if entry.Username != tt.usernames[i] || entry.Rank != tt.offset+i+1 {
+					t.Errorf("entry %d = %s ranked %d, want %s ranked %d", i, entry.Username, entry.Rank, tt.usernames[i], tt.offset+i+1)
+				}
+			}
+		})
+	}
+
+	assertError(t, serve(s, "GET", fmt.Sprintf("/api/leaderboard?limit=%d", game.MaxLeaderboardLimit+1)), http.StatusBadRequest, "invalid_limit")
+}
+
+func TestPlayerStats(t *testing.T) {
+	s, _ := newTestServer(t)
+	finished := playFinishedGame(t, s)
+
+	for _, tt := range []struct {
+		username, opponent, result string
+		wins, losses               int
+	}{
+		{"alice", "bob", game.PlayerResultWin, 1, 0},
+		{"bob", "alice", game.PlayerResultLoss, 0, 1},
+	} {
+		rec := serve(s, "GET", "/api/players/"+tt.username+"/stats")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body %s", tt.username, rec.Code, rec.Body.String())
+		}
+		var stats game.PlayerStats
+		decodeBody(t, rec, &stats)
+		if stats.Username != tt.username || stats.Wins != tt.wins || stats.Losses != tt.losses || stats.TotalGames != 1 {
+			t.Errorf("%s: stats = %+v, want %d-%d from 1 game", tt.username, stats.LeaderboardEntry, tt.wins, tt.losses)
+		}
+		if stats.WinRate != float64(tt.wins) || stats.Rating == 0 {
+			t.Errorf("%s: win rate %v, rating %d", tt.username, stats.WinRate, stats.Rating)
+		}
+		if len(stats.RecentGames) != 1 {
+			t.Fatalf("%s: recent games = %+v, want 1", tt.username, stats.RecentGames)
+		}
+		if recent := stats.RecentGames[0]; recent.ID != finished.ID || recent.Opponent != tt.opponent || recent.Result != tt.result || recent.EndedAt == nil {
+			t.Errorf("%s: recent game = %+v, want a %s against %s", tt.username, recent, tt.result, tt.opponent)
+		}
+	}
+
+	var stats game.PlayerStats
+	decodeBody(t, serve(s, "GET", "/api/players/alice/stats?limit=0"), &stats)
+	if len(stats.RecentGames) != 0 {
+		t.Errorf("limit=0 returned %d recent games", len(stats.RecentGames))
+	}
+	assertError(t, serve(s, "GET", "/api/players/nobody/stats"), http.StatusNotFound, "player_not_found")
+	assertError(t, serve(s, "GET", "/api/players/alice/stats?limit=51"), http.StatusBadRequest, "invalid_limit")
+}
+
+func TestJoinRequiresAValidToken(t *testing.T) {
+	s, _ := newTestServer(t)
+	expired, _, _ := auth.NewSigner([]byte("test-secret"), -time.Second).Issue("alice")
+	forged, _, _ := auth.NewSigner([]byte("guessed-secret"), time.Hour).Issue("alice")
+
+	for name, token := range map[string]interface{}{"missing": nil, "not a string": 42, "expired": expired, "forged": forged} {
+		conn := dialServer(t, s)
+		msg := map[string]interface{}{"type": "join", "username": "alice"}
+		if token != nil {
+			msg["token"] = token
+		}
+		if err := conn.WriteJSON(msg); err != nil {
+			t.Fatalf("%s: write: %v", name, err)
+		}
+		if message, _ := readType(t, conn, "error")["message"].(string); message == "" {
+			t.Errorf("%s: error without a message", name)
+		}
+	}
+	if n := s.matchmaking.WaitingCount(); n != 0 {
+		t.Fatalf("%d players queued with bad tokens", n)
+	}
+
+	token, _, _ := s.signer.Issue("alice")
+	conn := dialServer(t, s)
+	if err := conn.WriteJSON(map[string]interface{}{"type": "join", "username": "mallory", "token": token}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	readType(t, conn, "waiting")
+	conn.WriteJSON(map[string]interface{}{"type": "cancelJoin"})
+	readType(t, conn, "joinCancelled")
+}
+
+func TestMessageBurstIsRateLimited(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.config.MoveRateLimit = 3
+	conn := dialServer(t, s)
+
+	for i := 0; i < 5; i++ {
+		if err := conn.WriteJSON(map[string]interface{}{"type": "makeMove", "gameId": "none", "column": 0}); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for i := 0; i < 5; i++ {
+		var reply map[string]interface{}
+		if err := conn.ReadJSON(&reply); err != nil {
+			t.Fatalf("reply %d: %v", i+1, err)
+		}
+		if limited := reply["type"] == "rateLimited"; limited != (i >= 3) {
+			t.Errorf("move %d got %v", i+1, reply)
+		} else if limited && (reply["limit"] != "moves" || reply["messageType"] != "makeMove") {
+			t.Errorf("move %d: rateLimited = %v, want the moves limit", i+1, reply)
+		}
+	}
+
+	// Chat has its own bucket
+	conn.WriteJSON(map[string]interface{}{"type": "chat", "gameId": "none", "message": "hi"})
+	var reply map[string]interface{}
+	if err := conn.ReadJSON(&reply); err != nil {
+		t.Fatalf("chat reply: %v", err)
+	}
+	if reply["type"] == "rateLimited" {
+		t.Errorf("chat throttled by the moves limit: %v", reply)
+	}
+}
+
+// joinAs sends a join for username over conn with a freshly issued token
+func joinAs(t *testing.T, s *Server, conn *websocket.Conn, username string) {
+	t.Helper()
+	token, _, err := s.signer.Issue(username)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if err := conn.WriteJSON(map[string]interface{}{"type": "join", "username": username, "token": token}); err != nil {
+		t.Fatalf("write join: %v", err)
+	}
+}
+
+// matchPlayers queues alice then bob and returns their connections and the
+// ID of the game they are matched into, with alice to move
+func matchPlayers(t *testing.T, s *Server) (alice, bob *websocket.Conn, gameID string) {
+	t.Helper()
+	alice, bob = dialServer(t, s), dialServer(t, s)
+	joinAs(t, s, alice, "alice")
+	readType(t, alice, "waiting")
+	joinAs(t, s, bob, "bob")
+
+	state, _ := readType(t, alice, "gameState")["game"].(map[string]interface{})
+	readType(t, bob, "gameState")
+	gameID, _ = state["id"].(string)
+	if gameID == "" || state["currentPlayer"] != "alice" {
+		t.Fatalf("game state = %v, want a game with alice to move", state)
+	}
+	return alice, bob, gameID
+}
+
+func TestMalformedMovesAreRejected(t *testing.T) {
+	s, _ := newTestServer(t)
+	alice, _, gameID := matchPlayers(t, s)
+
+	frames := map[string]string{
+		"not JSON":              `{"type": "makeMove", "column": `,
+		"JSON array":            `["makeMove", 0]`,
+		"type not a string":     `{"type": 7, "gameId": "` + gameID + `", "column": 0}`,
+		"missing column":        `{"type": "makeMove", "gameId": "` + gameID + `"}`,
+		"null column":           `{"type": "makeMove", "gameId": "` + gameID + `", "column": null}`,
+		"column as a string":    `{"type": "makeMove", "gameId": "` + gameID + `", "column": "0"}`,
+		"fractional column":     `{"type": "makeMove", "gameId": "` + gameID + `", "column": 0.5}`,
+		"huge column":           `{"type": "makeMove", "gameId": "` + gameID + `", "column": 1e300}`,
+		"missing gameId":        `{"type": "makeMove", "column": 0}`,
+		"empty gameId":          `{"type": "makeMove", "gameId": "", "column": 0}`,
+		"numeric gameId":        `{"type": "makeMove", "gameId": 12, "column": 0}`,
+		"resign without gameId": `{"type": "resign"}`,
+	}
+	for name, frame := range frames {
+		if err := alice.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
+			t.Fatalf("%s: write: %v", name, err)
+		}
+		if reply := readType(t, alice, "error"); reply["code"] != codeInvalidMessage {
+			t.Errorf("%s: got %v, want an %s error", name, reply, codeInvalidMessage)
+		}
+	}
+	if g := s.gameManager.GetGame(gameID); g == nil || len(g.Moves) != 0 || g.Status != "active" {
+		t.Fatalf("game after malformed frames = %+v, want it active with no moves", g)
+	}
+
+	// The connection is still usable for a well-formed move
+	alice.WriteJSON(map[string]interface{}{"type": "makeMove", "gameId": gameID, "column": 3})
+	if ack := readType(t, alice, "moveAck"); ack["column"] != float64(3) {
+		t.Errorf("moveAck = %v, want column 3", ack)
+	}
+}
+
+func TestJoinWhilePlayingIsRejected(t *testing.T) {
+	s, _ := newTestServer(t)
+	alice, _, gameID := matchPlayers(t, s)
+
+	// Neither the same connection nor another tab gets a second game
+	otherTab := dialServer(t, s)
+	for name, conn := range map[string]*websocket.Conn{"same connection": alice, "other tab": otherTab} {
+		joinAs(t, s, conn, "alice")
+		message, _ := readType(t, conn, "error")["message"].(string)
+		if !strings.Contains(message, "already playing") {
+			t.Errorf("%s: join error = %q, want already playing", name, message)
+		}
+	}
+	if n := s.matchmaking.WaitingCount(); n != 0 {
+		t.Errorf("%d players queued while alice is playing", n)
+	}
+	if playing := s.gameManager.PlayingGameID("alice"); playing != gameID {
+		t.Errorf("alice is playing %q, want %q", playing, gameID)
+	}
+
+	// Once the game is over she can queue again
+	alice.WriteJSON(map[string]interface{}{"type": "resign", "gameId": gameID})
+	readType(t, alice, "gameState")
+	joinAs(t, s, otherTab, "alice")
+	readType(t, otherTab, "waiting")
+	otherTab.WriteJSON(map[string]interface{}{"type": "cancelJoin"})
+	readType(t, otherTab, "joinCancelled")
+}
+
+func TestIdempotencyKeyIsScopedToTheUser(t *testing.T) {
+	s, _ := newTestServer(t)
+	join := func(conn *websocket.Conn, username string) {
+		t.Helper()
+		token, _, _ := s.signer.Issue(username)
+		if err := conn.WriteJSON(map[string]interface{}{"type": "join", "username": username, "token": token, "idempotencyKey": "shared-key"}); err != nil {
+			t.Fatalf("write join: %v", err)
+		}
+	}
+
+	alice := dialServer(t, s)
+	join(alice, "alice")
+	readType(t, alice, "waiting")
+
+	// mallory's join with the same key is a join of her own, not a replay of
+	// alice's, so the two are matched against each other
+	mallory := dialServer(t, s)
+	join(mallory, "mallory")
+	state, _ := readType(t, mallory, "gameState")["game"].(map[string]interface{})
+	player1, _ := state["player1"].(map[string]interface{})
+	player2, _ := state["player2"].(map[string]interface{})
+	if player1["username"] != "alice" || player2["username"] != "mallory" {
+		t.Fatalf("game players = %v and %v, want alice against mallory", player1["username"], player2["username"])
+	}
+	readType(t, alice, "gameState")
+}
+
+func TestMalformedGameIDIsNotFound(t *testing.T) {
+	// games.id is a UUID column in Postgres; nothing listens on this address,
+	// so any query would fail with a 500
+	db, err := sql.Open("postgres", "host=127.0.0.1 port=1 sslmode=disable connect_timeout=1")
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	defer db.Close()
+	s, _ := newTestServer(t)
+	s.gameManager = game.NewManager(game.NewPostgresStore(db), nil, game.Options{})
+
+	for _, path := range []string{"/api/games/not-a-uuid", "/api/games/not-a-uuid/replay", "/api/game/not-a-uuid/analysis"} {
+		t.Run(path, func(t *testing.T) {
+			assertError(t, serve(s, "GET", path), http.StatusNotFound, "game_not_found")
+		})
+	}
+}
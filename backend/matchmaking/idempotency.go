@@ -0,0 +1,79 @@
+package matchmaking
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// JoinIdempotencyWindow is how long a join idempotency key is remembered
+const JoinIdempotencyWindow = 30 * time.Second
+
+type joinRecord struct {
+	player    *Player
+	expiresAt time.Time
+}
+
+// joinKey scopes a client's idempotency key to the username it joined as, so
+// another user sending the same key can't pick up their join. Usernames
+// compare case-insensitively.
+func joinKey(username, key string) string {
+	return strings.ToLower(username) + "\x00" + key
+}
+
+// RememberJoin records the player created for a join carrying key, so a
+// retried join by the same user with the same key can be answered with the
+// original result
+func (s *Service) RememberJoin(key string, player *Player) {
+	if key == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for k, record := range s.joinKeys {
+		if now.After(record.expiresAt) {
+			delete(s.joinKeys, k)
+		}
+	}
+	s.joinKeys[joinKey(player.Username, key)] = &joinRecord{player: player, expiresAt: now.Add(JoinIdempotencyWindow)}
+}
+
+// RecallJoin returns the player created for an earlier join by username
+// with key, or nil if the key is unknown for that user or older than
+// JoinIdempotencyWindow
+func (s *Service) RecallJoin(username, key string) *Player {
+	if key == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scoped := joinKey(username, key)
+	record, exists := s.joinKeys[scoped]
+	if !exists {
+		return nil
+	}
+	if time.Now().After(record.expiresAt) {
+		delete(s.joinKeys, scoped)
+		return nil
+	}
+	return record.player
+}
+
+// ReattachWaiting moves a player who is still queued for a match onto conn,
+// reporting whether they were still queued
+func (s *Service) ReattachWaiting(playerID string, conn *websocket.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	player := s.findPlayerByID(playerID)
+	if player == nil {
+		return false
+	}
+	player.Conn = conn
+	player.Connected = true
+	return true
+}
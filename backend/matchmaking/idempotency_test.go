@@ -0,0 +1,86 @@
+package matchmaking
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestDuplicateJoinRecallsTheFirst(t *testing.T) {
+	s := NewService(nil, time.Minute, time.Minute)
+	first := &Player{ID: "1", Username: "alice", Conn: &websocket.Conn{}, Connected: true}
+
+	if result := s.AddPlayer(first); result.Matched || result.AlreadyQueued {
+		t.Fatalf("first join = %+v, want queued", result)
+	}
+	s.RememberJoin("key-1", first)
+
+	// The retry arrives on the client's new connection with the same key
+	retryConn := &websocket.Conn{}
+	recalled := s.RecallJoin("alice", "key-1")
+	if recalled != first {
+		t.Fatalf("RecallJoin = %+v, want the first join's player", recalled)
+	}
+	if !s.ReattachWaiting(recalled.ID, retryConn) {
+		t.Fatal("player no longer waiting")
+	}
+	if first.Conn != retryConn {
+		t.Error("queued player not moved onto the retry's connection")
+	}
+	if n := s.WaitingCount(); n != 1 {
+		t.Errorf("%d players waiting after a duplicate join, want 1", n)
+	}
+
+	// Without the key the duplicate is turned away rather than queued twice
+	duplicate := &Player{ID: "2", Username: "alice", Conn: &websocket.Conn{}, Connected: true}
+	if result := s.AddPlayer(duplicate); !result.AlreadyQueued {
+		t.Errorf("duplicate join = %+v, want AlreadyQueued", result)
+	}
+	if n := s.WaitingCount(); n != 1 {
+		t.Errorf("%d players waiting, want 1", n)
+	}
+}
+
+func TestRecallJoinForgetsOldAndEmptyKeys(t *testing.T) {
+	s := NewService(nil, time.Minute, time.Minute)
+	player := &Player{ID: "1", Username: "alice"}
+
+	s.RememberJoin("", player)
+	if len(s.joinKeys) != 0 || s.RecallJoin("alice", "") != nil {
+		t.Error("an empty key was remembered")
+	}
+	if s.RecallJoin("alice", "unknown") != nil {
+		t.Error("RecallJoin found an unknown key")
+	}
+
+	s.RememberJoin("key-1", player)
+	s.joinKeys[joinKey("alice", "key-1")].expiresAt = time.Now().Add(-time.Second)
+	if s.RecallJoin("alice", "key-1") != nil {
+		t.Errorf("RecallJoin returned a key older than %v", JoinIdempotencyWindow)
+	}
+	if _, exists := s.joinKeys[joinKey("alice", "key-1")]; exists {
+		t.Error("expired key not dropped")
+	}
+}
+
+func TestJoinKeysAreScopedToTheUser(t *testing.T) {
+	s := NewService(nil, time.Minute, time.Minute)
+	alice := &Player{ID: "1", Username: "alice", Conn: &websocket.Conn{}, Connected: true}
+	s.AddPlayer(alice)
+	s.RememberJoin("shared-key", alice)
+
+	// Another user who sends, or guesses, the same key gets nothing back
+	if recalled := s.RecallJoin("mallory", "shared-key"); recalled != nil {
+		t.Fatalf("mallory recalled %+v with alice's key", recalled)
+	}
+	mallory := &Player{ID: "2", Username: "mallory", Conn: &websocket.Conn{}, Connected: true}
+	s.RememberJoin("shared-key", mallory)
+
+	if recalled := s.RecallJoin("Alice", "shared-key"); recalled != alice {
+		t.Errorf("alice recalled %+v, want her own join", recalled)
+	}
+	if recalled := s.RecallJoin("mallory", "shared-key"); recalled != mallory {
+		t.Errorf("mallory recalled %+v, want her own join", recalled)
+	}
+}
@@ -1,6 +1,8 @@
 package matchmaking
 
 import (
+	"connect-four/game"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -12,45 +14,135 @@ type Player struct {
 	Conn      *websocket.Conn
 	Connected bool
 	IsBot     bool
+	// ReconnectToken is supplied by the client so a brief socket drop while
+	// queued doesn't cost the player their place (see ResumePlayer)
+	ReconnectToken string
+	// Dimensions is the board the player asked for; players are only
+	// matched with others who asked for the same one
+	Dimensions game.Dimensions
+	// Elo is the player's leaderboard rating when they joined
+	Elo int
+	// FirstMove is who the player asked to move first, as player1: in a bot
+	// game or as a room host. Public matches use the server's policy.
+	FirstMove game.FirstMove
+	// QueuedAt is when the player started waiting, set by AddPlayer
+	QueuedAt time.Time
 }
 
+// Players are matched only if their ratings are within RatingWindow of each
+// other. The window widens by RatingWindowGrowth for every second the waiting
+// player has been queued, until the bot timeout takes over.
+const (
+	RatingWindow       = 100
+	RatingWindowGrowth = 20
+)
+
+// ratingWindow is the widest rating gap a player who has waited this long
+// accepts
+func ratingWindow(waited time.Duration) int {
+	return RatingWindow + int(waited/time.Second)*RatingWindowGrowth
+}
+
+// QueueReconnectGrace is how long a disconnected player with a reconnect
+// token keeps their place in the queue
+const QueueReconnectGrace = 5 * time.Second
+
 type MatchResult struct {
 	Matched bool
 	Player1 *Player
 	Player2 *Player
+	// AlreadyQueued is set when AddPlayer turned the player away because
+	// their username is already waiting on a live connection
+	AlreadyQueued bool
 }
 
+// Service is called from every connection's read loop and from its own
+// timers, so mu guards the queue, timers, rooms and join keys. Unexported
+// methods other than locked expect mu to be held.
 type Service struct {
+	mu             sync.Mutex
 	gameManager    GameManager
 	timeout        time.Duration
 	waitingPlayers []*Player
 	botTimers      map[string]*time.Timer
+	graceTimers    map[string]*time.Timer
+	roomTTL        time.Duration
+	rooms          map[string]*Room
+	joinKeys       map[string]*joinRecord
+	queueChange    func([]QueueEntry)
+	// departures counts players taken out of the queue, so locked can tell
+	// when places have changed
+	departures    int
+	recentMatches []time.Time
 }
 
 type GameManager interface {
 	CreateGame(player1, player2 interface{}) interface{} // Returns *game.Game, accepts *game.Player
 }
 
-func NewService(gameManager GameManager, timeout time.Duration) *Service {
+func NewService(gameManager GameManager, timeout, roomTTL time.Duration) *Service {
 	return &Service{
 		gameManager:    gameManager,
 		timeout:        timeout,
 		waitingPlayers: []*Player{},
 		botTimers:      make(map[string]*time.Timer),
+		graceTimers:    make(map[string]*time.Timer),
+		roomTTL:        roomTTL,
+		rooms:          make(map[string]*Room),
+		joinKeys:       make(map[string]*joinRecord),
 	}
 }
 
 func (s *Service) AddPlayer(player *Player) *MatchResult {
+	var result *MatchResult
+	s.locked(func() { result = s.addPlayer(player) })
+	return result
+}
+
+func (s *Service) addPlayer(player *Player) *MatchResult {
 	// Remove any existing bot timer for this player
 	if timer, exists := s.botTimers[player.ID]; exists {
 		timer.Stop()
 		delete(s.botTimers, player.ID)
 	}
 
-	// Check if there's a waiting player
-	if len(s.waitingPlayers) > 0 {
-		opponent := s.waitingPlayers[0]
-		s.waitingPlayers = s.waitingPlayers[1:]
+	// A username holds one place in the queue. A connected entry keeps it;
+	// one waiting out its reconnect grace gives way to the new join.
+	for _, waiting := range s.waitingPlayers {
+		if waiting.Username != player.Username {
+			continue
+		}
+		if waiting.Connected {
+			return &MatchResult{Matched: false, AlreadyQueued: true}
+		}
+		s.dropWaitingPlayer(waiting.ID)
+	}
+
+	// Match with the closest-rated connected player on the same board whose
+	// rating window covers the player, never one on the player's own
+	// connection. Ties go to whoever has waited longest.
+	now := time.Now()
+	match, matchGap := -1, 0
+	for i, opponent := range s.waitingPlayers {
+		if !opponent.Connected || opponent.Conn == player.Conn || opponent.Dimensions != player.Dimensions {
+			continue
+		}
+		gap := opponent.Elo - player.Elo
+		if gap < 0 {
+			gap = -gap
+		}
+		if gap > ratingWindow(now.Sub(opponent.QueuedAt)) {
+			continue
+		}
+		if match < 0 || gap < matchGap {
+			match, matchGap = i, gap
+		}
+	}
+	if match >= 0 {
+		opponent := s.waitingPlayers[match]
+		s.waitingPlayers = append(s.waitingPlayers[:match:match], s.waitingPlayers[match+1:]...)
+		s.departures++
+		s.recordMatch()
 		return &MatchResult{
 			Matched: true,
 			Player1: opponent,
@@ -59,36 +151,148 @@ func (s *Service) AddPlayer(player *Player) *MatchResult {
 	}
 
 	// Add to waiting queue
+	player.QueuedAt = now
 	s.waitingPlayers = append(s.waitingPlayers, player)
 	return &MatchResult{Matched: false}
 }
 
 func (s *Service) RemovePlayer(conn *websocket.Conn) {
-	// Remove from waiting queue
-	newWaiting := []*Player{}
-	for _, p := range s.waitingPlayers {
-		if p.Conn != conn {
-			newWaiting = append(newWaiting, p)
+	s.locked(func() {
+		// Remove from waiting queue, holding the place of players who can resume
+		newWaiting := []*Player{}
+		for _, p := range s.waitingPlayers {
+			if p.Conn != conn {
+				newWaiting = append(newWaiting, p)
+				continue
+			}
+			if p.ReconnectToken != "" {
+				p.Conn = nil
+				p.Connected = false
+				s.scheduleGraceExpiry(p.ID)
+				newWaiting = append(newWaiting, p)
+			}
+		}
+		if len(newWaiting) < len(s.waitingPlayers) {
+			s.departures++
+		}
+		s.waitingPlayers = newWaiting
+
+		// Clear bot timer if exists
+		for playerID, timer := range s.botTimers {
+			player := s.findPlayerByID(playerID)
+			if player == nil || player.Conn == conn {
+				timer.Stop()
+				delete(s.botTimers, playerID)
+			}
+		}
+
+		s.closeRoomsHostedBy(conn)
+	})
+}
+
+// CancelMatch takes the player on conn out of the queue and stops their
+// pending bot match, reporting whether they were waiting. Unlike RemovePlayer
+// it doesn't hold their place for a reconnect. Cancelling again, or after the
+// player has been matched, does nothing and returns false.
+func (s *Service) CancelMatch(conn *websocket.Conn) bool {
+	cancelled := false
+	s.locked(func() {
+		for _, p := range s.waitingPlayers {
+			if p.Conn != conn {
+				continue
+			}
+			cancelled = true
+			s.dropWaitingPlayer(p.ID)
 		}
+	})
+	return cancelled
+}
+
+// dropWaitingPlayer takes a player out of the queue and stops their timers
+func (s *Service) dropWaitingPlayer(id string) {
+	s.removeWaitingPlayer(id)
+	if timer, exists := s.botTimers[id]; exists {
+		timer.Stop()
+		delete(s.botTimers, id)
+	}
+	if timer, exists := s.graceTimers[id]; exists {
+		timer.Stop()
+		delete(s.graceTimers, id)
+	}
+}
+
+// ResumePlayer reattaches conn to a queued player who dropped within the
+// grace window, keeping their queue position. The token alone isn't enough:
+// the player must also be username, as authenticated for the new join. It
+// returns nil if no such player is waiting to resume.
+func (s *Service) ResumePlayer(token, username string, conn *websocket.Conn) *Player {
+	if token == "" {
+		return nil
 	}
-	s.waitingPlayers = newWaiting
 
-	// Clear bot timer if exists
-	for playerID, timer := range s.botTimers {
-		player := s.findPlayerByID(playerID)
-		if player == nil || player.Conn == conn {
-			timer.Stop()
-			delete(s.botTimers, playerID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.waitingPlayers {
+		if p.ReconnectToken == token && p.Username == username && !p.Connected {
+			if timer, exists := s.graceTimers[p.ID]; exists {
+				timer.Stop()
+				delete(s.graceTimers, p.ID)
+			}
+			p.Conn = conn
+			p.Connected = true
+			return p
 		}
 	}
+	return nil
 }
 
+func (s *Service) scheduleGraceExpiry(playerID string) {
+	s.graceTimers[playerID] = time.AfterFunc(QueueReconnectGrace, func() {
+		s.locked(func() {
+			delete(s.graceTimers, playerID)
+			if player := s.findPlayerByID(playerID); player != nil && !player.Connected {
+				s.removeWaitingPlayer(playerID)
+				if timer, exists := s.botTimers[playerID]; exists {
+					timer.Stop()
+					delete(s.botTimers, playerID)
+				}
+			}
+		})
+	})
+}
+
+// ScheduleBotMatch pairs the player with the bot after the timeout unless a
+// human opponent turns up first. Scheduling again replaces any pending timer.
+// callback runs without the lock held, once the player has left the queue.
 func (s *Service) ScheduleBotMatch(player *Player, callback func(*Player)) {
-	timer := time.AfterFunc(s.timeout, func() {
-		// Check if player is still waiting
-		if s.isPlayerWaiting(player.ID) {
-			s.removeWaitingPlayer(player.ID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if timer, exists := s.botTimers[player.ID]; exists {
+		timer.Stop()
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(s.timeout, func() {
+		matched := false
+		s.locked(func() {
+			// A timer replaced or stopped after it fired has nothing left to do
+			if s.botTimers[player.ID] != timer {
+				return
+			}
 			delete(s.botTimers, player.ID)
+
+			// A player inside the reconnect grace window gets rescheduled on
+			// resume; one who is no longer waiting has been matched or left
+			if !player.Connected || !s.isPlayerWaiting(player.ID) {
+				return
+			}
+			s.removeWaitingPlayer(player.ID)
+			s.recordMatch()
+			matched = true
+		})
+
+		if matched {
 			callback(player)
 		}
 	})
@@ -116,6 +320,9 @@ func (s *Service) removeWaitingPlayer(id string) {
 			newWaiting = append(newWaiting, p)
 		}
 	}
+	if len(newWaiting) < len(s.waitingPlayers) {
+		s.departures++
+	}
 	s.waitingPlayers = newWaiting
 }
 
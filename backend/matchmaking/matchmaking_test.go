@@ -0,0 +1,185 @@
+package matchmaking
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// queued returns a connected player ready to join the queue
+func queued(id, username string, elo int) *Player {
+	return &Player{ID: id, Username: username, Conn: &websocket.Conn{}, Connected: true, Elo: elo}
+}
+
+func TestSameUsernameIsNeverMatchedWithItself(t *testing.T) {
+	s := NewService(nil, time.Minute, time.Minute)
+	if result := s.AddPlayer(queued("1", "alice", 1000)); result.Matched {
+		t.Fatalf("first tab = %+v, want queued", result)
+	}
+
+	// A second tab gets told it is already queued
+	result := s.AddPlayer(queued("2", "alice", 1000))
+	if result.Matched || !result.AlreadyQueued {
+		t.Fatalf("second tab = %+v, want AlreadyQueued", result)
+	}
+	if n := s.WaitingCount(); n != 1 {
+		t.Fatalf("%d players waiting, want 1", n)
+	}
+
+	// Someone else still gets matched with the first tab
+	result = s.AddPlayer(queued("3", "bob", 1000))
+	if !result.Matched || result.Player1.ID != "1" || result.Player2.ID != "3" {
+		t.Errorf("bob's join = %+v, want matched with alice's first tab", result)
+	}
+}
+
+func TestConcurrentJoinsUnderOneUsername(t *testing.T) {
+	s := NewService(nil, time.Minute, time.Minute)
+
+	const tabs = 8
+	results := make([]*MatchResult, tabs)
+	var wg sync.WaitGroup
+	for i := 0; i < tabs; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = s.AddPlayer(queued(string(rune('a'+i)), "alice", 1000))
+		}(i)
+	}
+	wg.Wait()
+
+	queuedTabs := 0
+	for i, result := range results {
+		if result.Matched {
+			t.Errorf("tab %d matched %s against %s", i, result.Player1.Username, result.Player2.Username)
+		}
+		if !result.AlreadyQueued {
+			queuedTabs++
+		}
+	}
+	if queuedTabs != 1 || s.WaitingCount() != 1 {
+		t.Errorf("%d tabs queued and %d waiting, want 1 of each", queuedTabs, s.WaitingCount())
+	}
+}
+
+func TestDisconnectedEntryGivesWayToTheNewJoin(t *testing.T) {
+	s := NewService(nil, time.Minute, time.Minute)
+	first := queued("1", "alice", 1000)
+	first.ReconnectToken = "token"
+	s.AddPlayer(first)
+	s.RemovePlayer(first.Conn)
+
+	// The dropped tab is waiting out its grace period; a fresh join replaces
+	// it instead of being turned away or matched against it
+	result := s.AddPlayer(queued("2", "alice", 1000))
+	if result.Matched || result.AlreadyQueued {
+		t.Fatalf("rejoin = %+v, want queued", result)
+	}
+	if n := s.WaitingCount(); n != 1 {
+		t.Errorf("%d players waiting, want 1", n)
+	}
+	if s.ResumePlayer("token", "alice", &websocket.Conn{}) != nil {
+		t.Error("the replaced entry can still be resumed")
+	}
+}
+
+func TestPlayersSharingAConnectionAreNotMatched(t *testing.T) {
+	s := NewService(nil, time.Minute, time.Minute)
+	first := queued("1", "alice", 1000)
+	s.AddPlayer(first)
+
+	second := queued("2", "bob", 1000)
+	second.Conn = first.Conn
+	if result := s.AddPlayer(second); result.Matched {
+		t.Fatalf("join on the same connection = %+v, want queued", result)
+	}
+}
+
+func TestRatingWindowWidensWithTheWait(t *testing.T) {
+	tests := []struct {
+		waited time.Duration
+		want   int
+	}{
+		{0, RatingWindow},
+		{999 * time.Millisecond, RatingWindow},
+		{time.Second, RatingWindow + RatingWindowGrowth},
+		{10 * time.Second, RatingWindow + 10*RatingWindowGrowth},
+	}
+	for _, tt := range tests {
+		if got := ratingWindow(tt.waited); got != tt.want {
+			t.Errorf("ratingWindow(%v) = %d, want %d", tt.waited, got, tt.want)
+		}
+	}
+}
+
+func TestDistantRatingsMatchOnceTheWindowWidens(t *testing.T) {
+	s := NewService(nil, time.Minute, time.Minute)
+	waiting := queued("1", "alice", 1000)
+	s.AddPlayer(waiting)
+
+	// Two seconds' growth past the starting window
+	gap := RatingWindow + 2*RatingWindowGrowth
+	if result := s.AddPlayer(queued("2", "bob", 1000+gap)); result.Matched {
+		t.Fatalf("fresh join %d points away = %+v, want queued", gap, result)
+	}
+
+	// After two seconds alice's window reaches bob's rating
+	s.mu.Lock()
+	waiting.QueuedAt = time.Now().Add(-2 * time.Second)
+	s.mu.Unlock()
+	result := s.AddPlayer(queued("3", "carol", 1000-gap))
+	if !result.Matched || result.Player1.ID != "1" {
+		t.Errorf("join after the window widened = %+v, want matched with alice", result)
+	}
+}
+
+func TestClosestRatingWins(t *testing.T) {
+	s := NewService(nil, time.Minute, time.Minute)
+	s.AddPlayer(queued("1", "alice", 1090))
+	s.AddPlayer(queued("2", "bob", 1400))
+	s.AddPlayer(queued("3", "carol", 920))
+
+	result := s.AddPlayer(queued("4", "dave", 1000))
+	if !result.Matched || result.Player1.ID != "3" {
+		t.Fatalf("dave's join = %+v, want matched with carol", result)
+	}
+	if n := s.WaitingCount(); n != 2 {
+		t.Errorf("%d players waiting, want alice and bob", n)
+	}
+}
+
+func TestResumedPlayerKeepsTheirPlace(t *testing.T) {
+	s := NewService(nil, time.Minute, time.Minute)
+	alice := queued("1", "alice", 1000)
+	alice.ReconnectToken = "alice-token"
+	s.AddPlayer(alice)
+	// Far enough apart in rating that they aren't matched with each other
+	bob := queued("2", "bob", 2000)
+	s.AddPlayer(bob)
+
+	s.RemovePlayer(alice.Conn)
+	if position, _ := s.QueuePosition(alice.ID); position != 1 {
+		t.Fatalf("alice is at %d while reconnecting, want 1", position)
+	}
+
+	// Someone else with alice's token doesn't get her place
+	if s.ResumePlayer("alice-token", "mallory", &websocket.Conn{}) != nil {
+		t.Fatal("resumed alice's place under another username")
+	}
+
+	conn := &websocket.Conn{}
+	if resumed := s.ResumePlayer("alice-token", "alice", conn); resumed != alice || resumed.Conn != conn || !resumed.Connected {
+		t.Fatalf("ResumePlayer = %+v, want alice on the new connection", resumed)
+	}
+	if position, _ := s.QueuePosition(alice.ID); position != 1 {
+		t.Errorf("alice is at %d after resuming, want 1", position)
+	}
+	if position, _ := s.QueuePosition(bob.ID); position != 2 {
+		t.Errorf("bob is at %d, want 2 behind alice", position)
+	}
+	if s.ResumePlayer("alice-token", "alice", &websocket.Conn{}) != nil {
+		t.Error("resumed a player who is already connected")
+	}
+}
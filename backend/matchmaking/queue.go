@@ -0,0 +1,126 @@
+package matchmaking
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// recentMatchCount is how many of the latest matches the wait estimate is
+// based on
+const recentMatchCount = 10
+
+// QueueEntry is a waiting player's place in the queue
+type QueueEntry struct {
+	PlayerID      string
+	Conn          *websocket.Conn
+	Position      int
+	EstimatedWait time.Duration
+}
+
+// OnQueueChange registers fn to hear the place of every connected waiting
+// player whenever someone leaves the queue, so players behind them can be
+// told they moved up. fn runs without the lock held. Set it before anyone
+// joins.
+func (s *Service) OnQueueChange(fn func([]QueueEntry)) {
+	s.queueChange = fn
+}
+
+// QueuePosition returns the player's 1-based place in the queue and how long
+// they can expect to wait, or 0 if they are not queued
+func (s *Service) QueuePosition(playerID string) (int, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, p := range s.waitingPlayers {
+		if p.ID == playerID {
+			return i + 1, s.estimatedWait(i + 1)
+		}
+	}
+	return 0, 0
+}
+
+// WaitingCount returns the number of players in the queue, including any
+// waiting out their reconnect grace
+func (s *Service) WaitingCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.waitingPlayers)
+}
+
+// Status is a snapshot of the matchmaking state for operators
+type Status struct {
+	// WaitingPlayers includes players waiting out their reconnect grace
+	WaitingPlayers int `json:"waitingPlayers"`
+	// BotMatchTimers counts queued players the bot will take on if nobody
+	// else joins first
+	BotMatchTimers int `json:"botMatchTimers"`
+	PrivateRooms   int `json:"privateRooms"`
+}
+
+// Status returns a snapshot of the queue, bot timers and private rooms
+func (s *Service) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Status{
+		WaitingPlayers: len(s.waitingPlayers),
+		BotMatchTimers: len(s.botTimers),
+		PrivateRooms:   len(s.rooms),
+	}
+}
+
+// locked runs fn with mu held and, if fn took anyone out of the queue, tells
+// the OnQueueChange callback once the lock is released
+func (s *Service) locked(fn func()) {
+	s.mu.Lock()
+	departures := s.departures
+	fn()
+	var entries []QueueEntry
+	if s.departures != departures && s.queueChange != nil {
+		entries = s.queueEntries()
+	}
+	s.mu.Unlock()
+
+	if entries != nil {
+		s.queueChange(entries)
+	}
+}
+
+// queueEntries lists the connected waiting players with their places
+func (s *Service) queueEntries() []QueueEntry {
+	entries := []QueueEntry{}
+	for i, p := range s.waitingPlayers {
+		if p.Connected {
+			entries = append(entries, QueueEntry{
+				PlayerID:      p.ID,
+				Conn:          p.Conn,
+				Position:      i + 1,
+				EstimatedWait: s.estimatedWait(i + 1),
+			})
+		}
+	}
+	return entries
+}
+
+// recordMatch notes a waiting player being matched, for estimatedWait
+func (s *Service) recordMatch() {
+	s.recentMatches = append(s.recentMatches, time.Now())
+	if len(s.recentMatches) > recentMatchCount {
+		s.recentMatches = s.recentMatches[len(s.recentMatches)-recentMatchCount:]
+	}
+}
+
+// estimatedWait guesses how long the player at position will wait from how
+// often waiting players have been matched lately. Nobody waits longer than
+// the bot timeout, which is also the guess until there is a match rate.
+func (s *Service) estimatedWait(position int) time.Duration {
+	if len(s.recentMatches) < 2 {
+		return s.timeout
+	}
+	first, last := s.recentMatches[0], s.recentMatches[len(s.recentMatches)-1]
+	interval := last.Sub(first) / time.Duration(len(s.recentMatches)-1)
+	if wait := interval * time.Duration(position); wait < s.timeout {
+		return wait
+	}
+	return s.timeout
+}
@@ -0,0 +1,93 @@
+package matchmaking
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// DefaultRoomTTL is how long a private room waits for its code to be used
+const DefaultRoomTTL = 5 * time.Minute
+
+// roomCodeAlphabet leaves out characters that are easy to misread (0/O, 1/I)
+const roomCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+const roomCodeLength = 6
+
+// Room is a private game waiting for a second player to join by code.
+// Private rooms never fall back to a bot match.
+type Room struct {
+	Code      string
+	Host      *Player
+	ExpiresAt time.Time
+	timer     *time.Timer
+}
+
+// CreateRoom opens a private room hosted by player. If nobody joins before
+// the room TTL, the room is closed and onExpire is called with it.
+func (s *Service) CreateRoom(host *Player, onExpire func(*Room)) *Room {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	code := s.newRoomCode()
+	room := &Room{
+		Code:      code,
+		Host:      host,
+		ExpiresAt: time.Now().Add(s.roomTTL),
+	}
+	room.timer = time.AfterFunc(s.roomTTL, func() {
+		s.mu.Lock()
+		if s.rooms[code] != room {
+			s.mu.Unlock()
+			return
+		}
+		delete(s.rooms, code)
+		s.mu.Unlock()
+		onExpire(room)
+	})
+	s.rooms[code] = room
+	return room
+}
+
+// JoinRoom pairs player with the host of the room with the given code and
+// closes the room. It reports no match if the code is unknown or expired.
+func (s *Service) JoinRoom(code string, player *Player) *MatchResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room, exists := s.rooms[code]
+	if !exists || room.Host.Conn == player.Conn {
+		return &MatchResult{Matched: false}
+	}
+
+	room.timer.Stop()
+	delete(s.rooms, code)
+	return &MatchResult{
+		Matched: true,
+		Player1: room.Host,
+		Player2: player,
+	}
+}
+
+// closeRoomsHostedBy drops any room whose host is on conn
+func (s *Service) closeRoomsHostedBy(conn *websocket.Conn) {
+	for code, room := range s.rooms {
+		if room.Host.Conn == conn {
+			room.timer.Stop()
+			delete(s.rooms, code)
+		}
+	}
+}
+
+func (s *Service) newRoomCode() string {
+	for {
+		code := make([]byte, roomCodeLength)
+		for i := range code {
+			code[i] = roomCodeAlphabet[rand.Intn(len(roomCodeAlphabet))]
+		}
+		if _, taken := s.rooms[string(code)]; !taken {
+			return string(code)
+		}
+	}
+}
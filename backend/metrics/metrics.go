@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	GameSaveFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "connect_four_game_save_failures_total",
+		Help: "Finished games that could not be saved to the database after all retries.",
+	})
+
+	WebSocketConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "connect_four_websocket_connections",
+		Help: "Currently open WebSocket connections.",
+	})
+
+	RejectedConnections = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "connect_four_websocket_connections_rejected_total",
+		Help: "WebSocket connections refused because MAX_WS_CONNECTIONS was reached.",
+	})
+
+	JoinRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "connect_four_join_rejections_total",
+		Help: "Join attempts rejected before entering matchmaking, by reason.",
+	}, []string{"reason"})
+
+	HandlerPanics = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "connect_four_ws_handler_panics_total",
+		Help: "WebSocket message handlers that panicked; each one dropped its connection.",
+	})
+
+	RateLimitedMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "connect_four_rate_limited_messages_total",
+		Help: "WebSocket messages refused for exceeding a per-connection rate limit, by limit.",
+	}, []string{"limit"})
+
+	GamesStarted = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "connect_four_games_started_total",
+		Help: "Games created, against humans or the bot.",
+	})
+
+	GamesFinished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "connect_four_games_finished_total",
+		Help: "Games that ended, by result type (win, draw, forfeit, abandoned, ...).",
+	}, []string{"result"})
+
+	GamesForfeited = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "connect_four_games_forfeited_total",
+		Help: "Games forfeited by a player who did not come back within the reconnect window.",
+	})
+
+	GameDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "connect_four_game_duration_seconds",
+		Help:    "Time from a game's start to its end.",
+		Buckets: []float64{15, 30, 60, 120, 180, 300, 600, 900, 1800},
+	})
+
+	MovesPlayed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "connect_four_moves_total",
+		Help: "Moves played, by who played them (human or bot).",
+	}, []string{"player"})
+
+	BotMoveSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "connect_four_bot_move_seconds",
+		Help:    "Time the bot spent choosing a move, excluding BOT_MOVE_DELAY_MS.",
+		Buckets: prometheus.ExponentialBuckets(0.0005, 2, 12),
+	})
+
+	AnalyticsEventsDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "connect_four_analytics_events_dropped_total",
+		Help: "Analytics events lost, by reason (buffer_full or kafka_error when publishing, store_error when saving consumed events).",
+	}, []string{"reason"})
+
+	ReconnectOutcomes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "connect_four_reconnect_outcomes_total",
+		Help: "Closed reconnect windows of disconnected players, by outcome.",
+	}, []string{"outcome"})
+
+	ReconnectSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "connect_four_reconnect_seconds",
+		Help:    "Time from a player's disconnect to their successful rejoin.",
+		Buckets: []float64{1, 2, 5, 10, 15, 20, 25, 30, 45, 60},
+	})
+
+	reconnectsClosed, reconnectsSucceeded atomic.Int64
+
+	ReconnectSuccessRatio = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "connect_four_reconnect_success_ratio",
+		Help: "Share of closed reconnect windows that ended in a successful rejoin since startup.",
+	}, func() float64 {
+		closed := reconnectsClosed.Load()
+		if closed == 0 {
+			return 0
+		}
+		return float64(reconnectsSucceeded.Load()) / float64(closed)
+	})
+)
+
+// RegisterGauges exports the number of active games, waiting players and
+// open reconnect windows, read from the given funcs on every scrape. Call it
+// once at startup.
+func RegisterGauges(activeGames, waitingPlayers, reconnectWindows func() int) {
+	gauge := func(name, help string, value func() int) {
+		promauto.NewGaugeFunc(prometheus.GaugeOpts{Name: name, Help: help}, func() float64 {
+			return float64(value())
+		})
+	}
+	gauge("connect_four_active_games", "Games being played.", activeGames)
+	gauge("connect_four_waiting_players", "Players waiting in the matchmaking queue.", waitingPlayers)
+	gauge("connect_four_reconnect_windows_open", "Games waiting for a disconnected player to rejoin.", reconnectWindows)
+}
+
+// RecordGameEnd counts a game that ended with resultType after lasting
+// duration
+func RecordGameEnd(resultType string, duration time.Duration) {
+	GamesFinished.WithLabelValues(resultType).Inc()
+	GameDuration.Observe(duration.Seconds())
+}
+
+// RecordMove counts a move played by the bot or a human
+func RecordMove(byBot bool) {
+	player := "human"
+	if byBot {
+		player = "bot"
+	}
+	MovesPlayed.WithLabelValues(player).Inc()
+}
+
+// RecordReconnect counts a closed reconnect window. elapsed is only observed
+// for successful rejoins, so the histogram shows how long rejoining takes.
+func RecordReconnect(outcome string, succeeded bool, elapsed time.Duration) {
+	ReconnectOutcomes.WithLabelValues(outcome).Inc()
+	reconnectsClosed.Add(1)
+	if succeeded {
+		reconnectsSucceeded.Add(1)
+		ReconnectSeconds.Observe(elapsed.Seconds())
+	}
+}
+
+// Handler serves the registered metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,130 @@
+package moderation
+
+import (
+	"database/sql"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Blocklist holds banned username patterns. A pattern is matched
+// case-insensitively against the whole username and may use '*' as a
+// wildcard, so "*admin*" bans any name containing "admin".
+//
+// Patterns come from the BANNED_USERNAMES env var (comma-separated) and the
+// banned_usernames table; only the latter can be changed at runtime.
+type Blocklist struct {
+	mu          sync.RWMutex
+	db          *sql.DB
+	envPatterns []string
+	dbPatterns  map[string]bool
+}
+
+func NewBlocklist(db *sql.DB, envPatterns string) (*Blocklist, error) {
+	b := &Blocklist{
+		db:         db,
+		dbPatterns: make(map[string]bool),
+	}
+	for _, pattern := range strings.Split(envPatterns, ",") {
+		if pattern = normalize(pattern); pattern != "" {
+			b.envPatterns = append(b.envPatterns, pattern)
+		}
+	}
+
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS banned_usernames (
+			pattern VARCHAR(255) PRIMARY KEY,
+			created_at TIMESTAMP DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT pattern FROM banned_usernames`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var pattern string
+		if err := rows.Scan(&pattern); err != nil {
+			return nil, err
+		}
+		b.dbPatterns[pattern] = true
+	}
+
+	return b, rows.Err()
+}
+
+// IsBanned reports whether username matches any banned pattern
+func (b *Blocklist) IsBanned(username string) bool {
+	name := normalize(username)
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, pattern := range b.envPatterns {
+		if matches(pattern, name) {
+			return true
+		}
+	}
+	for pattern := range b.dbPatterns {
+		if matches(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Add bans a pattern and persists it
+func (b *Blocklist) Add(pattern string) error {
+	pattern = normalize(pattern)
+	if _, err := path.Match(pattern, ""); err != nil {
+		return err
+	}
+
+	_, err := b.db.Exec(`INSERT INTO banned_usernames (pattern) VALUES ($1) ON CONFLICT (pattern) DO NOTHING`, pattern)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.dbPatterns[pattern] = true
+	b.mu.Unlock()
+	return nil
+}
+
+// Remove lifts a ban added at runtime. Env patterns can't be removed.
+func (b *Blocklist) Remove(pattern string) error {
+	pattern = normalize(pattern)
+	if _, err := b.db.Exec(`DELETE FROM banned_usernames WHERE pattern = $1`, pattern); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	delete(b.dbPatterns, pattern)
+	b.mu.Unlock()
+	return nil
+}
+
+// Patterns lists every active pattern, env patterns first
+func (b *Blocklist) Patterns() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	patterns := append([]string{}, b.envPatterns...)
+	for pattern := range b.dbPatterns {
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+func normalize(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+func matches(pattern, name string) bool {
+	matched, err := path.Match(pattern, name)
+	return err == nil && matched
+}
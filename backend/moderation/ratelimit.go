@@ -0,0 +1,42 @@
+package moderation
+
+import "time"
+
+// RateLimiter is a token bucket allowing perSecond messages a second on
+// average, in bursts of up to perSecond. A limit of 0 or less allows
+// everything. It is not safe for concurrent use; each connection's read loop
+// keeps its own.
+type RateLimiter struct {
+	perSecond float64
+	tokens    float64
+	last      time.Time
+}
+
+func NewRateLimiter(perSecond int) *RateLimiter {
+	return &RateLimiter{
+		perSecond: float64(perSecond),
+		tokens:    float64(perSecond),
+		last:      time.Now(),
+	}
+}
+
+// Allow takes a token for one message, reporting false if the bucket is
+// empty
+func (l *RateLimiter) Allow() bool {
+	if l.perSecond <= 0 {
+		return true
+	}
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.perSecond
+	if l.tokens > l.perSecond {
+		l.tokens = l.perSecond
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
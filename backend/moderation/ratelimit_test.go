@@ -0,0 +1,47 @@
+package moderation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterBurst(t *testing.T) {
+	l := NewRateLimiter(5)
+	for i := 0; i < 5; i++ {
+		if !l.Allow() {
+			t.Fatalf("message %d of the burst throttled", i+1)
+		}
+	}
+	if l.Allow() {
+		t.Fatal("message past the burst allowed")
+	}
+
+	// Tokens come back at perSecond, never more than a full burst
+	l.last = l.last.Add(-500 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if !l.Allow() {
+			t.Fatalf("message %d after 500ms throttled", i+1)
+		}
+	}
+	if l.Allow() {
+		t.Error("more than 2 messages allowed after 500ms")
+	}
+
+	l.last = l.last.Add(-time.Hour)
+	allowed := 0
+	for l.Allow() {
+		allowed++
+	}
+	if allowed != 5 {
+		t.Errorf("%d messages allowed after an idle hour, want a burst of 5", allowed)
+	}
+}
+
+func TestRateLimiterWithoutALimit(t *testing.T) {
+	l := NewRateLimiter(0)
+	for i := 0; i < 1000; i++ {
+		if !l.Allow() {
+			t.Fatalf("message %d throttled with no limit", i+1)
+		}
+	}
+}
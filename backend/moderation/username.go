@@ -0,0 +1,34 @@
+package moderation
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Length limits for usernames, in characters
+const (
+	MinUsernameLength = 3
+	MaxUsernameLength = 20
+)
+
+// ValidateUsername trims surrounding whitespace from a requested username and
+// checks that what is left is MinUsernameLength to MaxUsernameLength ASCII
+// letters, digits, '_' or '-'. It returns the trimmed name, even when it is
+// invalid, and an error describing the first problem found.
+func ValidateUsername(username string) (string, error) {
+	username = strings.TrimSpace(username)
+	if length := utf8.RuneCountInString(username); length < MinUsernameLength || length > MaxUsernameLength {
+		return username, fmt.Errorf("must be %d to %d characters long", MinUsernameLength, MaxUsernameLength)
+	}
+	for _, r := range username {
+		if !isUsernameRune(r) {
+			return username, fmt.Errorf("%q is not allowed; use letters, digits, '_' or '-'", r)
+		}
+	}
+	return username, nil
+}
+
+func isUsernameRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-'
+}
@@ -0,0 +1,58 @@
+// Package socket serializes writes to WebSocket connections. gorilla allows
+// only one writer per connection at a time, yet a player's messages come from
+// their own read loop, the matchmaker, bot and clock timers and the game
+// manager alike, so every message is written through WriteJSON.
+package socket
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// writeTimeout bounds a single write, so a client that stops reading can't
+// hold up everyone else sending to it
+const writeTimeout = 10 * time.Second
+
+// ErrClosed is returned by WriteJSON for a connection that isn't open
+var ErrClosed = errors.New("socket: connection is not open")
+
+var (
+	mu sync.Mutex
+	// writers holds the write lock of every open connection
+	writers = make(map[*websocket.Conn]*sync.Mutex)
+)
+
+// Open registers conn for writing. Call it once the connection is upgraded,
+// before it is handed to anything that might send to it.
+func Open(conn *websocket.Conn) {
+	mu.Lock()
+	defer mu.Unlock()
+	writers[conn] = &sync.Mutex{}
+}
+
+// Close unregisters conn; messages sent to it afterwards are dropped with
+// ErrClosed. Call it when the read loop ends.
+func Close(conn *websocket.Conn) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(writers, conn)
+}
+
+// WriteJSON sends v to conn as JSON, waiting for any other write to conn to
+// finish first
+func WriteJSON(conn *websocket.Conn, v interface{}) error {
+	mu.Lock()
+	writer, open := writers[conn]
+	mu.Unlock()
+	if !open {
+		return ErrClosed
+	}
+
+	writer.Lock()
+	defer writer.Unlock()
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return conn.WriteJSON(v)
+}
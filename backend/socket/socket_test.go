@@ -0,0 +1,89 @@
+package socket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// dial returns the server and client ends of a new WebSocket connection
+func dial(t *testing.T) (server, client *websocket.Conn) {
+	t.Helper()
+	conns := make(chan *websocket.Conn, 1)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		conns <- conn
+	}))
+	t.Cleanup(srv.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	server = <-conns
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return server, client
+}
+
+func TestWriteJSONSerializesConcurrentWriters(t *testing.T) {
+	server, client := dial(t)
+	Open(server)
+	defer Close(server)
+
+	const writers, perWriter = 8, 25
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < perWriter; j++ {
+				if err := WriteJSON(server, map[string]int{"writer": i, "seq": j}); err != nil {
+					t.Errorf("WriteJSON: %v", err)
+					return
+				}
+			}
+		}(i)
+	}
+
+	// Each writer's messages arrive whole and in the order it sent them
+	next := make(map[int]int)
+	for n := 0; n < writers*perWriter; n++ {
+		var msg map[string]int
+		if err := client.ReadJSON(&msg); err != nil {
+			t.Fatalf("ReadJSON after %d messages: %v", n, err)
+		}
+		if msg["seq"] != next[msg["writer"]] {
+			t.Fatalf("writer %d: got seq %d, want %d", msg["writer"], msg["seq"], next[msg["writer"]])
+		}
+		next[msg["writer"]]++
+	}
+	wg.Wait()
+}
+
+func TestWriteJSONAfterClose(t *testing.T) {
+	server, _ := dial(t)
+	if err := WriteJSON(server, "hello"); err != ErrClosed {
+		t.Fatalf("before Open: got %v, want ErrClosed", err)
+	}
+
+	Open(server)
+	if err := WriteJSON(server, "hello"); err != nil {
+		t.Fatalf("while open: %v", err)
+	}
+	Close(server)
+	if err := WriteJSON(server, "hello"); err != ErrClosed {
+		t.Fatalf("after Close: got %v, want ErrClosed", err)
+	}
+}
@@ -0,0 +1,348 @@
+package tournament
+
+import (
+	"connect-four/game"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// Tournament is a single-elimination bracket. Rounds[0] is the first round;
+// each later round has half as many matches, and the winner of match i
+// advances to match i/2 of the next round.
+type Tournament struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Status    string     `json:"status"` // "active" or "finished"
+	Players   []string   `json:"players"`
+	Rounds    [][]*Match `json:"rounds"`
+	Champion  string     `json:"champion,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// Match pairs two players by username. An empty slot in a later round is
+// still to be decided; a first-round match with one empty slot is a bye.
+// A drawn or abandoned game is replayed until someone wins.
+type Match struct {
+	Player1 string   `json:"player1"`
+	Player2 string   `json:"player2"`
+	Winner  string   `json:"winner,omitempty"`
+	GameID  string   `json:"gameId,omitempty"`
+	GameIDs []string `json:"gameIds"`
+}
+
+// MatchResult is a decided match as listed in a tournament's results
+type MatchResult struct {
+	Round   int      `json:"round"`
+	Player1 string   `json:"player1"`
+	Player2 string   `json:"player2"`
+	Winner  string   `json:"winner"`
+	Bye     bool     `json:"bye"`
+	GameIDs []string `json:"gameIds"`
+}
+
+// Results lists every decided match, round by round (rounds numbered from 1)
+func (t *Tournament) Results() []MatchResult {
+	results := []MatchResult{}
+	for roundIndex, round := range t.Rounds {
+		for _, match := range round {
+			if match.Winner == "" {
+				continue
+			}
+			results = append(results, MatchResult{
+				Round:   roundIndex + 1,
+				Player1: match.Player1,
+				Player2: match.Player2,
+				Winner:  match.Winner,
+				Bye:     match.Player1 == "" || match.Player2 == "",
+				GameIDs: match.GameIDs,
+			})
+		}
+	}
+	return results
+}
+
+// GameCreator starts tournament games; satisfied by *game.Manager
+type GameCreator interface {
+	CreateTournamentGame(player1, player2 *game.Player) *game.Game
+}
+
+// Service runs tournaments and keeps their brackets in the tournaments table
+type Service struct {
+	mu          sync.Mutex
+	db          *sql.DB
+	games       GameCreator
+	notify      func(*game.Game)
+	tournaments map[string]*Tournament
+	// conns holds the connection each registered player joined with, keyed
+	// by tournament ID then username
+	conns map[string]map[string]*websocket.Conn
+}
+
+// NewService loads stored tournaments. notify is called with each game the
+// service starts so both players receive its first gameState.
+func NewService(db *sql.DB, games GameCreator, notify func(*game.Game)) (*Service, error) {
+	s := &Service{
+		db:          db,
+		games:       games,
+		notify:      notify,
+		tournaments: make(map[string]*Tournament),
+		conns:       make(map[string]map[string]*websocket.Conn),
+	}
+
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS tournaments (
+			id UUID PRIMARY KEY,
+			state JSONB NOT NULL,
+			updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT state FROM tournaments`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var state []byte
+		if err := rows.Scan(&state); err != nil {
+			return nil, err
+		}
+		var t Tournament
+		if err := json.Unmarshal(state, &t); err != nil {
+			return nil, err
+		}
+		// Games in progress didn't survive the restart; they are replayed
+		// once both players join again
+		for _, round := range t.Rounds {
+			for _, match := range round {
+				if match.Winner == "" {
+					match.GameID = ""
+				}
+			}
+		}
+		s.tournaments[t.ID] = &t
+	}
+
+	return s, rows.Err()
+}
+
+// Create registers the players and generates the bracket. Players are seeded
+// in the order given; the field is padded to a power of two with byes.
+func (s *Service) Create(name string, players []string) (*Tournament, error) {
+	if len(players) < 2 {
+		return nil, fmt.Errorf("a tournament needs at least 2 players")
+	}
+	seen := make(map[string]bool, len(players))
+	for _, player := range players {
+		if player == "" || seen[player] {
+			return nil, fmt.Errorf("player names must be unique and non-empty")
+		}
+		seen[player] = true
+	}
+
+	size := 2
+	for size < len(players) {
+		size *= 2
+	}
+
+	t := &Tournament{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Status:    "active",
+		Players:   players,
+		CreatedAt: time.Now(),
+	}
+	for matches := size / 2; matches >= 1; matches /= 2 {
+		round := make([]*Match, matches)
+		for i := range round {
+			round[i] = &Match{GameIDs: []string{}}
+		}
+		t.Rounds = append(t.Rounds, round)
+	}
+	for i, player := range players {
+		match := t.Rounds[0][i%(size/2)]
+		if i < size/2 {
+			match.Player1 = player
+		} else {
+			match.Player2 = player
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Byes advance immediately
+	for i, match := range t.Rounds[0] {
+		if match.Player2 == "" {
+			s.advance(t, 0, i, match.Player1)
+		}
+	}
+
+	s.tournaments[t.ID] = t
+	if err := s.save(t); err != nil {
+		delete(s.tournaments, t.ID)
+		return nil, err
+	}
+	return t, nil
+}
+
+// Get returns a snapshot of the tournament, or nil
+func (s *Service) Get(id string) *Tournament {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, exists := s.tournaments[id]
+	if !exists {
+		return nil
+	}
+	state, _ := json.Marshal(t)
+	var snapshot Tournament
+	json.Unmarshal(state, &snapshot)
+	return &snapshot
+}
+
+// Join attaches a registered player's connection. Any of their matches whose
+// opponent is also connected starts right away.
+func (s *Service) Join(id, username string, conn *websocket.Conn) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, exists := s.tournaments[id]
+	if !exists {
+		return fmt.Errorf("tournament not found")
+	}
+	registered := false
+	for _, player := range t.Players {
+		if player == username {
+			registered = true
+		}
+	}
+	if !registered {
+		return fmt.Errorf("%s is not registered in this tournament", username)
+	}
+
+	if s.conns[id] == nil {
+		s.conns[id] = make(map[string]*websocket.Conn)
+	}
+	s.conns[id][username] = conn
+	s.startReadyMatches(t)
+	return nil
+}
+
+// RemoveConn forgets conn wherever a player joined with it
+func (s *Service) RemoveConn(conn *websocket.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, players := range s.conns {
+		for username, playerConn := range players {
+			if playerConn == conn {
+				delete(players, username)
+			}
+		}
+	}
+}
+
+// startReadyMatches creates a game for every decided pairing whose players
+// are both connected and not already playing
+func (s *Service) startReadyMatches(t *Tournament) {
+	if t.Status != "active" {
+		return
+	}
+
+	for roundIndex, round := range t.Rounds {
+		for matchIndex, match := range round {
+			if match.Winner != "" || match.GameID != "" || match.Player1 == "" || match.Player2 == "" {
+				continue
+			}
+			conn1, conn2 := s.conns[t.ID][match.Player1], s.conns[t.ID][match.Player2]
+			if conn1 == nil || conn2 == nil {
+				continue
+			}
+
+			g := s.games.CreateTournamentGame(
+				&game.Player{ID: uuid.New().String(), Username: match.Player1, Conn: conn1},
+				&game.Player{ID: uuid.New().String(), Username: match.Player2, Conn: conn2},
+			)
+			match.GameID = g.ID
+			match.GameIDs = append(match.GameIDs, g.ID)
+			if err := s.save(t); err != nil {
+				slog.Error("Error saving tournament", "tournamentId", t.ID, "error", err)
+			}
+
+			tournamentID, r, m := t.ID, roundIndex, matchIndex
+			context.AfterFunc(g.Context(), func() { s.recordResult(tournamentID, r, m, g.Final()) })
+			s.notify(g)
+		}
+	}
+}
+
+// recordResult advances the winner of a finished match game. Draws and
+// abandoned games leave the match open to be replayed.
+func (s *Service) recordResult(id string, roundIndex, matchIndex int, g *game.Game) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.tournaments[id]
+	match := t.Rounds[roundIndex][matchIndex]
+	if match.GameID != g.ID {
+		return
+	}
+	match.GameID = ""
+
+	switch g.Winner {
+	case g.Player1.ID:
+		s.advance(t, roundIndex, matchIndex, g.Player1.Username)
+	case g.Player2.ID:
+		s.advance(t, roundIndex, matchIndex, g.Player2.Username)
+	}
+
+	if err := s.save(t); err != nil {
+		slog.Error("Error saving tournament", "tournamentId", t.ID, "error", err)
+	}
+	s.startReadyMatches(t)
+}
+
+// advance records winner for a match and moves them into the next round, or
+// crowns them champion after the final
+func (s *Service) advance(t *Tournament, roundIndex, matchIndex int, winner string) {
+	t.Rounds[roundIndex][matchIndex].Winner = winner
+
+	if roundIndex == len(t.Rounds)-1 {
+		t.Champion = winner
+		t.Status = "finished"
+		return
+	}
+
+	next := t.Rounds[roundIndex+1][matchIndex/2]
+	if matchIndex%2 == 0 {
+		next.Player1 = winner
+	} else {
+		next.Player2 = winner
+	}
+}
+
+func (s *Service) save(t *Tournament) error {
+	state, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO tournaments (id, state, updated_at) VALUES ($1, $2, NOW())
+		 ON CONFLICT (id) DO UPDATE SET state = $2, updated_at = NOW()`,
+		t.ID, state,
+	)
+	return err
+}
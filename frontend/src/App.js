@@ -7,12 +7,15 @@ const WS_URL = process.env.REACT_APP_WS_URL || 'ws://localhost:3001/ws';
 function App() {
   const [username, setUsername] = useState('');
   const [enteredUsername, setEnteredUsername] = useState('');
+  const [enteredPassword, setEnteredPassword] = useState('');
   const [game, setGame] = useState(null);
   const [leaderboard, setLeaderboard] = useState([]);
   const [error, setError] = useState('');
   const [message, setMessage] = useState('');
   const wsRef = useRef(null);
   const gameIdRef = useRef(null);
+  const reconnectTokenRef = useRef(null);
+  const sessionTokenRef = useRef(null);
 
   useEffect(() => {
     fetchLeaderboard();
@@ -33,7 +36,7 @@ function App() {
       const response = await fetch(`${API_URL}/api/leaderboard`);
       if (response.ok) {
         const data = await response.json();
-        setLeaderboard(Array.isArray(data) ? data : []);
+        setLeaderboard(Array.isArray(data.entries) ? data.entries : []);
       } else {
         setLeaderboard([]);
       }
@@ -86,6 +89,9 @@ function App() {
       case 'gameState':
         setGame(data.game);
         gameIdRef.current = data.game.id;
+        if (data.reconnectToken) {
+          reconnectTokenRef.current = data.reconnectToken;
+        }
         setMessage('');
         setError('');
         break;
@@ -95,6 +101,9 @@ function App() {
       case 'playerReconnected':
         setMessage(`${data.username} reconnected!`);
         break;
+      case 'reconnectExpired':
+        setMessage(data.message);
+        break;
       case 'error':
         setError(data.message);
         break;
@@ -103,14 +112,39 @@ function App() {
     }
   };
 
-  const handleJoin = (e) => {
+  const signIn = async () => {
+    const response = await fetch(`${API_URL}/api/auth`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({
+        username: enteredUsername.trim(),
+        password: enteredPassword,
+      }),
+    });
+    const data = await response.json();
+    if (!response.ok) {
+      throw new Error(data.error?.message || 'Failed to sign in');
+    }
+    return data;
+  };
+
+  const handleJoin = async (e) => {
     e.preventDefault();
     if (!enteredUsername.trim()) {
       setError('Please enter a username');
       return;
     }
 
-    setUsername(enteredUsername.trim());
+    let session;
+    try {
+      session = await signIn();
+    } catch (err) {
+      setError(err.message);
+      return;
+    }
+    sessionTokenRef.current = session.token;
+
+    setUsername(session.username);
     setError('');
     setMessage('');
     connectWebSocket();
@@ -120,7 +154,7 @@ function App() {
       if (wsRef.current && wsRef.current.readyState === WebSocket.OPEN) {
         wsRef.current.send(JSON.stringify({
           type: 'join',
-          username: enteredUsername.trim(),
+          token: sessionTokenRef.current,
         }));
       }
     }, 100);
@@ -134,8 +168,9 @@ function App() {
       if (wsRef.current && wsRef.current.readyState === WebSocket.OPEN) {
         wsRef.current.send(JSON.stringify({
           type: 'rejoin',
-          username: username,
+          token: sessionTokenRef.current,
           gameId: gameIdRef.current,
+          reconnectToken: reconnectTokenRef.current,
         }));
       }
     }, 100);
@@ -223,6 +258,12 @@ function App() {
                   placeholder="Username"
                   maxLength={20}
                 />
+                <input
+                  type="password"
+                  value={enteredPassword}
+                  onChange={(e) => setEnteredPassword(e.target.value)}
+                  placeholder="Password (optional)"
+                />
                 <button type="submit">Join Game</button>
               </form>
             </div>